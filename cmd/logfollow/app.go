@@ -9,6 +9,7 @@ import (
 	"github.com/gsmcwhirter/go-util/v9/cli"
 
 	"github.com/gsmcwhirter/prettify/pkg/files/finder"
+	"github.com/gsmcwhirter/prettify/pkg/theme"
 )
 
 func optionParser() *cli.Command {
@@ -29,13 +30,28 @@ func optionParser() *cli.Command {
   Output Expression: <field name>[,<field name>...][,<formatter>]
     - Field names that are specified but not present in a line will be treated as an empty string
     - You might need to escape @ symbols in field names depending on your shell
+    - Commas inside brackets, braces, parentheses or double quotes do not separate fields
+	  (e.g. items.#(status=="ok")#.id); escape any other comma in a field name with a backslash (k\,1)
     - Valid <formatter> expressions include (with leading '|' character):
-	  |@tsv (tab-separated values)
-	  |@csv (comma-separated values)
+	  |@tsv (tab-separated values; tabs, newlines and backslashes in values are escaped)
+	  |@csv (comma-separated values, quoted per RFC 4180)
+	  |@tsv:header, |@csv:header (the same, starting with a row of the field names)
 	  |@ssv (space-separated values)
 	  |@nlsv (newline-separated values; default)
+	  |@json (a json object keyed by field name)
+	  |@logfmt (field=value pairs)
+	  |@table (columns aligned with spaces, under a header row)
+	  |@markdown (a Markdown table, e.g. for pasting into a PR)
+    - A field name may be followed by functions of its value, and renamed with "as":
+	  message|trunc(80)|upper, @timestamp|time(kitchen) as time, user|default(-)
+	  The functions are upper, lower, len, trunc(n), time(layout), default(x), json and keys.
+	  time(layout) takes a Go layout or rfc3339, rfc3339nano, kitchen, stamp, stampmilli, datetime, date or time.
+    - gjson modifiers (like |@reverse) must be named in --allow-modifiers to be used
 
-`, strings.Join(ff.SearchDirectories, "\n    - ")),
+  Colors: --color turns on json colorization, as does a FORCE_COLOR environment variable.
+  The palette comes from --theme (or $PRETTIFY_THEME); user themes can be defined in %s.
+
+`, strings.Join(ff.SearchDirectories, "\n    - "), theme.DefaultConfigPath()),
 		Example: "",
 	})
 
@@ -1,31 +1,50 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/gsmcwhirter/go-util/v9/cli"
 
 	"github.com/gsmcwhirter/prettify/pkg/files/finder"
 	"github.com/gsmcwhirter/prettify/pkg/files/pattern"
 	"github.com/gsmcwhirter/prettify/pkg/files/streamer"
+	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
 	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/streams/redact"
+	"github.com/gsmcwhirter/prettify/pkg/theme"
 )
 
 type catCommand struct {
 	cli         *cli.Command
 	fileFinder  *finder.Finder
 	linePrinter linehandler.FilterLineHandler
+	out         *bufio.Writer
+	flushDedupe func() error
+	flushEvents func() error
 
 	JSONPath     string
+	Modifiers    []string
 	JSONPretty   bool
+	Pretty       formatter.PrettyOptions
 	JSONColor    bool
 	JSONSort     bool
+	Theme        string
+	ColorDepth   string
 	WithBlanks   bool
 	WithFilename bool
+	Redact       redact.Config
+	Tee          teeConfig
+	Grep         grepConfig
+	Location     locationConfig
+	Human        humanConfig
+	Dedupe       dedupeConfig
+	Multiline    multilineConfig
 }
 
 func (cmd *catCommand) catFile(ctx context.Context) func(string, os.FileInfo, error) error {
@@ -38,9 +57,19 @@ func (cmd *catCommand) catFile(ctx context.Context) func(string, os.FileInfo, er
 		}
 
 		dirName, fileName := filepath.Split(path)
-		_, err = streamer.Cat(ctx, dirName, fileName, cmd.linePrinter)
+		if _, err = streamer.Cat(ctx, dirName, fileName, cmd.linePrinter); err != nil {
+			return err
+		}
 
-		return err
+		if err = cmd.flushEvents(); err != nil {
+			return err
+		}
+
+		if err = cmd.flushDedupe(); err != nil {
+			return err
+		}
+
+		return cmd.out.Flush()
 	}
 }
 
@@ -56,14 +85,51 @@ func (cmd *catCommand) run(c *cli.Command, args []string) error {
 
 	ctx := context.Background()
 
-	cmd.linePrinter = linehandler.NewLinePrinter(linehandler.Options{
-		WithBlanks:   cmd.WithBlanks,
-		WithFilename: cmd.WithFilename,
-		JSONPath:     cmd.JSONPath,
-		Pretty:       cmd.JSONPretty,
-		Color:        cmd.JSONColor,
-		Sort:         cmd.JSONSort,
-	})
+	expr, err := compileOutput(cmd.JSONPath, cmd.Modifiers)
+	if err != nil {
+		return err
+	}
+
+	th, jsonColor, err := setupColor(cmd.JSONColor, cmd.Theme, cmd.ColorDepth)
+	if err != nil {
+		return err
+	}
+
+	cmd.out = newOutput()
+	defer cmd.out.Flush() //nolint:errcheck // only left to do when stopping early; each file is flushed when done
+
+	printer, closeTee, err := withTee(newLinePrinter(withLocation(linehandler.Options{
+		WithBlanks:    cmd.WithBlanks,
+		WithFilename:  cmd.WithFilename,
+		Expression:    expr,
+		Pretty:        cmd.JSONPretty || cmd.Pretty.YAML,
+		PrettyOptions: cmd.Pretty,
+		Color:         jsonColor,
+		Sort:          cmd.JSONSort,
+		Output:        cmd.out,
+	}, cmd.Location, cmd.Grep), cmd.Human, th), cmd.Tee)
+	if err != nil {
+		return err
+	}
+	defer closeTee() //nolint:errcheck // the lines are all written by then
+
+	printer, err = withRedaction(printer, cmd.Redact)
+	if err != nil {
+		return err
+	}
+
+	printer, cmd.flushDedupe = withDedupe(printer, cmd.Dedupe, cmd.Human, false)
+
+	printer, err = withGrep(printer, cmd.Grep, false)
+	if err != nil {
+		return err
+	}
+
+	cmd.linePrinter, cmd.flushEvents, err = withMultiline(printer, cmd.Multiline, false)
+	if err != nil {
+		return err
+	}
+	cmd.linePrinter = withLineNumbering(cmd.linePrinter, cmd.Location)
 
 	var fp *pattern.Pattern
 
@@ -83,7 +149,7 @@ func (cmd *catCommand) run(c *cli.Command, args []string) error {
 		fp = &fpTmp
 	}
 
-	return fp.WalkFiles(ctx, cmd.catFile(ctx))
+	return ignoreBrokenPipe(fp.WalkFiles(ctx, cmd.catFile(ctx)))
 }
 
 func setupCat(c *cli.Command, appName string, fileFinder *finder.Finder) {
@@ -102,18 +168,36 @@ func setupCat(c *cli.Command, appName string, fileFinder *finder.Finder) {
 		"Cat the contents of all matching files, skipping blank lines, prefixing each line with the filename the line came from", fmt.Sprintf("%[1]s cat <filepat> --with-filename", appName),
 		"Cat the contents of all matching files to stdout, preserving blank lines", fmt.Sprintf("%[1]s cat <filepat> --with-blanks", appName),
 		"Cat the contents of the matching files to stdout, selecting only the @timestamp, @tag, and message fields from each json line, and outputting the data as tab-separated values", fmt.Sprintf("%[1]s cat <filepat> --output='@timestamp,@tag,message,|@tsv'", appName),
+		"Cat the contents of the matching files as a CSV file with a header row, e.g. for a spreadsheet", fmt.Sprintf("%[1]s cat <filepat> --output='@timestamp,level,message,|@csv:header' > out.csv", appName),
 		"Cat the contents of all matching files to stdout, skipping files that have a filename datetime after 2018010315*", fmt.Sprintf("%[1]s cat <filepat> --before='2018-01-03 15:'", appName),
 		"Cat the contents of all matching files to stdout, skipping files that have a filename datetime before 2018010315*", fmt.Sprintf("%[1]s cat <filepat> --since='2018-01-03 15:'", appName),
+		"Cat the contents of all matching files to stdout, hiding tokens, emails and similar values (e.g. for pasting into a ticket)", fmt.Sprintf("%[1]s cat <filepat> --redact", appName),
+		"Cat the contents of all matching files to stdout, formatted the same way prettify does", fmt.Sprintf("%[1]s cat <filepat> --human --color", appName),
+		"Cat the contents of all matching files to stdout, pretty-printed, while also saving the error lines to a CSV file", fmt.Sprintf("%[1]s cat <filepat> --pretty --tee=errors.csv --tee-match='\"level\":\"error\"' --tee-output='timestamp,message,|@csv'", appName),
+		"Cat the error lines of all matching files to stdout, with the 3 lines before and after each one", fmt.Sprintf("%[1]s cat <filepat> --match='\"level\":\"error\"' --context=3", appName),
+		"Cat the contents of all matching files to stdout as YAML-like text, with the timestamp and message first", fmt.Sprintf("%[1]s cat <filepat> --yaml --pin-keys=timestamp,message", appName),
+		"Open the error lines of all matching files in vim's quickfix list, to jump to each of them", fmt.Sprintf("vim -q <(%[1]s cat <filepat> --vimgrep --match='\"level\":\"error\"')", appName),
 	)
 
 	cat.SetRunFunc(opts.run)
 
 	cat.Flags().StringVarP(&opts.JSONPath, "output", "O", "", "An output expression (selects which fields to show and how)")
+	cat.Flags().StringSliceVar(&opts.Modifiers, "allow-modifiers", nil, "gjson modifiers (like reverse or flatten) to allow in --output; others are rejected")
 	cat.Flags().BoolVarP(&opts.JSONPretty, "pretty", "P", false, "Pretty-print json lines")
 	cat.Flags().BoolVarP(&opts.JSONColor, "color", "C", false, "Add color to pretty-printed json lines")
+	cat.Flags().StringVar(&opts.Theme, "theme", theme.NameFromEnv(), fmt.Sprintf("The color theme (%s, or one defined in %s; defaults to $PRETTIFY_THEME)", strings.Join(theme.Names(), ", "), theme.DefaultConfigPath()))
+	cat.Flags().StringVar(&opts.ColorDepth, "color-depth", "auto", "The number of colors the terminal supports (auto, 16, 256, truecolor)")
 	cat.Flags().BoolVarP(&opts.JSONSort, "sort", "S", false, "Sort output keys")
 	cat.Flags().BoolVar(&opts.WithBlanks, "with-blanks", false, "Include blank lines in the output")
 	cat.Flags().BoolVar(&opts.WithFilename, "with-filename", false, "Display the filename at the beginning of each line")
+	addRedactFlags(cat, &opts.Redact)
+	addTeeFlags(cat, &opts.Tee)
+	addGrepFlags(cat, &opts.Grep)
+	addLocationFlags(cat, &opts.Location)
+	addPrettyFlags(cat, &opts.Pretty)
+	addHumanFlags(cat, &opts.Human)
+	addDedupeFlags(cat, &opts.Dedupe)
+	addMultilineFlags(cat, &opts.Multiline)
 
 	c.AddSubCommands(cat)
 
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/fatih/color"
+
+	"github.com/gsmcwhirter/prettify/pkg/theme"
+)
+
+// setupColor loads the requested theme for json colorization, and decides whether color is on at all
+//
+// Color stays opt-in (--color or FORCE_COLOR) for logfollow, since its output is usually meant for other tools
+func setupColor(forceColor bool, themeName, colorDepth string) (*theme.Theme, bool, error) {
+	depth, err := theme.ParseDepth(colorDepth)
+	if err != nil {
+		return nil, false, err
+	}
+
+	th, err := theme.Load(themeName, theme.DefaultConfigPath(), depth)
+	if err != nil {
+		return nil, false, err
+	}
+
+	enabled := theme.Enabled(forceColor, false)
+	color.NoColor = !enabled
+
+	return th, enabled, nil
+}
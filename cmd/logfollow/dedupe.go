@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/gsmcwhirter/go-util/v9/cli"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/dedupe"
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+)
+
+// dedupeConfig holds the flags for collapsing runs of duplicate lines (shared by the commands that print lines)
+type dedupeConfig struct {
+	Enabled bool
+	Fields  []string
+	Ignore  []string
+}
+
+// addDedupeFlags registers the flags that fill in a dedupeConfig
+func addDedupeFlags(c *cli.Command, cfg *dedupeConfig) {
+	c.Flags().BoolVar(&cfg.Enabled, "dedupe", false, "Collapse runs of consecutive duplicate lines (ignoring their timestamps) into one; collapsed json lines get "+
+		dedupe.CountField+", "+dedupe.FirstField+" and "+dedupe.LastField+" fields (for --output), and --human and plain text lines a (×N, first..last) suffix")
+	c.Flags().StringSliceVar(&cfg.Fields, "dedupe-fields", nil, "The fields that must match, along with --message-field, for lines to be duplicates (all fields when not present)")
+	c.Flags().StringSliceVar(&cfg.Ignore, "dedupe-ignore", nil, "Volatile fields (like attempt counters) that may differ between duplicates")
+}
+
+// withDedupe wraps lh in a dedupe.Handler, if --dedupe was requested
+//
+// The count and times of a collapsed run are added to its json line as fields, so machine-readable output stays valid,
+// except for --human output (hc), which gets the suffix. reversed is for lines read last first, as by tac.
+// The returned flush func passes along the run of duplicates being collapsed, and must be called at the end
+// of each file and whenever the lines go idle.
+func withDedupe(lh linehandler.LineHandler, cfg dedupeConfig, hc humanConfig, reversed bool) (linehandler.LineHandler, func() error) {
+	if !cfg.Enabled {
+		return lh, func() error { return nil }
+	}
+
+	h := dedupe.NewHandler(lh, dedupe.Options{
+		MessageField: hc.Options.MessageField,
+		Fields:       cfg.Fields,
+		IgnoreFields: cfg.Ignore,
+		Reversed:     reversed,
+		AsFields:     !hc.Enabled,
+	})
+
+	return h, h.Flush
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/gsmcwhirter/go-util/v9/cli"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+)
+
+// grepConfig holds the flags for showing only matching lines, with the lines around them (shared by the commands that print lines)
+type grepConfig struct {
+	Match   string
+	Invert  bool
+	Before  int
+	After   int
+	Context int
+}
+
+// addGrepFlags registers the flags that fill in a grepConfig
+func addGrepFlags(c *cli.Command, cfg *grepConfig) {
+	c.Flags().StringVarP(&cfg.Match, "match", "e", "", "Only show lines matching this regular expression")
+	c.Flags().BoolVarP(&cfg.Invert, "invert-match", "v", false, "Only show lines not matching --match")
+	c.Flags().IntVarP(&cfg.Before, "before-context", "B", 0, "Also show this many lines before each --match line")
+	c.Flags().IntVarP(&cfg.After, "after-context", "A", 0, "Also show this many lines after each --match line")
+	c.Flags().IntVar(&cfg.Context, "context", 0, "Also show this many lines before and after each --match line (unless -B or -A say otherwise)")
+}
+
+// withGrep wraps lh so only lines matching --match (and their context) are passed to it
+//
+// reversed is for commands that print lines last-first, so the context lines still come from the right side of a match
+func withGrep(lh linehandler.LineHandler, cfg grepConfig, reversed bool) (linehandler.LineHandler, error) {
+	if cfg.Match == "" {
+		return lh, nil
+	}
+
+	re, err := regexp.Compile(cfg.Match)
+	if err != nil {
+		return nil, fmt.Errorf("bad --match: %w", err)
+	}
+
+	before, after := cfg.Before, cfg.After
+	if before == 0 {
+		before = cfg.Context
+	}
+	if after == 0 {
+		after = cfg.Context
+	}
+
+	return linehandler.NewContextHandler(lh, linehandler.ContextOptions{
+		Match: func(rec *linehandler.Record) bool {
+			return re.MatchString(rec.Text()) != cfg.Invert
+		},
+		Before:   before,
+		After:    after,
+		Reversed: reversed,
+	}), nil
+}
+
+// grepColumn gives the 1-based column of the first --match in a line (1 when there is none, or the match is inverted)
+func grepColumn(cfg grepConfig) func(text string) int {
+	re, err := regexp.Compile(cfg.Match)
+	if cfg.Match == "" || cfg.Invert || err != nil { // a bad --match is reported by withGrep
+		return nil
+	}
+
+	return func(text string) int {
+		loc := re.FindStringIndex(text)
+		if loc == nil {
+			return 1
+		}
+
+		return loc[0] + 1
+	}
+}
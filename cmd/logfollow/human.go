@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/gsmcwhirter/go-util/v9/cli"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/human"
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/theme"
+)
+
+// humanConfig holds the flags for prettify-style output (shared by the commands that print lines)
+type humanConfig struct {
+	Enabled bool
+	Options human.Options
+}
+
+// addHumanFlags registers the flags that fill in a humanConfig, mirroring the prettify flags
+func addHumanFlags(c *cli.Command, cfg *humanConfig) {
+	c.Flags().BoolVar(&cfg.Enabled, "human", false, "Print json lines the way prettify does (ignores --output and --pretty)")
+	c.Flags().StringVar(&cfg.Options.MessageField, "message-field", "message", "The name of a field that contains the 'message' (for --human, --dedupe-fields and --rate-key)")
+	c.Flags().StringVar(&cfg.Options.TimestampField, "timestamp-field", "timestamp", "The name of the timestamp field (for --human)")
+	c.Flags().StringVar(&cfg.Options.LevelField, "level-field", "level", "The name of the field containing the log level (for --human)")
+	c.Flags().StringVar(&cfg.Options.StackField, "stack-field", "stack", "The name of the field containing the stack trace (for --human)")
+	c.Flags().StringSliceVar(&cfg.Options.Output, "fields", nil, "A list of fields to show (for --human; all when not present)")
+	c.Flags().StringSliceVar(&cfg.Options.Exclude, "exclude", nil, "A list of fields to exclude (for --human; takes priority over everything else)")
+	c.Flags().StringSliceVar(&cfg.Options.MultilineFields, "multiline-fields", nil, "A list of fields with multiline content to be specially formatted (for --human)")
+	c.Flags().BoolVar(&cfg.Options.AutoFields, "auto-fields", false, "Include auto-generated tags from log lines (for --human)")
+	c.Flags().BoolVar(&cfg.Options.AllStacks, "all-stacks", false, "Include printing a stack trace for non-error lines where it is included (for --human)")
+	c.Flags().BoolVar(&cfg.Options.SkipStacks, "no-stacks", false, "Skip printing a stack trace for lines where it is included (for --human)")
+	c.Flags().BoolVar(&cfg.Options.MultilineTags, "multiline-tags", false, "Format tags each on their own line (for --human)")
+}
+
+// newLinePrinter creates the line printer for lpOpts, rendering like prettify if --human was requested
+//
+// th colors the json and --human output, and is only used when color is turned on
+func newLinePrinter(lpOpts linehandler.Options, cfg humanConfig, th *theme.Theme) linehandler.FilterLineHandler {
+	lpOpts.PrettyOptions.Style = th.PrettyStyle()
+
+	if !cfg.Enabled {
+		return linehandler.NewLinePrinter(lpOpts)
+	}
+
+	opts := cfg.Options
+	if lpOpts.Color {
+		opts.Theme = th
+	}
+
+	return human.NewLineHandler(opts, lpOpts)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/gsmcwhirter/go-util/v9/cli"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+)
+
+// locationConfig holds the flags for showing where in their files lines are (shared by the commands that print lines)
+type locationConfig struct {
+	LineNumbers bool
+	Offsets     bool
+	VimGrep     bool
+}
+
+// addLocationFlags registers the flags that fill in a locationConfig
+func addLocationFlags(c *cli.Command, cfg *locationConfig) {
+	c.Flags().BoolVar(&cfg.LineNumbers, "line-numbers", false, "Display the line number of each line (after any filename)")
+	c.Flags().BoolVar(&cfg.Offsets, "offsets", false, "Display the byte offset in its file of each line (after any filename and line number)")
+	c.Flags().BoolVar(&cfg.VimGrep, "vimgrep", false, "Print lines as path:line:column:text, as vim's :grep (and other editors) read them; the column is that of the --match")
+}
+
+// withLocation sets up lpOpts to show the locations of lines requested in cfg
+func withLocation(lpOpts linehandler.Options, cfg locationConfig, grep grepConfig) linehandler.Options {
+	lpOpts.WithLineNumbers = cfg.LineNumbers
+	lpOpts.WithOffsets = cfg.Offsets
+	lpOpts.VimGrep = cfg.VimGrep
+
+	if cfg.VimGrep {
+		lpOpts.Column = grepColumn(grep)
+	}
+
+	return lpOpts
+}
+
+// withLineNumbering marks lh as not using line numbers (or offsets) unless cfg shows them,
+// so the files are not read from their start just to number the lines
+func withLineNumbering(lh linehandler.LineHandler, cfg locationConfig) linehandler.LineHandler {
+	if cfg.LineNumbers || cfg.Offsets || cfg.VimGrep {
+		return lh
+	}
+
+	return linehandler.WithoutLineNumbers(lh)
+}
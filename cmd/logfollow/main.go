@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 // Build-time variables
@@ -26,6 +28,9 @@ func main() {
 func run() (int, error) {
 	code := 0
 
+	// Without this, writing to a closed stdout kills the process before the commands can stop (and close their files)
+	signal.Ignore(syscall.SIGPIPE)
+
 	cli := optionParser()
 
 	err := cli.Execute()
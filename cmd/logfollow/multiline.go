@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/gsmcwhirter/go-util/v9/cli"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/streams/multiline"
+)
+
+// multilineConfig holds the flags for assembling multi-line events (shared by the commands that print lines)
+type multilineConfig struct {
+	Enabled      bool
+	Start        []string
+	Continuation []string
+	MaxLines     int
+}
+
+// addMultilineFlags registers the flags that fill in a multilineConfig
+func addMultilineFlags(c *cli.Command, cfg *multilineConfig) {
+	c.Flags().BoolVar(&cfg.Enabled, "multiline", false, "Treat plain-text events spanning several lines (like stack traces) as one line, for matching and printing")
+	c.Flags().StringSliceVar(&cfg.Start, "multiline-start", nil, "Regular expressions for the lines that start an event (lines starting with a date or time, {, or a level keyword when not present)")
+	c.Flags().StringSliceVar(&cfg.Continuation, "multiline-continue", nil, "Regular expressions for the lines that continue an event, even if they look like a start (indented lines, and ones starting with 'at ' or 'goroutine ' when not present)")
+	c.Flags().IntVar(&cfg.MaxLines, "multiline-max-lines", multiline.DefaultMaxLines, "The most lines in an event, after which a new one is started")
+}
+
+// withMultiline wraps lh so the lines of multi-line events are passed to it together, if --multiline was requested
+//
+// reversed is for commands that print lines last-first. The returned flush func passes along the event being assembled,
+// and must be called at the end of each file and whenever the lines go idle.
+func withMultiline(lh linehandler.LineHandler, cfg multilineConfig, reversed bool) (linehandler.LineHandler, func() error, error) {
+	noop := func() error { return nil }
+
+	if !cfg.Enabled {
+		return lh, noop, nil
+	}
+
+	h, err := multiline.NewHandler(lh, multiline.Options{
+		Start:        cfg.Start,
+		Continuation: cfg.Continuation,
+		MaxLines:     cfg.MaxLines,
+		Reversed:     reversed,
+	})
+	if err != nil {
+		return nil, noop, err
+	}
+
+	return h, h.Flush, nil
+}
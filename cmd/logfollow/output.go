@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
+)
+
+// compileOutput checks the --output expression up front, so a typo fails before any file is read (nil when not given)
+//
+// modifiers are the gjson modifiers allowed in it (from --allow-modifiers)
+func compileOutput(expr string, modifiers []string) (*formatter.Expression, error) {
+	opts := formatter.CompileOptions{AllowModifiers: modifiers}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("bad --allow-modifiers: %w", err)
+	}
+
+	if expr == "" {
+		return nil, nil
+	}
+
+	e, err := formatter.CompileWith(expr, opts)
+	if err != nil {
+		return nil, fmt.Errorf("bad --output: %w", err)
+	}
+
+	return e, nil
+}
+
+// ignoreBrokenPipe drops the error from writing to a closed pipe: the reader going away (e.g. `| head`) is a normal way to stop
+func ignoreBrokenPipe(err error) error {
+	if errors.Is(err, syscall.EPIPE) {
+		return nil
+	}
+
+	return err
+}
+
+// newOutput buffers stdout for the lines a command prints; it must be flushed after each file and before exiting
+func newOutput() *bufio.Writer {
+	return bufio.NewWriterSize(os.Stdout, 64*1024)
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/gsmcwhirter/go-util/v9/cli"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
+)
+
+// addPrettyFlags registers the flags that fill in the options for --pretty output (shared by the commands that print lines)
+func addPrettyFlags(c *cli.Command, opts *formatter.PrettyOptions) {
+	c.Flags().StringVar(&opts.Indent, "indent", "  ", "The indentation of each nesting level (for --pretty)")
+	c.Flags().IntVar(&opts.Width, "width", 80, "The widest an array or object may be and still be printed on one line (for --pretty)")
+	c.Flags().StringVar(&opts.Prefix, "prefix", "", "Text to start every line with (for --pretty)")
+	c.Flags().StringSliceVar(&opts.PinnedKeys, "pin-keys", nil, "Keys to print first, in this order, with the rest following sorted (--sort) or as they were (e.g. timestamp,level,message)")
+	c.Flags().BoolVar(&opts.YAML, "yaml", false, "Pretty-print json as YAML-like key: value lines, which are easier to read for deeply nested values (implies --pretty)")
+}
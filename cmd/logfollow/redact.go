@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gsmcwhirter/go-util/v9/cli"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/streams/redact"
+)
+
+// addRedactFlags registers the flags that fill in a redact.Config (shared by the commands that print lines)
+func addRedactFlags(c *cli.Command, cfg *redact.Config) {
+	c.Flags().BoolVar(&cfg.Enabled, "redact", false, "Replace secrets and personal data with "+redact.Placeholder)
+	c.Flags().BoolVar(&cfg.Pseudonymize, "pseudonymize", false, "Replace secrets and personal data with stable pseudonyms, so they can still be correlated")
+	c.Flags().StringSliceVar(&cfg.Fields, "redact-fields", redact.DefaultFieldPatterns, "Field name patterns whose values are hidden")
+	c.Flags().StringSliceVar(&cfg.Rules, "redact-rules", redact.RuleNames(), fmt.Sprintf("Built-in value patterns to hide wherever they appear (%s)", strings.Join(redact.RuleNames(), ", ")))
+	c.Flags().StringSliceVar(&cfg.Regexes, "redact-regex", nil, "Additional regular expressions for values to hide wherever they appear")
+	c.Flags().StringVar(&cfg.Key, "redact-key", os.Getenv("PRETTIFY_REDACT_KEY"), "The key for --pseudonymize; pseudonyms only match between runs with the same key (defaults to $PRETTIFY_REDACT_KEY, or a random key)")
+}
+
+// withRedaction wraps lh in a redact.Handler, if redaction is configured
+func withRedaction(lh linehandler.LineHandler, cfg redact.Config) (linehandler.LineHandler, error) {
+	r, err := cfg.Redactor()
+	if err != nil || r == nil {
+		return lh, err
+	}
+
+	return redact.NewHandler(lh, r), nil
+}
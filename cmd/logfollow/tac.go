@@ -1,31 +1,50 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/gsmcwhirter/go-util/v9/cli"
 
 	"github.com/gsmcwhirter/prettify/pkg/files/finder"
 	"github.com/gsmcwhirter/prettify/pkg/files/pattern"
 	"github.com/gsmcwhirter/prettify/pkg/files/streamer"
+	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
 	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/streams/redact"
+	"github.com/gsmcwhirter/prettify/pkg/theme"
 )
 
 type tacCommand struct {
 	cli         *cli.Command
 	fileFinder  *finder.Finder
 	linePrinter linehandler.FilterLineHandler
+	out         *bufio.Writer
+	flushDedupe func() error
+	flushEvents func() error
 
 	JSONPath     string
+	Modifiers    []string
 	JSONPretty   bool
+	Pretty       formatter.PrettyOptions
 	JSONColor    bool
 	JSONSort     bool
+	Theme        string
+	ColorDepth   string
 	WithBlanks   bool
 	WithFilename bool
+	Redact       redact.Config
+	Tee          teeConfig
+	Grep         grepConfig
+	Location     locationConfig
+	Human        humanConfig
+	Dedupe       dedupeConfig
+	Multiline    multilineConfig
 }
 
 func (cmd *tacCommand) tacFile(ctx context.Context) func(string, os.FileInfo, error) error {
@@ -38,9 +57,19 @@ func (cmd *tacCommand) tacFile(ctx context.Context) func(string, os.FileInfo, er
 		}
 
 		dirName, fileName := filepath.Split(path)
-		_, err = streamer.Tac(ctx, dirName, fileName, cmd.linePrinter)
+		if _, err = streamer.Tac(ctx, dirName, fileName, cmd.linePrinter); err != nil {
+			return err
+		}
 
-		return err
+		if err = cmd.flushEvents(); err != nil {
+			return err
+		}
+
+		if err = cmd.flushDedupe(); err != nil {
+			return err
+		}
+
+		return cmd.out.Flush()
 	}
 }
 
@@ -55,14 +84,51 @@ func (cmd *tacCommand) run(c *cli.Command, args []string) error {
 
 	ctx := context.Background()
 
-	cmd.linePrinter = linehandler.NewLinePrinter(linehandler.Options{
-		WithBlanks:   cmd.WithBlanks,
-		WithFilename: cmd.WithFilename,
-		JSONPath:     cmd.JSONPath,
-		Pretty:       cmd.JSONPretty,
-		Color:        cmd.JSONColor,
-		Sort:         cmd.JSONSort,
-	})
+	expr, err := compileOutput(cmd.JSONPath, cmd.Modifiers)
+	if err != nil {
+		return err
+	}
+
+	th, jsonColor, err := setupColor(cmd.JSONColor, cmd.Theme, cmd.ColorDepth)
+	if err != nil {
+		return err
+	}
+
+	cmd.out = newOutput()
+	defer cmd.out.Flush() //nolint:errcheck // only left to do when stopping early; each file is flushed when done
+
+	printer, closeTee, err := withTee(newLinePrinter(withLocation(linehandler.Options{
+		WithBlanks:    cmd.WithBlanks,
+		WithFilename:  cmd.WithFilename,
+		Expression:    expr,
+		Pretty:        cmd.JSONPretty || cmd.Pretty.YAML,
+		PrettyOptions: cmd.Pretty,
+		Color:         jsonColor,
+		Sort:          cmd.JSONSort,
+		Output:        cmd.out,
+	}, cmd.Location, cmd.Grep), cmd.Human, th), cmd.Tee)
+	if err != nil {
+		return err
+	}
+	defer closeTee() //nolint:errcheck // the lines are all written by then
+
+	printer, err = withRedaction(printer, cmd.Redact)
+	if err != nil {
+		return err
+	}
+
+	printer, cmd.flushDedupe = withDedupe(printer, cmd.Dedupe, cmd.Human, true)
+
+	printer, err = withGrep(printer, cmd.Grep, true)
+	if err != nil {
+		return err
+	}
+
+	cmd.linePrinter, cmd.flushEvents, err = withMultiline(printer, cmd.Multiline, true)
+	if err != nil {
+		return err
+	}
+	cmd.linePrinter = withLineNumbering(cmd.linePrinter, cmd.Location)
 
 	var fp *pattern.Pattern
 
@@ -82,7 +148,7 @@ func (cmd *tacCommand) run(c *cli.Command, args []string) error {
 		fp = &fpTmp
 	}
 
-	return fp.WalkFilesReverse(ctx, cmd.tacFile(ctx))
+	return ignoreBrokenPipe(fp.WalkFilesReverse(ctx, cmd.tacFile(ctx)))
 }
 
 func setupTac(c *cli.Command, appName string, fileFinder *finder.Finder) {
@@ -100,6 +166,7 @@ func setupTac(c *cli.Command, appName string, fileFinder *finder.Finder) {
 		"Tac the contents of all matching files, skipping blank lines, prefixing each line with the filename the line came from", fmt.Sprintf("%s tac <filepat> --with-filename", appName),
 		"Tac the contents of all matching files to stdout, preserving blank lines", fmt.Sprintf("%s tac <filepat> --with-blanks", appName),
 		"Tac the contents of the matching files to stdout, selecting only the @timestamp, @tag, and message fields from each json line, and outputting the data as tab-separated values", fmt.Sprintf("%s tac <filepat> --output='@timestamp,@tag,message,|@tsv'", appName),
+		"Tac the contents of the matching files to stdout as a Markdown table of the level and message fields", fmt.Sprintf("%s tac <filepat> --output='level,message,|@markdown'", appName),
 		"Tac the contents of all matching files to stdout, skipping files that have a filename datetime after 2018010315*", fmt.Sprintf("%s tac <filepat> --before='2018-01-03 15:'", appName),
 		"Tac the contents of all matching files to stdout, skipping files that have a filename datetime before 2018010315*", fmt.Sprintf("%s tac <filepat> --since='2018-01-03 15:'", appName),
 	)
@@ -107,11 +174,22 @@ func setupTac(c *cli.Command, appName string, fileFinder *finder.Finder) {
 	tac.SetRunFunc(opts.run)
 
 	tac.Flags().StringVarP(&opts.JSONPath, "output", "O", "", "An output expression (selects which fields to show and how)")
+	tac.Flags().StringSliceVar(&opts.Modifiers, "allow-modifiers", nil, "gjson modifiers (like reverse or flatten) to allow in --output; others are rejected")
 	tac.Flags().BoolVarP(&opts.JSONPretty, "pretty", "P", false, "Pretty-print json lines")
 	tac.Flags().BoolVarP(&opts.JSONColor, "color", "C", false, "Add color to pretty-printed json lines")
+	tac.Flags().StringVar(&opts.Theme, "theme", theme.NameFromEnv(), fmt.Sprintf("The color theme (%s, or one defined in %s; defaults to $PRETTIFY_THEME)", strings.Join(theme.Names(), ", "), theme.DefaultConfigPath()))
+	tac.Flags().StringVar(&opts.ColorDepth, "color-depth", "auto", "The number of colors the terminal supports (auto, 16, 256, truecolor)")
 	tac.Flags().BoolVarP(&opts.JSONSort, "sort", "S", false, "Sort output keys")
 	tac.Flags().BoolVar(&opts.WithBlanks, "with-blanks", false, "Include blank lines in the output")
 	tac.Flags().BoolVar(&opts.WithFilename, "with-filename", false, "Display the filename at the beginning of each line")
+	addRedactFlags(tac, &opts.Redact)
+	addTeeFlags(tac, &opts.Tee)
+	addGrepFlags(tac, &opts.Grep)
+	addLocationFlags(tac, &opts.Location)
+	addPrettyFlags(tac, &opts.Pretty)
+	addHumanFlags(tac, &opts.Human)
+	addDedupeFlags(tac, &opts.Dedupe)
+	addMultilineFlags(tac, &opts.Multiline)
 
 	c.AddSubCommands(tac)
 
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gsmcwhirter/go-util/v9/cli"
 
@@ -11,7 +14,11 @@ import (
 	"github.com/gsmcwhirter/prettify/pkg/files/pattern"
 	"github.com/gsmcwhirter/prettify/pkg/files/streamer"
 	"github.com/gsmcwhirter/prettify/pkg/files/watcher"
+	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
 	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/streams/redact"
+	"github.com/gsmcwhirter/prettify/pkg/streams/sampling"
+	"github.com/gsmcwhirter/prettify/pkg/theme"
 )
 
 type tailCommand struct {
@@ -19,15 +26,35 @@ type tailCommand struct {
 	fileFinder  *finder.Finder
 	fileWatcher *watcher.Watcher
 	linePrinter linehandler.FilterLineHandler
+	sampler     *sampling.Handler
+	out         *bufio.Writer
+	flushDedupe func() error
+	flushEvents func() error
 
 	JSONPath     string
+	Modifiers    []string
 	JSONPretty   bool
+	Pretty       formatter.PrettyOptions
 	JSONColor    bool
 	JSONSort     bool
+	Theme        string
+	ColorDepth   string
 	Follow       bool
 	WithBlanks   bool
 	WithFilename bool
+	Redact       redact.Config
+	Tee          teeConfig
+	Grep         grepConfig
+	Location     locationConfig
+	Human        humanConfig
+	Dedupe       dedupeConfig
+	Multiline    multilineConfig
 	NumLines     uint
+	RateLimit    int
+	RateInterval time.Duration
+	RateKey      string
+	SampleRate   string
+	SampleKey    string
 }
 
 func (cmd *tailCommand) printTail(ctx context.Context) (lastFile string, lastFilePos int64, err error) {
@@ -72,37 +99,133 @@ func (cmd *tailCommand) run(c *cli.Command, args []string) error {
 		fp = &fpTmp
 	}
 
+	var sampleRate float64
+	if cmd.SampleRate != "" {
+		rate, err := sampling.ParseRate(cmd.SampleRate)
+		if err != nil {
+			return err
+		}
+		sampleRate = rate
+	}
+
 	cmd.fileWatcher = watcher.NewWatcher(fp)
-	cmd.linePrinter = linehandler.NewLinePrinter(linehandler.Options{
-		WithBlanks:   cmd.WithBlanks,
-		WithFilename: cmd.WithFilename,
-		JSONPath:     cmd.JSONPath,
-		Pretty:       cmd.JSONPretty,
-		Color:        cmd.JSONColor,
-		Sort:         cmd.JSONSort,
+	expr, err := compileOutput(cmd.JSONPath, cmd.Modifiers)
+	if err != nil {
+		return err
+	}
+
+	th, jsonColor, err := setupColor(cmd.JSONColor, cmd.Theme, cmd.ColorDepth)
+	if err != nil {
+		return err
+	}
+
+	cmd.out = newOutput()
+	defer cmd.out.Flush() //nolint:errcheck // only left to do when stopping early; the output is flushed when idle
+
+	printer, closeTee, err := withTee(newLinePrinter(withLocation(linehandler.Options{
+		WithBlanks:    cmd.WithBlanks,
+		WithFilename:  cmd.WithFilename,
+		Expression:    expr,
+		Pretty:        cmd.JSONPretty || cmd.Pretty.YAML,
+		PrettyOptions: cmd.Pretty,
+		Color:         jsonColor,
+		Sort:          cmd.JSONSort,
+		Output:        cmd.out,
+	}, cmd.Location, cmd.Grep), cmd.Human, th), cmd.Tee)
+	if err != nil {
+		return err
+	}
+	defer closeTee() //nolint:errcheck // the lines are all written by then
+
+	printer, err = withRedaction(printer, cmd.Redact)
+	if err != nil {
+		return err
+	}
+
+	printer, cmd.flushDedupe = withDedupe(printer, cmd.Dedupe, cmd.Human, false)
+
+	printer, err = withGrep(printer, cmd.Grep, false)
+	if err != nil {
+		return err
+	}
+
+	rateKey := cmd.RateKey
+	if rateKey == "" {
+		rateKey = cmd.Human.Options.MessageField
+	}
+
+	cmd.sampler = sampling.NewHandler(printer, sampling.Options{
+		SampleRate:   sampleRate,
+		SampleKey:    cmd.SampleKey,
+		RateLimit:    cmd.RateLimit,
+		RateInterval: cmd.RateInterval,
+		RateKey:      rateKey,
 	})
 
+	cmd.linePrinter, cmd.flushEvents, err = withMultiline(cmd.sampler, cmd.Multiline, false)
+	if err != nil {
+		return err
+	}
+	cmd.linePrinter = withLineNumbering(cmd.linePrinter, cmd.Location)
+
 	// Sets the SeenFiles
-	err := cmd.fileWatcher.Run(ctx)
+	err = cmd.fileWatcher.Run(ctx)
 	if err != nil {
 		return err
 	}
 
 	lastFile, lastFilePos, err := cmd.printTail(ctx)
 	if err != nil {
-		return err
+		return ignoreBrokenPipe(err)
 	}
 
 	if !cmd.Follow {
-		return nil
+		return ignoreBrokenPipe(cmd.flush(lastFile))
+	}
+
+	if err := cmd.flushIdle(); err != nil {
+		return ignoreBrokenPipe(err)
 	}
 
 	tailFollower := streamer.TailFollower{
 		FileWatcher: cmd.fileWatcher,
 		LineHandler: cmd.linePrinter,
+		Flush:       cmd.flushIdle,
+	}
+
+	err = tailFollower.FollowTail(ctx, lastFile, lastFilePos)
+	if err != nil {
+		return ignoreBrokenPipe(err)
+	}
+
+	return ignoreBrokenPipe(cmd.flush(lastFile))
+}
+
+// flush prints the event being assembled, the notices for lines still being suppressed, and then everything still buffered
+func (cmd *tailCommand) flush(lastFile string) error {
+	if err := cmd.flushEvents(); err != nil {
+		return err
+	}
+
+	if err := cmd.sampler.Flush(lastFile); err != nil {
+		return err
+	}
+
+	return cmd.flushIdle()
+}
+
+// flushIdle prints the event being assembled, the run of duplicates being collapsed, and then everything still buffered,
+// for when there are no more lines for now
+func (cmd *tailCommand) flushIdle() error {
+	if err := cmd.flushEvents(); err != nil {
+		return err
+	}
+
+	if err := cmd.flushDedupe(); err != nil {
+		return err
 	}
 
-	return tailFollower.FollowTail(ctx, lastFile, lastFilePos)
+	return cmd.out.Flush()
 }
 
 func setupTail(c *cli.Command, appName string, fileFinder *finder.Finder) {
@@ -123,6 +246,10 @@ func setupTail(c *cli.Command, appName string, fileFinder *finder.Finder) {
 		"Tail the contents of matching files, skipping blank lines, prefixing each line with the filename the line came from.", fmt.Sprintf("%s tail -n 10 <filepat> --with-filename", appName),
 		"Tail the contents of matching files to stdout, preserving blank lines", fmt.Sprintf("%s tail -n 10<filepat> --with-blanks", appName),
 		"Tail the contents of the matching files to stdout, selecting only the @timestamp, @tag, and message fields from each json line, and outputting the data as tab-separated values", fmt.Sprintf("%s tail -n 10 <filepat> --jj='@timestamp,@tag,message,|@tsv'", appName),
+		"Follow the contents of matching files, showing at most 10 lines with the same message each second", fmt.Sprintf("%s tail -f <filepat> --rate-limit 10", appName),
+		"Follow the contents of matching files, showing only 1%% of traces (all lines of a kept trace are shown)", fmt.Sprintf("%s tail -f <filepat> --sample 1%% --sample-key trace_id", appName),
+		"Follow the contents of matching plain-text files, showing only the errors, each with its whole stack trace", fmt.Sprintf("%s tail -f <filepat> --multiline --match=ERROR", appName),
+		"Follow the contents of matching files, collapsing retry loops into one line even as their attempt counter goes up", fmt.Sprintf("%s tail -f <filepat> --dedupe --dedupe-ignore attempt", appName),
 	)
 
 	tail.SetRunFunc(opts.run)
@@ -130,11 +257,27 @@ func setupTail(c *cli.Command, appName string, fileFinder *finder.Finder) {
 	tail.Flags().BoolVarP(&opts.Follow, "follow", "f", false, "Follow the files")
 	tail.Flags().UintVarP(&opts.NumLines, "num-lines", "n", 5, "Tail starting this many lines back")
 	tail.Flags().StringVarP(&opts.JSONPath, "output", "O", "", "An output expression (selects which fields to show and how)")
+	tail.Flags().StringSliceVar(&opts.Modifiers, "allow-modifiers", nil, "gjson modifiers (like reverse or flatten) to allow in --output; others are rejected")
 	tail.Flags().BoolVarP(&opts.JSONPretty, "pretty", "P", false, "Pretty-print json lines")
 	tail.Flags().BoolVarP(&opts.JSONColor, "color", "C", false, "Add color to pretty-printed json lines")
+	tail.Flags().StringVar(&opts.Theme, "theme", theme.NameFromEnv(), fmt.Sprintf("The color theme (%s, or one defined in %s; defaults to $PRETTIFY_THEME)", strings.Join(theme.Names(), ", "), theme.DefaultConfigPath()))
+	tail.Flags().StringVar(&opts.ColorDepth, "color-depth", "auto", "The number of colors the terminal supports (auto, 16, 256, truecolor)")
 	tail.Flags().BoolVarP(&opts.JSONSort, "sort", "S", false, "Sort output keys")
 	tail.Flags().BoolVar(&opts.WithBlanks, "with-blanks", false, "Include blank lines in the output")
 	tail.Flags().BoolVar(&opts.WithFilename, "with-filename", false, "Display the filename at the beginning of each line")
+	addRedactFlags(tail, &opts.Redact)
+	addTeeFlags(tail, &opts.Tee)
+	addGrepFlags(tail, &opts.Grep)
+	addLocationFlags(tail, &opts.Location)
+	addPrettyFlags(tail, &opts.Pretty)
+	addHumanFlags(tail, &opts.Human)
+	addDedupeFlags(tail, &opts.Dedupe)
+	addMultilineFlags(tail, &opts.Multiline)
+	tail.Flags().IntVar(&opts.RateLimit, "rate-limit", 0, "Show at most this many similar lines per --rate-interval, then a suppression notice (unlimited when 0)")
+	tail.Flags().DurationVar(&opts.RateInterval, "rate-interval", time.Second, "The window for --rate-limit")
+	tail.Flags().StringVar(&opts.RateKey, "rate-key", "", "The field that makes lines similar for --rate-limit (--message-field when not present)")
+	tail.Flags().StringVar(&opts.SampleRate, "sample", "", "Only show this fraction of --sample-key values, e.g. 1% (all when not present)")
+	tail.Flags().StringVar(&opts.SampleKey, "sample-key", "trace_id", "The field whose value decides whether a line is sampled (falls back to the whole line when missing)")
 
 	c.AddSubCommands(tail)
 
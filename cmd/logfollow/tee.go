@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/gsmcwhirter/go-util/v9/cli"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+)
+
+// teeConfig holds the flags for also writing (some) lines to a file (shared by the commands that print lines)
+type teeConfig struct {
+	Path   string
+	Output string
+	Match  string
+}
+
+// addTeeFlags registers the flags that fill in a teeConfig
+func addTeeFlags(c *cli.Command, cfg *teeConfig) {
+	c.Flags().StringVar(&cfg.Path, "tee", "", "Also write lines to this file (appending to it), alongside the usual output")
+	c.Flags().StringVar(&cfg.Output, "tee-output", "", "An output expression for the lines written to --tee (the lines as they are when not present)")
+	c.Flags().StringVar(&cfg.Match, "tee-match", "", "Only write lines matching this regular expression to --tee")
+}
+
+// withTee passes lines to lh, and also to the --tee file if one was requested
+//
+// The returned close func closes the file, and must be called once the lines are handled.
+func withTee(lh linehandler.LineHandler, cfg teeConfig) (linehandler.LineHandler, func() error, error) {
+	noop := func() error { return nil }
+
+	if cfg.Path == "" {
+		return lh, noop, nil
+	}
+
+	var expr *formatter.Expression
+	if cfg.Output != "" {
+		var err error
+		if expr, err = formatter.Compile(cfg.Output); err != nil {
+			return nil, noop, fmt.Errorf("bad --tee-output: %w", err)
+		}
+	}
+
+	var stages []linehandler.Middleware
+	if cfg.Match != "" {
+		re, err := regexp.Compile(cfg.Match)
+		if err != nil {
+			return nil, noop, fmt.Errorf("bad --tee-match: %w", err)
+		}
+
+		stages = append(stages, linehandler.Filter(func(rec *linehandler.Record) bool {
+			return re.MatchString(rec.Text())
+		}))
+	}
+
+	f, err := os.OpenFile(cfg.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, noop, err
+	}
+
+	sink := linehandler.NewLinePrinter(linehandler.Options{
+		Expression: expr,
+		Output:     f,
+	})
+
+	return linehandler.Tee(lh, linehandler.Chain(stages...)(sink)), f.Close, nil
+}
@@ -2,27 +2,24 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"sort"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/gsmcwhirter/go-util/v9/cli"
-	"github.com/tidwall/gjson"
-)
-
-var levelColors = map[string]func(string, ...interface{}) string{
-	"NONE": color.BlackString,
-	"DEBU": color.MagentaString,
-	"INFO": color.GreenString,
-	"WARN": color.YellowString,
-	"ERRO": color.RedString,
-}
 
-var autoFields = map[string]bool{
-	"caller": true,
-}
+	"github.com/gsmcwhirter/prettify/pkg/streams/dedupe"
+	"github.com/gsmcwhirter/prettify/pkg/streams/human"
+	"github.com/gsmcwhirter/prettify/pkg/streams/multiline"
+	"github.com/gsmcwhirter/prettify/pkg/streams/pipeline"
+	"github.com/gsmcwhirter/prettify/pkg/streams/redact"
+	"github.com/gsmcwhirter/prettify/pkg/streams/sampling"
+	"github.com/gsmcwhirter/prettify/pkg/theme"
+)
 
 type app struct {
 	cli             *cli.Command
@@ -38,6 +35,23 @@ type app struct {
 	skipStacks      bool
 	multilineTags   bool
 	multilineFields []string
+	rateLimit       int
+	rateInterval    time.Duration
+	rateKey         string
+	sampleRate      string
+	sampleKey       string
+	dedupe          bool
+	dedupeFields    []string
+	dedupeIgnore    []string
+	multilineConfig multiline.Options
+	multilineEvents bool
+	themeName       string
+	colorDepth      string
+	theme           *theme.Theme
+	redactConfig    redact.Config
+	redactor        *redact.Redactor
+	workers         int
+	batchSize       int
 }
 
 func (a *app) setup() *cli.Command {
@@ -56,6 +70,11 @@ func (a *app) setup() *cli.Command {
 		"Just seeing logs", fmt.Sprintf("my-cmd 2>&1 | %[1]s", AppName),
 		"Saving raw logs and seeing nice versions", fmt.Sprintf("my-cmd 2>&1 | tee -a real_data.log | %[1]s", AppName),
 		"Only seeing some fields (just message in this case)", fmt.Sprintf("my-cmd | %[1]s -O 'message'", AppName),
+		"Hiding tokens, emails and similar values (e.g. for pasting into a ticket)", fmt.Sprintf("my-cmd | %[1]s --redact", AppName),
+		"Showing at most 10 lines per message each second", fmt.Sprintf("my-cmd | %[1]s --rate-limit 10", AppName),
+		"Showing only 1%% of traces (all lines of a kept trace are shown)", fmt.Sprintf("my-cmd | %[1]s --sample 1%% --sample-key trace_id", AppName),
+		"Collapsing retry loops into one line, even as their attempt counter goes up", fmt.Sprintf("my-cmd | %[1]s --dedupe --dedupe-ignore attempt", AppName),
+		"Keeping the stack traces of a plain-text service together with their log lines", fmt.Sprintf("my-cmd 2>&1 | %[1]s --multiline", AppName),
 	)
 
 	c.SetRunFunc(a.run)
@@ -67,10 +86,32 @@ func (a *app) setup() *cli.Command {
 	c.Flags().StringSliceVarP(&a.exclude, "exclude", "E", nil, "A list of fields to exclude (none when not present; takes priority over everything else)")
 	c.Flags().StringSliceVarP(&a.multilineFields, "multiline-fields", "L", nil, "A list of fields with multiline content to be specially formatted")
 	c.Flags().BoolVarP(&a.forceColor, "color", "C", false, "Force color output (for less and similar pipes)")
+	c.Flags().StringVar(&a.themeName, "theme", theme.NameFromEnv(), fmt.Sprintf("The color theme (%s, or one defined in %s; defaults to $PRETTIFY_THEME)", strings.Join(theme.Names(), ", "), theme.DefaultConfigPath()))
+	c.Flags().StringVar(&a.colorDepth, "color-depth", "auto", "The number of colors the terminal supports (auto, 16, 256, truecolor)")
 	c.Flags().BoolVarP(&a.autoFields, "auto-fields", "A", false, "Include auto-generated tags from log lines (without, can still explicitly specify in -O)")
 	c.Flags().BoolVarP(&a.allStacks, "all-stacks", "s", false, "Include printing a stack trace for non-error lines where it is included")
 	c.Flags().BoolVarP(&a.skipStacks, "no-stacks", "S", false, "Skip printing a stack trace for lines where it is included")
 	c.Flags().BoolVarP(&a.multilineTags, "multiline-tags", "M", false, "Format tags each on their own line")
+	c.Flags().IntVar(&a.rateLimit, "rate-limit", 0, "Show at most this many similar lines per --rate-interval, then a suppression notice (unlimited when 0)")
+	c.Flags().DurationVar(&a.rateInterval, "rate-interval", time.Second, "The window for --rate-limit")
+	c.Flags().StringVar(&a.rateKey, "rate-key", "", "The field that makes lines similar for --rate-limit (the message field when not present)")
+	c.Flags().StringVar(&a.sampleRate, "sample", "", "Only show this fraction of --sample-key values, e.g. 1% (all when not present)")
+	c.Flags().StringVar(&a.sampleKey, "sample-key", "trace_id", "The field whose value decides whether a line is sampled (falls back to the whole line when missing)")
+	c.Flags().BoolVar(&a.dedupe, "dedupe", false, "Collapse runs of consecutive duplicate lines (ignoring their timestamps) into one, with a (×N, first..last) suffix")
+	c.Flags().StringSliceVar(&a.dedupeFields, "dedupe-fields", nil, "The fields that must match, along with the message field, for lines to be duplicates (all fields when not present)")
+	c.Flags().StringSliceVar(&a.dedupeIgnore, "dedupe-ignore", nil, "Volatile fields (like attempt counters) that may differ between duplicates")
+	c.Flags().BoolVar(&a.multilineEvents, "multiline", false, "Treat plain-text events spanning several lines (like stack traces) as one line, for sampling, rate limiting and printing")
+	c.Flags().StringSliceVar(&a.multilineConfig.Start, "multiline-start", nil, "Regular expressions for the lines that start an event (lines starting with a date or time, {, or a level keyword when not present)")
+	c.Flags().StringSliceVar(&a.multilineConfig.Continuation, "multiline-continue", nil, "Regular expressions for the lines that continue an event, even if they look like a start (indented lines, and ones starting with 'at ' or 'goroutine ' when not present)")
+	c.Flags().IntVar(&a.multilineConfig.MaxLines, "multiline-max-lines", multiline.DefaultMaxLines, "The most lines in an event, after which a new one is started")
+	c.Flags().BoolVar(&a.redactConfig.Enabled, "redact", false, "Replace secrets and personal data with "+redact.Placeholder)
+	c.Flags().BoolVar(&a.redactConfig.Pseudonymize, "pseudonymize", false, "Replace secrets and personal data with stable pseudonyms, so they can still be correlated")
+	c.Flags().StringSliceVar(&a.redactConfig.Fields, "redact-fields", redact.DefaultFieldPatterns, "Field name patterns whose values are hidden")
+	c.Flags().StringSliceVar(&a.redactConfig.Rules, "redact-rules", redact.RuleNames(), fmt.Sprintf("Built-in value patterns to hide wherever they appear (%s)", strings.Join(redact.RuleNames(), ", ")))
+	c.Flags().StringSliceVar(&a.redactConfig.Regexes, "redact-regex", nil, "Additional regular expressions for values to hide wherever they appear")
+	c.Flags().StringVar(&a.redactConfig.Key, "redact-key", os.Getenv("PRETTIFY_REDACT_KEY"), "The key for --pseudonymize; pseudonyms only match between runs with the same key (defaults to $PRETTIFY_REDACT_KEY, or a random key)")
+	c.Flags().IntVar(&a.workers, "workers", runtime.NumCPU(), "The number of goroutines formatting lines (output order is kept)")
+	c.Flags().IntVar(&a.batchSize, "batch-size", pipeline.DefaultBatchSize, "The most lines handed to a worker at once when catching up on input")
 
 	a.cli = c
 
@@ -78,145 +119,251 @@ func (a *app) setup() *cli.Command {
 }
 
 func (a *app) run(cmd *cli.Command, args []string) error {
-	if a.forceColor {
-		color.NoColor = false
+	color.NoColor = !theme.Enabled(a.forceColor, theme.IsTerminal(os.Stdout))
+
+	depth, err := theme.ParseDepth(a.colorDepth)
+	if err != nil {
+		return err
 	}
 
-	scanner := bufio.NewScanner(os.Stdin)
+	a.theme, err = theme.Load(a.themeName, theme.DefaultConfigPath(), depth)
+	if err != nil {
+		return err
+	}
 
-	var line string
-	var obj gjson.Result
-	var ts string
-	var level, levelText string
-	var message string
+	a.redactor, err = a.redactConfig.Redactor()
+	if err != nil {
+		return err
+	}
 
-	var lineKeys []string
-	var lineMap map[string]gjson.Result
+	var sampler *sampling.Sampler
+	if a.sampleRate != "" {
+		rate, err := sampling.ParseRate(a.sampleRate)
+		if err != nil {
+			return err
+		}
 
-	specialFields := map[string]bool{
-		a.messageField:   true,
-		a.timestampField: true,
-		a.levelField:     true,
-		a.stackField:     true,
+		s := sampling.NewSampler(rate)
+		sampler = &s
 	}
 
-	for _, f := range a.multilineFields {
-		specialFields[f] = true
+	var limiter *sampling.Limiter
+	if a.rateLimit > 0 {
+		limiter = sampling.NewLimiter(a.rateLimit, a.rateInterval, nil)
 	}
 
-	outputFields := map[string]bool{}
-	for _, oField := range a.output {
-		outputFields[oField] = true
+	rateKey := a.rateKey
+	if rateKey == "" {
+		rateKey = a.messageField
 	}
 
-	excludeFields := map[string]bool{}
-	for _, eField := range a.exclude {
-		excludeFields[eField] = true
-	}
+	renderer := human.NewRenderer(human.Options{
+		MessageField:    a.messageField,
+		TimestampField:  a.timestampField,
+		LevelField:      a.levelField,
+		StackField:      a.stackField,
+		Output:          a.output,
+		Exclude:         a.exclude,
+		MultilineFields: a.multilineFields,
+		AutoFields:      a.autoFields,
+		AllStacks:       a.allStacks,
+		SkipStacks:      a.skipStacks,
+		MultilineTags:   a.multilineTags,
+		Theme:           a.theme,
+	})
 
-	fill := " "
-	multilineFill := "\n\t"
-	if a.multilineTags {
-		fill = "\n\t"
-		multilineFill = "\n\t\t"
+	render := renderer.Render
+	if a.redactor != nil {
+		render = func(line string) string {
+			return renderer.Render(a.redactor.RedactLine(line))
+		}
 	}
 
-	for scanner.Scan() {
-		line = strings.TrimSpace(scanner.Text())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		lineKeys = make([]string, 0, len(lineKeys))
-		lineMap = map[string]gjson.Result{}
+	if a.batchSize <= 0 {
+		a.batchSize = pipeline.DefaultBatchSize
+	}
 
-		obj = gjson.Parse(line)
-		obj.ForEach(func(key, value gjson.Result) bool {
-			k := key.String()
-			if k == "" {
-				return true
-			}
+	items := make(chan pipeline.Item, a.batchSize)
+	readErr := make(chan error, 1)
+
+	var scanner lineScanner = bufio.NewScanner(os.Stdin)
+	if a.multilineEvents {
+		assembler, err := multiline.NewAssembler(a.multilineConfig)
+		if err != nil {
+			return err
+		}
+		scanner = newEventScanner(ctx, bufio.NewScanner(os.Stdin), assembler)
+	}
 
-			lineKeys = append(lineKeys, k)
-			lineMap[k] = value
-			return true // keep iterating
-		})
+	go func() {
+		readErr <- a.read(ctx, scanner, items, sampler, limiter, rateKey)
+	}()
+
+	var in <-chan pipeline.Item = items
+	if a.dedupe {
+		deduped := make(chan pipeline.Item, a.batchSize)
+		go a.dedupeItems(ctx, items, deduped, dedupe.NewDeduper(dedupe.Options{
+			MessageField:   a.messageField,
+			Fields:         a.dedupeFields,
+			IgnoreFields:   a.dedupeIgnore,
+			TimestampField: a.timestampField,
+		}))
+		in = deduped
+	}
 
-		sort.Strings(lineKeys)
+	out := bufio.NewWriter(os.Stdout)
 
-		if lineMap[a.timestampField].Exists() {
-			ts = lineMap[a.timestampField].String()
-		} else {
-			ts = ""
+	err = pipeline.Run(ctx, in, pipeline.Options{
+		Workers:   a.workers,
+		BatchSize: a.batchSize,
+		Render:    render,
+	}, func(line string) error {
+		if _, err := out.WriteString(line); err != nil {
+			return err
 		}
+		return out.WriteByte('\n')
+	}, out.Flush)
+	if err != nil {
+		return err
+	}
 
-		ts = color.HiBlackString(ts)
+	return <-readErr
+}
 
-		if lineMap[a.levelField].Exists() {
-			level = strings.ToUpper(lineMap[a.levelField].String())
-		} else {
-			level = "NONE"
-		}
+// idleDelay is how long the input must have nothing ready before an event or run of duplicates still being put together is passed along
+const idleDelay = 100 * time.Millisecond
+
+// lineScanner is where read gets its lines (a *bufio.Scanner, or an *eventScanner for --multiline)
+type lineScanner interface {
+	Scan() bool
+	Text() string
+	Err() error
+}
 
-		levelText = level[:4]
-		if levelColor, ok := levelColors[levelText]; ok {
-			levelText = levelColor("%s", levelText)
+// read scans the input lines, applying sampling and rate limiting (which depend on line order) before
+// handing them to the pipeline, and closes items at the end
+func (a *app) read(ctx context.Context, scanner lineScanner, items chan<- pipeline.Item, sampler *sampling.Sampler, limiter *sampling.Limiter, rateKey string) error {
+	defer close(items)
+
+	send := func(item pipeline.Item) bool {
+		select {
+		case items <- item:
+			return true
+		case <-ctx.Done():
+			return false
 		}
+	}
 
-		message = ""
-		if !strings.HasPrefix(line, "{") {
-			message = line
-		} else if lineMap[a.messageField].Exists() {
-			message = lineMap[a.messageField].String()
+	sendNotices := func(notices []sampling.Notice) bool {
+		for _, n := range notices {
+			if !send(pipeline.Item{Line: a.theme.Paint(a.theme.Notice, n.String()), Done: true}) {
+				return false
+			}
 		}
+		return true
+	}
 
-		fmt.Printf("%s |%s| %s", ts, levelText, message)
+	var line string
 
-		for _, key := range lineKeys {
-			if _, ok := specialFields[key]; ok {
-				continue
-			}
+	for scanner.Scan() {
+		line = strings.TrimSpace(scanner.Text())
 
-			if excludeFields[key] {
-				continue
-			}
+		if sampler != nil && !sampler.Keep(sampling.Key(line, a.sampleKey)) {
+			continue
+		}
 
-			if len(a.output) > 0 && !outputFields[key] { // only display requested fields
-				continue
+		if limiter != nil {
+			allowed, notices := limiter.Allow(sampling.Key(line, rateKey))
+			if !sendNotices(notices) {
+				return ctx.Err()
 			}
 
-			if !a.autoFields && autoFields[key] && (len(a.output) == 0 || outputFields[key]) { // get rid of any non-requested auto-fields, unless --auto-fields
+			if !allowed {
 				continue
 			}
+		}
 
-			fmt.Printf("%s%s=%s", fill, color.CyanString(key), lineMap[key].String())
+		if !send(pipeline.Item{Line: line}) {
+			return ctx.Err()
 		}
+	}
 
-		for _, mlf := range a.multilineFields {
-			if !lineMap[mlf].Exists() {
-				continue
-			}
+	if limiter != nil && !sendNotices(limiter.Flush()) {
+		return ctx.Err()
+	}
+
+	return scanner.Err()
+}
 
-			fld := lineMap[mlf].String()
-			lines := strings.Split(strings.TrimSpace(fld), "\n")
+// dedupeItems collapses runs of duplicate lines from in before passing them along to out, and closes out at the end
+//
+// The run being collapsed is passed along once in has had nothing ready for idleDelay, so it is not held back while the input is idle
+func (a *app) dedupeItems(ctx context.Context, in <-chan pipeline.Item, out chan<- pipeline.Item, d *dedupe.Deduper) {
+	defer close(out)
+
+	send := func(item pipeline.Item) bool {
+		select {
+		case out <- item:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	sendRun := func(run dedupe.Run, ok bool) bool {
+		if !ok {
+			return true
+		}
 
-			fmt.Printf("%s%s=%s%s", "\n\t", color.CyanString(mlf), multilineFill, strings.Join(lines, multilineFill))
+		item := pipeline.Item{Line: run.Line}
+		if suffix := run.Suffix(); suffix != "" {
+			item.Suffix = a.theme.Paint(a.theme.Notice, suffix)
 		}
 
-		if lineMap[a.stackField].Exists() && !a.skipStacks && (a.allStacks || level == "ERROR") {
-			rawLines := lineMap[a.stackField].Array()
-			lines := make([]string, 0, len(rawLines))
+		return send(item)
+	}
+
+	for {
+		var item pipeline.Item
+		var ok bool
 
-			for _, l := range rawLines {
-				lines = append(lines, l.String())
+		select {
+		case item, ok = <-in:
+		default:
+			var idle <-chan time.Time
+			if d.Pending() > 0 {
+				idle = time.After(idleDelay)
 			}
 
-			fmt.Printf("%s%s=%s%s", "\n\t", color.CyanString(a.stackField), multilineFill, strings.Join(lines, multilineFill))
+			select {
+			case item, ok = <-in:
+			case <-idle:
+				if !sendRun(d.Flush()) {
+					return
+				}
+				continue
+			case <-ctx.Done():
+				return
+			}
 		}
 
-		fmt.Println()
-	}
+		if !ok {
+			sendRun(d.Flush())
+			return
+		}
 
-	if scanner.Err() != nil {
-		return scanner.Err()
-	}
+		if item.Done { // notices are not deduped, but still come after the lines before them
+			if !sendRun(d.Flush()) || !send(item) {
+				return
+			}
+			continue
+		}
 
-	return nil
+		if !sendRun(d.Add(item.Line)) {
+			return
+		}
+	}
 }
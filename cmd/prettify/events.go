@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"time"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/multiline"
+)
+
+// eventScanner is a lineScanner that assembles the lines of multi-line events into one
+//
+// The lines are scanned on their own goroutine, so an event still being assembled can be passed along
+// once the input has had nothing ready for idleDelay
+type eventScanner struct {
+	lines     <-chan string
+	err       error
+	assembler *multiline.Assembler
+	text      string
+}
+
+// newEventScanner starts scanning the lines of scanner, until they end or ctx is done
+func newEventScanner(ctx context.Context, scanner *bufio.Scanner, assembler *multiline.Assembler) *eventScanner {
+	lines := make(chan string, 256)
+	s := &eventScanner{lines: lines, assembler: assembler}
+
+	go func() {
+		defer close(lines)
+
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text() + "\n":
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		s.err = scanner.Err() // only read once lines is closed
+	}()
+
+	return s
+}
+
+// Scan advances to the next event, returning false at the end of the input
+func (s *eventScanner) Scan() bool {
+	for {
+		var line string
+		var ok bool
+
+		select {
+		case line, ok = <-s.lines:
+		default:
+			var idle <-chan time.Time
+			if s.assembler.Pending() > 0 {
+				idle = time.After(idleDelay)
+			}
+
+			select {
+			case line, ok = <-s.lines:
+			case <-idle:
+				s.text, ok = s.assembler.Flush()
+				return ok
+			}
+		}
+
+		if !ok {
+			s.text, ok = s.assembler.Flush()
+			return ok
+		}
+
+		if s.text, ok = s.assembler.Add(line); ok {
+			return true
+		}
+	}
+}
+
+// Text is the current event, with its lines separated by newlines
+func (s *eventScanner) Text() string {
+	return s.text
+}
+
+// Err is the first error scanning the input (nil at the end of it)
+func (s *eventScanner) Err() error {
+	return s.err
+}
@@ -1,10 +1,13 @@
 module github.com/gsmcwhirter/prettify
 
-go 1.17
+// 1.21 for log/slog (see pkg/streams/human/slog.go), and 1.22 for github.com/klauspost/compress v1.18.0
+go 1.22
 
 require (
 	github.com/fatih/color v1.13.0
 	github.com/gsmcwhirter/go-util/v9 v9.1.0
+	github.com/klauspost/compress v1.18.0
+	github.com/mattn/go-isatty v0.0.14
 	github.com/tidwall/gjson v1.12.1
 	github.com/tidwall/pretty v1.2.0
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
@@ -13,7 +16,6 @@ require (
 require (
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/mattn/go-colorable v0.1.12 // indirect
-	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/spf13/cobra v1.3.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
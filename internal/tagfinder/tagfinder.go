@@ -2,19 +2,18 @@ package tagfinder
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"os"
+	"path/filepath"
 	"sort"
 
 	"github.com/tidwall/gjson"
 
-	"github.com/gsmcwhirter/go-util/v9/deferutil"
+	"github.com/gsmcwhirter/prettify/pkg/files/streamer"
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
 )
 
-func init() {
-	gjson.DisableModifiers = true
-}
-
 // TagFinder handles reading files as they are iterated through by a directory walker
 // and finding the tags that exist
 type TagFinder struct {
@@ -46,8 +45,8 @@ func (tf *TagFinder) Tags() []string {
 	return tags
 }
 
-func extractTag(line string) (string, error) {
-	res := gjson.Get(line, "@tag")
+func extractTag(rec *linehandler.Record) (string, error) {
+	res := rec.Get("@tag")
 	if !res.Exists() {
 		return "", errors.New("no @tag found")
 	} else if res.Type != gjson.String {
@@ -57,35 +56,34 @@ func extractTag(line string) (string, error) {
 	return res.String(), nil
 }
 
+// errSampled stops reading a file once enough of its lines have been seen
+var errSampled = errors.New("sampled enough lines")
+
 // Walker handles reading files and finding tags as they are iterated through by a directory walker
 func (tf *TagFinder) Walker(path string, info os.FileInfo, err error) error {
 	if err != nil {
 		return err
 	}
 
-	file, err := os.Open(path)
-	if err != nil {
-		return err
-	}
-	defer deferutil.CheckDefer(file.Close)
-
-	scanner := bufio.NewScanner(file)
 	var lineCt uint
-	for scanner.Scan() {
-		line := scanner.Text()
+	dirName, fileName := filepath.Split(path)
+
+	_, err = streamer.Cat(context.Background(), dirName, fileName, linehandler.HandlerFunc(func(rec *linehandler.Record) error {
 		lineCt++
 
-		tag, err := extractTag(line)
-		if err != nil {
-			continue
+		if tag, err := extractTag(rec); err == nil {
+			tf.tags[tag] = true
 		}
 
-		tf.tags[tag] = true
-
 		if !tf.findAll && lineCt >= tf.sampleSize {
-			break
+			return errSampled
 		}
+
+		return nil
+	}))
+	if errors.Is(err, errSampled) || errors.Is(err, bufio.ErrTooLong) { // a line too long to read ends the file's sample
+		return nil
 	}
 
-	return nil
+	return err
 }
@@ -3,10 +3,14 @@ package tagfinder
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/gsmcwhirter/prettify/pkg/files/streamer"
 	"github.com/gsmcwhirter/prettify/pkg/pathutil"
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
 )
 
 var testDataDir = "../../pkg/files/testdata"
@@ -134,7 +138,7 @@ func Test_extractTag(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := extractTag(tt.args.line)
+			got, err := extractTag(&linehandler.Record{Line: tt.args.line})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("extractTag() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -230,3 +234,21 @@ func TestTagFinder_Walker(t *testing.T) {
 		})
 	}
 }
+
+func TestTagFinder_Walker_longLine(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "test-out.log")
+	data := `{"@tag": "before"}` + "\n" + strings.Repeat("x", 2*streamer.MaxLineSizeBytes) + "\n" + `{"@tag": "after"}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+
+	tf := NewTagFinder(10, true)
+	if err := tf.Walker(path, nil, nil); err != nil {
+		t.Errorf("Walker() error = %v, want nil", err)
+	}
+
+	if got, want := tf.Tags(), []string{"before"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Walker() tags = %v, want %v", got, want)
+	}
+}
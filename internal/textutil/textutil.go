@@ -0,0 +1,43 @@
+// Package textutil contains small allocation-free helpers for turning bytes and json values into text,
+// shared by the formatter and human renderer
+package textutil
+
+import (
+	"strconv"
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// BytesToString views b as a string without copying; the string must not outlive any change to b
+func BytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// AppendScalar appends the same text as res.String() (for a number, boolean, null or string), without allocating where possible
+func AppendScalar(dst []byte, res gjson.Result) []byte {
+	if res.Type == gjson.Number && res.Raw != "" && !isInteger(res.Raw) {
+		return strconv.AppendFloat(dst, res.Num, 'f', -1, 64)
+	}
+
+	return append(dst, res.String()...)
+}
+
+func isInteger(raw string) bool {
+	i := 0
+	if raw[0] == '-' {
+		i++
+	}
+
+	for ; i < len(raw); i++ {
+		if raw[i] < '0' || raw[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,44 @@
+package textutil
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestBytesToString(t *testing.T) {
+	t.Parallel()
+	if got := BytesToString([]byte("testing")); got != "testing" {
+		t.Errorf("BytesToString() = %q, want %q", got, "testing")
+	}
+	if got := BytesToString(nil); got != "" {
+		t.Errorf("BytesToString(nil) = %q, want empty", got)
+	}
+}
+
+func TestAppendScalar(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		json string
+	}{
+		{name: "integer", json: `12`},
+		{name: "negative integer", json: `-12`},
+		{name: "big integer", json: `12345678901234567890`},
+		{name: "float", json: `1.50`},
+		{name: "exponent", json: `1e3`},
+		{name: "string", json: `"testing"`},
+		{name: "true", json: `true`},
+		{name: "null", json: `null`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			res := gjson.Parse(tt.json)
+			if got, want := string(AppendScalar([]byte("> "), res)), "> "+res.String(); got != want {
+				t.Errorf("AppendScalar() = %q, want %q", got, want)
+			}
+		})
+	}
+}
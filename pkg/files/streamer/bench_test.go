@@ -0,0 +1,98 @@
+package streamer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gsmcwhirter/prettify/pkg/minmax"
+	"github.com/gsmcwhirter/prettify/pkg/testutil"
+)
+
+// readLineBeforeUnbuffered is how lines used to be read backwards (for comparison): reading up to MaxLineSizeBytes
+// before the position for every line
+func readLineBeforeUnbuffered(file io.ReadSeeker, lineBuffer []byte) ([]byte, int64, error) {
+	pos, err := file.Seek(0, io.SeekCurrent)
+	if err != nil || pos == 0 {
+		return []byte{}, pos, err
+	}
+
+	readTarget := minmax.Int64Max(pos-MaxLineSizeBytes, 0)
+	if _, err = file.Seek(readTarget, io.SeekStart); err != nil {
+		return []byte{}, pos, err
+	}
+
+	numBytes, err := file.Read(lineBuffer)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return []byte{}, pos, err
+	}
+	numBytes = minmax.IntMin(numBytes, int(pos-readTarget))
+
+	i := bytes.LastIndexByte(lineBuffer[:numBytes-1], NewlineByte) + 1
+	pos = readTarget + int64(i)
+	_, err = file.Seek(pos, io.SeekStart)
+
+	return lineBuffer[i:numBytes], pos, err
+}
+
+func BenchmarkReader_ReadLineBefore(b *testing.B) {
+	readers := []struct {
+		name string
+		read func(file io.ReadSeeker) func() ([]byte, int64, error)
+	}{
+		{
+			name: "blocks",
+			read: func(file io.ReadSeeker) func() ([]byte, int64, error) {
+				return NewReader(file).ReadLineBefore
+			},
+		},
+		{
+			name: "unbuffered",
+			read: func(file io.ReadSeeker) func() ([]byte, int64, error) {
+				lineBuffer := make([]byte, MaxLineSizeBytes+1)
+				return func() ([]byte, int64, error) { return readLineBeforeUnbuffered(file, lineBuffer) }
+			},
+		},
+	}
+
+	for _, c := range testutil.LogCorpora(10000) {
+		c := c
+		fname := filepath.Join(b.TempDir(), c.Name+".log")
+		if err := os.WriteFile(fname, append(bytes.Join(c.Lines, []byte("\n")), '\n'), 0o600); err != nil {
+			b.Fatalf("could not write test file: %v", err)
+		}
+
+		for _, r := range readers {
+			r := r
+			b.Run(c.Name+"/"+r.name, func(b *testing.B) {
+				file, err := os.Open(fname)
+				if err != nil {
+					b.Fatalf("could not open test file: %v", err)
+				}
+				defer file.Close() //nolint:errcheck // read only
+
+				b.SetBytes(c.AverageLineSize() + 1)
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				var read func() ([]byte, int64, error)
+				var pos int64
+				for i := 0; i < b.N; i++ {
+					if pos == 0 {
+						if pos, err = skipToEnd(file); err != nil {
+							b.Fatalf("skipToEnd() error = %v", err)
+						}
+						read = r.read(file)
+					}
+
+					if _, pos, err = read(); err != nil {
+						b.Fatalf("ReadLineBefore() error = %v", err)
+					}
+				}
+			})
+		}
+	}
+}
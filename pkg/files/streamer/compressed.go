@@ -0,0 +1,212 @@
+package streamer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gsmcwhirter/go-util/v9/deferutil"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/gsmcwhirter/prettify/pkg/minmax"
+)
+
+// Compression is a format a log file can be compressed with
+type Compression int
+
+// The compression formats that are read transparently
+const (
+	Uncompressed Compression = iota
+	Gzip
+	Bzip2
+	Zstd
+)
+
+// spillLimit is the most bytes a compressed file may decompress to, to be read backwards (see openSeekable)
+var spillLimit int64 = 4 * 1024 * 1024 * 1024 // 4 GB
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+	// a bzip2 stream goes on (after its magic and a block size digit) with a block, or ends right away when empty
+	bzip2BlockMagic = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2EndMagic   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// bzip2HeaderSize is how many bytes isBzip2 looks at
+var bzip2HeaderSize = len(bzip2Magic) + 1 + len(bzip2BlockMagic)
+
+// String is the usual name of the format
+func (c Compression) String() string {
+	switch c {
+	case Gzip:
+		return "gzip"
+	case Bzip2:
+		return "bzip2"
+	case Zstd:
+		return "zstd"
+	default:
+		return "uncompressed"
+	}
+}
+
+// DetectCompression tells the format of file from its first bytes, leaving it at the start
+func DetectCompression(file io.ReadSeeker) (Compression, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Uncompressed, err
+	}
+
+	magic := make([]byte, bzip2HeaderSize)
+	n, err := io.ReadFull(file, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Uncompressed, err
+	}
+	magic = magic[:n]
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Uncompressed, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return Gzip, nil
+	case isBzip2(magic):
+		return Bzip2, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		return Zstd, nil
+	default:
+		return Uncompressed, nil
+	}
+}
+
+// isBzip2 tells if magic is the start of a bzip2 stream; "BZh" alone is too likely to start a line of text
+func isBzip2(magic []byte) bool {
+	if len(magic) < bzip2HeaderSize || !bytes.HasPrefix(magic, bzip2Magic) {
+		return false
+	}
+
+	if level := magic[len(bzip2Magic)]; level < '1' || level > '9' {
+		return false
+	}
+
+	rest := magic[len(bzip2Magic)+1:]
+
+	return bytes.HasPrefix(rest, bzip2BlockMagic) || bytes.HasPrefix(rest, bzip2EndMagic)
+}
+
+// decompress wraps r in a reader of the decompressed bytes of format c
+//
+// The returned close func releases the decompressor, and must be called once done with the reader.
+func decompress(r io.Reader, c Compression) (io.Reader, func() error, error) {
+	noop := func() error { return nil }
+
+	switch c {
+	case Gzip:
+		gz, err := gzip.NewReader(bufio.NewReader(r))
+		if err != nil {
+			return nil, noop, err
+		}
+		return gz, gz.Close, nil
+	case Bzip2:
+		return bzip2.NewReader(bufio.NewReader(r)), noop, nil
+	case Zstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, noop, err
+		}
+		return zr, func() error { zr.Close(); return nil }, nil
+	default:
+		return r, noop, nil
+	}
+}
+
+// skipBytes reads past the first n bytes of r (which cannot seek), returning the number of newlines in them
+func skipBytes(r io.Reader, n int64) (int, error) {
+	buffer := make([]byte, 64*1024)
+	count := 0
+
+	for n > 0 {
+		read, err := r.Read(buffer[:minmax.Int64Min(int64(len(buffer)), n)])
+		count += bytes.Count(buffer[:read], []byte{NewlineByte})
+		n -= int64(read)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// openSeekable is openSeekableFile (a var so tests can tell how often files are opened)
+var openSeekable = openSeekableFile
+
+// openSeekableFile opens a file to be read backwards (or from its end), like os.Open
+//
+// Compressed files cannot seek, so they are decompressed to a temporary file (of at most spillLimit bytes) instead,
+// and positions in them are those in the decompressed bytes. The returned close func closes the file, and removes
+// any temporary one.
+func openSeekableFile(path string) (io.ReadSeeker, func() error, error) {
+	noop := func() error { return nil }
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, noop, err
+	}
+
+	compression, err := DetectCompression(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, noop, err
+	}
+
+	if compression == Uncompressed {
+		return file, file.Close, nil
+	}
+	defer deferutil.CheckDefer(file.Close)
+
+	r, closeReader, err := decompress(file, compression)
+	if err != nil {
+		return nil, noop, fmt.Errorf("could not read %s as %s: %w", path, compression, err)
+	}
+	defer deferutil.CheckDefer(closeReader)
+
+	spill, err := os.CreateTemp("", "prettify-spill-*")
+	if err != nil {
+		return nil, noop, err
+	}
+
+	remove := func() error {
+		closeErr := spill.Close()
+		if err := os.Remove(spill.Name()); err != nil {
+			return err
+		}
+		return closeErr
+	}
+
+	n, err := io.Copy(spill, io.LimitReader(r, spillLimit+1))
+	if err == nil && n > spillLimit {
+		err = fmt.Errorf("%s is more than %d bytes decompressed, which is too much to read backwards", path, spillLimit)
+	}
+
+	if err == nil {
+		_, err = spill.Seek(0, io.SeekStart)
+	}
+
+	if err != nil {
+		_ = remove()
+		return nil, noop, fmt.Errorf("could not decompress %s: %w", path, err)
+	}
+
+	return spill, remove, nil
+}
@@ -0,0 +1,358 @@
+package streamer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/testutil"
+)
+
+const compressedTestData = "testing 1\ntesting 2\ntesting 3\n"
+
+// bzip2TestData is compressedTestData compressed with bzip2 (which the standard library cannot write)
+var bzip2TestData = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xa3, 0x52, 0xd0, 0x33, 0x00, 0x00,
+	0x0c, 0x59, 0x80, 0x00, 0x10, 0x40, 0x00, 0x38, 0x00, 0x02, 0xa1, 0x0c, 0x00, 0x20, 0x00, 0x22,
+	0x3d, 0x53, 0x01, 0xa4, 0x20, 0x1a, 0x69, 0xa2, 0x62, 0x6c, 0xa3, 0x84, 0x78, 0x42, 0x11, 0xc7,
+	0xc5, 0xdc, 0x91, 0x4e, 0x14, 0x24, 0x28, 0xd4, 0xb4, 0x0c, 0xc0,
+}
+
+func compressTestData(t *testing.T, c Compression) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	switch c {
+	case Gzip:
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write([]byte(compressedTestData)); err != nil {
+			t.Fatalf("could not gzip: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("could not gzip: %v", err)
+		}
+	case Bzip2:
+		buf.Write(bzip2TestData)
+	case Zstd:
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("could not zstd: %v", err)
+		}
+		if _, err := w.Write([]byte(compressedTestData)); err != nil {
+			t.Fatalf("could not zstd: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("could not zstd: %v", err)
+		}
+	default:
+		buf.WriteString(compressedTestData)
+	}
+
+	return buf.Bytes()
+}
+
+func TestDetectCompression(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data []byte
+		want Compression
+	}{
+		{name: "empty", data: nil, want: Uncompressed},
+		{name: "short", data: []byte("a"), want: Uncompressed},
+		{name: "text", data: []byte(compressedTestData), want: Uncompressed},
+		{name: "gzip", data: []byte{0x1f, 0x8b, 0x08, 0x00}, want: Gzip},
+		{name: "bzip2", data: bzip2TestData, want: Bzip2},
+		{name: "empty bzip2", data: []byte{'B', 'Z', 'h', '9', 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0, 0, 0, 0}, want: Bzip2},
+		{name: "text starting with BZh", data: []byte("BZh9 looks like bzip2, but is not\n"), want: Uncompressed},
+		{name: "short text starting with BZh", data: []byte("BZh\n"), want: Uncompressed},
+		{name: "zstd", data: []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, want: Zstd},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			file := testutil.NewReadSeeker(tt.data)
+
+			got, err := DetectCompression(file)
+			if err != nil {
+				t.Fatalf("DetectCompression() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("DetectCompression() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatFrom_compressed(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		compression Compression
+		pos         int64
+		want        []linehandler.Record
+	}{
+		{
+			name:        "gzip",
+			compression: Gzip,
+			want: []linehandler.Record{
+				{Line: "testing 1\n", Number: 1, Offset: 0},
+				{Line: "testing 2\n", Number: 2, Offset: 10},
+				{Line: "testing 3\n", Number: 3, Offset: 20},
+			},
+		},
+		{
+			name:        "gzip from a position",
+			compression: Gzip,
+			pos:         10,
+			want: []linehandler.Record{
+				{Line: "testing 2\n", Number: 2, Offset: 10},
+				{Line: "testing 3\n", Number: 3, Offset: 20},
+			},
+		},
+		{
+			name:        "bzip2",
+			compression: Bzip2,
+			pos:         20,
+			want: []linehandler.Record{
+				{Line: "testing 3\n", Number: 3, Offset: 20},
+			},
+		},
+		{
+			name:        "zstd",
+			compression: Zstd,
+			want: []linehandler.Record{
+				{Line: "testing 1\n", Number: 1, Offset: 0},
+				{Line: "testing 2\n", Number: 2, Offset: 10},
+				{Line: "testing 3\n", Number: 3, Offset: 20},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir() + "/"
+			if err := os.WriteFile(filepath.Join(dir, "test.log.gz"), compressTestData(t, tt.compression), 0o600); err != nil {
+				t.Fatalf("could not write test file: %v", err)
+			}
+
+			var got []linehandler.Record
+			lh := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+				got = append(got, linehandler.Record{Line: rec.Line, Number: rec.Number, Offset: rec.Offset})
+				return nil
+			})
+
+			pos, err := CatFrom(context.Background(), dir, "test.log.gz", tt.pos, lh)
+			if err != nil {
+				t.Fatalf("CatFrom() error = %v", err)
+			}
+
+			if want := int64(len(compressedTestData)); pos != want {
+				t.Errorf("CatFrom() = %v, want %v", pos, want)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CatFrom() records = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatFrom_corrupt(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir() + "/"
+	if err := os.WriteFile(filepath.Join(dir, "test.log.gz"), []byte{0x1f, 0x8b, 0x08, 0x00, 0x01, 0x02}, 0o600); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+
+	if _, err := Cat(context.Background(), dir, "test.log.gz", linehandler.Discard); err == nil {
+		t.Errorf("Cat() error = nil, want one")
+	}
+}
+
+func TestCat_bzip2LookingText(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir() + "/"
+	data := "BZh9 is the bzip2 magic\nand this is text\n"
+	if err := os.WriteFile(filepath.Join(dir, "test.log"), []byte(data), 0o600); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+
+	var got []string
+	lh := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+		got = append(got, rec.Line)
+		return nil
+	})
+
+	if _, err := Cat(context.Background(), dir, "test.log", lh); err != nil {
+		t.Fatalf("Cat() error = %v", err)
+	}
+
+	if want := []string{"BZh9 is the bzip2 magic\n", "and this is text\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Cat() lines = %q, want %q", got, want)
+	}
+}
+
+func TestTac_compressed(t *testing.T) {
+	t.Parallel()
+	for _, c := range []Compression{Gzip, Bzip2, Zstd} {
+		c := c
+		t.Run(c.String(), func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir() + "/"
+			if err := os.WriteFile(filepath.Join(dir, "test.log.gz"), compressTestData(t, c), 0o600); err != nil {
+				t.Fatalf("could not write test file: %v", err)
+			}
+
+			var got []linehandler.Record
+			lh := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+				got = append(got, linehandler.Record{Line: rec.Line, Number: rec.Number, Offset: rec.Offset})
+				return nil
+			})
+
+			if _, err := Tac(context.Background(), dir, "test.log.gz", lh); err != nil {
+				t.Fatalf("Tac() error = %v", err)
+			}
+
+			want := []linehandler.Record{
+				{Line: "testing 3\n", Number: 3, Offset: 20},
+				{Line: "testing 2\n", Number: 2, Offset: 10},
+				{Line: "testing 1\n", Number: 1, Offset: 0},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Tac() records = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestTailFiles_compressed(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	older := filepath.Join(dir, "test-out-1.log.gz")
+	newer := filepath.Join(dir, "test-out.log")
+
+	if err := os.WriteFile(older, compressTestData(t, Gzip), 0o600); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+	if err := os.WriteFile(newer, []byte("testing 4\n"), 0o600); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+
+	var got []string
+	lh := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+		got = append(got, filepath.Base(rec.Path)+":"+rec.Line)
+		return nil
+	})
+
+	pos, err := TailFiles(context.Background(), []string{older, newer}, -3, lh)
+	if err != nil {
+		t.Fatalf("TailFiles() error = %v", err)
+	}
+
+	if pos != 10 {
+		t.Errorf("TailFiles() = %v, want %v", pos, 10)
+	}
+
+	want := []string{"test-out-1.log.gz:testing 2\n", "test-out-1.log.gz:testing 3\n", "test-out.log:testing 4\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TailFiles() lines = %q, want %q", got, want)
+	}
+}
+
+func TestTailFiles_decompressesOnce(t *testing.T) { // not parallel, as it changes openSeekable
+	dir := t.TempDir()
+	older := filepath.Join(dir, "test-out-1.log.gz")
+	newer := filepath.Join(dir, "test-out.log.zst")
+
+	if err := os.WriteFile(older, compressTestData(t, Gzip), 0o600); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+	if err := os.WriteFile(newer, compressTestData(t, Zstd), 0o600); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+
+	opened := map[string]int{}
+	defer func(open func(string) (io.ReadSeeker, func() error, error)) { openSeekable = open }(openSeekable)
+	openSeekable = func(path string) (io.ReadSeeker, func() error, error) {
+		opened[filepath.Base(path)]++
+		return openSeekableFile(path)
+	}
+
+	var got []string
+	lh := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+		got = append(got, filepath.Base(rec.Path)+":"+rec.Line)
+		return nil
+	})
+
+	if _, err := TailFiles(context.Background(), []string{older, newer}, -4, lh); err != nil {
+		t.Fatalf("TailFiles() error = %v", err)
+	}
+
+	want := []string{"test-out-1.log.gz:testing 3\n", "test-out.log.zst:testing 1\n", "test-out.log.zst:testing 2\n", "test-out.log.zst:testing 3\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TailFiles() lines = %q, want %q", got, want)
+	}
+
+	if wantOpened := map[string]int{"test-out-1.log.gz": 1, "test-out.log.zst": 1}; !reflect.DeepEqual(opened, wantOpened) {
+		t.Errorf("TailFiles() opened files %v times, want %v", opened, wantOpened)
+	}
+}
+
+func Test_openSeekableFile_unreadable(t *testing.T) {
+	t.Parallel()
+	file, closeFile, err := openSeekableFile(t.TempDir()) // a directory opens, but cannot be read
+	if err == nil {
+		t.Fatal("openSeekableFile() error = nil, want one for a directory")
+	}
+
+	if file != nil {
+		t.Errorf("openSeekableFile() file = %v, want nil (closed) with the error", file)
+	}
+
+	if err := closeFile(); err != nil {
+		t.Errorf("openSeekableFile() close func error = %v, want a no-op", err)
+	}
+}
+
+func Test_openSeekable_limit(t *testing.T) { // not parallel, as it changes spillLimit
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log.gz")
+	if err := os.WriteFile(path, compressTestData(t, Gzip), 0o600); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+
+	defer func(limit int64) { spillLimit = limit }(spillLimit)
+	spillLimit = int64(len(compressedTestData)) - 1
+
+	if _, _, err := openSeekable(path); err == nil {
+		t.Errorf("openSeekable() error = nil, want one for a file over the limit")
+	}
+
+	spillLimit = int64(len(compressedTestData))
+
+	file, closeFile, err := openSeekable(path)
+	if err != nil {
+		t.Fatalf("openSeekable() error = %v", err)
+	}
+
+	end, err := file.Seek(0, io.SeekEnd)
+	if err != nil || end != int64(len(compressedTestData)) {
+		t.Errorf("openSeekable() file ends at %v (err = %v), want %v", end, err, len(compressedTestData))
+	}
+
+	if err := closeFile(); err != nil {
+		t.Errorf("close error = %v", err)
+	}
+}
@@ -8,8 +8,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/gsmcwhirter/go-util/v9/deferutil"
 
@@ -21,15 +19,12 @@ import (
 const NewlineByte = byte('\n')
 
 // MaxLineSizeBytes is a constant used for setting a file scanning buffer size
-// We allow 1MB lines at maximum. Lines that are longer will be discarded / cause errors
+// We allow 1MB lines at maximum when reading forwards. Lines that are longer will be discarded / cause errors
+// (bufio.ErrTooLong); reading backwards (as Tac does) has no limit
 const (
 	MaxLineSizeBytes = 1 * 1024 * 1024 // 1 MB
 	searchBufferSize = 64 * 1024       // 64 KB
-)
-
-var (
-	searchBuffer = make([]byte, searchBufferSize)
-	lineBuffer   = make([]byte, MaxLineSizeBytes+1)
+	reverseBlockSize = 64 * 1024       // 64 KB
 )
 
 func skipToEnd(file io.Seeker) (int64, error) {
@@ -49,323 +44,140 @@ func peekEndPos(file io.Seeker) (int64, error) {
 	return endPos, err
 }
 
-func reverseOneLine(file io.ReadSeeker) (pos int64, err error) {
-	pos, err = file.Seek(0, io.SeekCurrent)
-	if pos == 0 || err != nil {
-		return pos, err
-	}
-
-	// assume we are at the start of a line. back up one character over the newline
-	pos, err = file.Seek(-1, io.SeekCurrent)
-	if err != nil {
-		return pos, err
+// dropCR drops a terminal \r from the data.
+func dropCR(data []byte) []byte {
+	if len(data) > 0 && data[len(data)-1] == '\r' {
+		return data[0 : len(data)-1]
 	}
+	return data
+}
 
-	// debug print
-	// fmt.Printf("step back pos %d\n", pos)
-
-	var bytesRead int
-	var seekBack int64
-	for pos > 0 {
-		seekBack = minmax.Int64Min(pos, searchBufferSize)
-
-		// jump back to read a bit
-		pos, err = file.Seek(-seekBack, io.SeekCurrent)
-		if err != nil {
-			return pos, err
-		}
-
-		// debug print
-		// fmt.Printf("read at pos %d\n", pos)
-
-		bytesRead, err = file.Read(searchBuffer)
-		if err != nil && !errors.Is(err, io.EOF) {
-			return pos, err
-		}
-
-		// debug print
-		// fmt.Printf("read buffer %v\n", searchBuffer[:bytesRead])
-
-		if bytesRead == 0 {
-			return pos, nil
-		}
-
-		for i := minmax.IntMin(int(seekBack), bytesRead) - 1; i >= 0; i-- {
-			if searchBuffer[i] == NewlineByte {
-				return file.Seek(pos+int64(i)+1, io.SeekStart)
-			}
-		}
-
-		// debug block
-		// pos, err = file.Seek(0, io.SeekCurrent)
-		// if err != nil {
-		//	return
-		// }
-		// fmt.Printf("curr pos %d\n", pos)
-
-		pos, err = file.Seek(-int64(bytesRead), io.SeekCurrent)
-		if err != nil {
-			return pos, err
-		}
+// ScanLines is a split function for a Scanner that returns each line of
+// text, stripped of any trailing end-of-line marker. The returned line may
+// be empty. The end-of-line marker is one optional carriage return followed
+// by one mandatory newline. In regular expression notation, it is `\r?\n`.
+// The last non-empty line of input will be returned even if it has no
+// newline.
+func scanLinesWithNewline(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
 	}
 
-	return pos, nil
-}
-
-func forwardOneLine(file io.ReadSeeker) (int64, error) {
-	pos, err := file.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return pos, err
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		// We have a full newline-terminated line.
+		return i + 1, append(dropCR(data[0:i]), 10), nil
 	}
 
-	endPos, fErr := peekEndPos(file)
-	if fErr != nil {
-		return pos, fErr
+	// If we're at EOF, we have a final, non-terminated line. Return it.
+	if atEOF {
+		return len(data), dropCR(data), nil
 	}
 
-	// debug print
-	// fmt.Printf("start pos %d\n", pos)
+	// Request more data.
+	return 0, nil, nil
+}
 
-	if pos == endPos {
-		return pos, nil
+// countNewlines counts the newlines in file before pos, leaving the file at pos
+func countNewlines(file io.ReadSeeker, pos int64) (int, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
 	}
 
-	for pos < endPos {
-		// read the character we are at
-		// debug print
-		// fmt.Printf("read at pos %d\n", pos)
+	buffer := make([]byte, 64*1024)
+	count := 0
 
-		bytesRead, readErr := file.Read(searchBuffer)
-		if readErr != nil && !errors.Is(readErr, io.EOF) {
-			return pos, readErr
-		}
+	for read := int64(0); read < pos; {
+		n, err := file.Read(buffer[:minmax.Int64Min(int64(len(buffer)), pos-read)])
+		count += bytes.Count(buffer[:n], []byte{'\n'})
+		read += int64(n)
 
-		if bytesRead == 0 {
-			return pos, nil
+		if err == io.EOF {
+			break
 		}
 
-		// debug print
-		// fmt.Printf("read buffer %v\n", searchBuffer[:bytesRead])
-
-		for i := 0; i < bytesRead; i++ {
-			if searchBuffer[i] == NewlineByte {
-				pos = pos + int64(i) + 1
-
-				// debug print
-				// fmt.Printf("Found newline at i=%d, pos=%d\n", i, pos)
-
-				pos, err = file.Seek(pos, io.SeekStart)
-				return pos, err
-			}
+		if err != nil {
+			return 0, err
 		}
-
-		// debug block
-		// pos, err = file.Seek(0, io.SeekCurrent)
-		// if err != nil {
-		//	return
-		// }
-		// fmt.Printf("curr pos %d\n", pos)
-
-		pos += int64(bytesRead)
 	}
 
-	return pos, nil
-}
+	_, err := file.Seek(pos, io.SeekStart)
 
-func readLineBefore(file io.ReadSeeker) ([]byte, int64, error) {
-	// note: assumes that the file read pointer is just after a newline character.
-	// and attempts to read the line on the other side of that newline
+	return count, err
+}
 
+// lineNumberAt is the line number of the line starting at the current position of file
+func lineNumberAt(file io.ReadSeeker) (int, error) {
 	pos, err := file.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return []byte{}, pos, err
-	}
-
-	if pos == 0 {
-		return []byte{}, pos, nil
+		return 0, err
 	}
 
-	// debug print
-	// fmt.Printf("reading from %d\n", pos)
-
-	readTarget := minmax.Int64Max(pos-MaxLineSizeBytes, 0)
-
-	_, err = file.Seek(readTarget, io.SeekStart)
+	count, err := countNewlines(file, pos)
 	if err != nil {
-		return []byte{}, pos, err
-	}
-
-	numBytes, readErr := file.Read(lineBuffer)
-	if readErr != nil && !errors.Is(readErr, io.EOF) {
-		return []byte{}, pos, readErr
-	}
-
-	// debug print
-	// fmt.Printf("numBytes %d, buffer slice %v, buffer str '%s'\n", numBytes, lineBuffer[:numBytes], string(lineBuffer[:numBytes]))
-
-	if numBytes < 2 {
-		pos -= int64(numBytes)
-		return []byte{}, pos, nil
-	}
-
-	endByte := readTarget + int64(numBytes)
-	skipBytes := endByte - pos
-
-	// debug print
-	// fmt.Printf("skip bytes %d\n", skipBytes)
-
-	lastIndex := numBytes - int(skipBytes) - 1
-
-	// debug print
-	// fmt.Printf("lastIndex %d\n", lastIndex)
-
-	pos-- // the newline we were just at
-	// -1 because we should have just been at a newline
-	for i := lastIndex - 1; i >= 0; i-- {
-		if i == 0 {
-			var slice []byte
-			pos = 0
-			if lineBuffer[lastIndex] == NewlineByte {
-				slice = lineBuffer[i : lastIndex+1]
-			} else {
-				slice = lineBuffer[i:lastIndex]
-			}
-
-			_, err = file.Seek(pos, io.SeekStart)
-
-			// debug print
-			// fmt.Printf("returning i=0 pos %d slice %v\n", pos, slice)
-			return slice, pos, err
-		} else if lineBuffer[i] == NewlineByte {
-			var slice []byte
-			if lineBuffer[lastIndex] == NewlineByte {
-				slice = lineBuffer[i+1 : lastIndex+1]
-			} else {
-				slice = lineBuffer[i+1 : lastIndex]
-			}
-			_, err = file.Seek(pos, io.SeekStart)
-
-			// debug print
-			// fmt.Printf("returning i!=0 pos %d slice %v\n", pos, slice)
-			return slice, pos, err
-		}
-		pos--
+		return 0, err
 	}
 
-	return []byte{}, pos, nil
+	return count + 1, nil
 }
 
-func moveLines(file io.ReadSeeker, skipLines int) (linesMoved int, err error) {
-	// Skip to the end of the file and set up to read only abs(startLine) many lines
-	var pos int64 = 1
-	var endPos int64
-
-	switch {
-	case skipLines == 0:
-		return 0, nil
-	case skipLines < 0:
-		for pos > 0 && skipLines < 0 {
-			pos, err = reverseOneLine(file)
-			if err != nil {
-				return linesMoved, err
-			}
-
-			skipLines++
-			linesMoved--
-		}
-	default:
-		endPos, err = peekEndPos(file)
-		if err != nil {
-			return linesMoved, err
-		}
-
-		for pos < endPos && skipLines > 0 {
-			pos, err = forwardOneLine(file)
-			if err != nil {
-				return linesMoved, err
-			}
-
-			skipLines--
-			linesMoved++
-		}
+// catFile hands the lines of file from its current position to lp, numbering them from lineNum
+//
+// It returns the position it stopped at, and the line number of the line there.
+func catFile(ctx context.Context, file io.ReadSeeker, path string, lineNum int, lp linehandler.LineHandler) (int64, int, error) {
+	start, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, lineNum, err
 	}
 
-	return linesMoved, nil
+	return catReader(ctx, file, path, start, lineNum, lp)
 }
 
-// dropCR drops a terminal \r from the data.
-func dropCR(data []byte) []byte {
-	if len(data) > 0 && data[len(data)-1] == '\r' {
-		return data[0 : len(data)-1]
-	}
-	return data
-}
-
-// ScanLines is a split function for a Scanner that returns each line of
-// text, stripped of any trailing end-of-line marker. The returned line may
-// be empty. The end-of-line marker is one optional carriage return followed
-// by one mandatory newline. In regular expression notation, it is `\r?\n`.
-// The last non-empty line of input will be returned even if it has no
-// newline.
-func scanLinesWithNewline(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF && len(data) == 0 {
-		return 0, nil, nil
-	}
-
-	if i := bytes.IndexByte(data, '\n'); i >= 0 {
-		// We have a full newline-terminated line.
-		return i + 1, append(dropCR(data[0:i]), 10), nil
-	}
-
-	// If we're at EOF, we have a final, non-terminated line. Return it.
-	if atEOF {
-		return len(data), dropCR(data), nil
-	}
-
-	// Request more data.
-	return 0, nil, nil
-}
+// catReader hands the lines of r to lp, numbering them from lineNum (or not at all when it is 0); start is the offset of the first of them
+//
+// It returns the offset it stopped at (just past the last line, or at the start of the one it did not get to),
+// and the line number of the line there.
+func catReader(ctx context.Context, r io.Reader, path string, start int64, lineNum int, lp linehandler.LineHandler) (int64, int, error) {
+	rec := linehandler.Record{Path: path}
+	end := start
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := scanLinesWithNewline(data, atEOF)
+		if token != nil {
+			rec.Offset = end
+		}
+		end += int64(advance)
 
-func catFile(ctx context.Context, file io.ReadSeeker, filename string, lp linehandler.LineHandler) (int64, error) {
-	scanner := bufio.NewScanner(file)
-	scanner.Split(scanLinesWithNewline)
+		return advance, token, err
+	})
 	buffer := make([]byte, MaxLineSizeBytes+1)
 	scanner.Buffer(buffer, MaxLineSizeBytes)
 
 	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
-			pos, err := file.Seek(0, io.SeekCurrent)
-			if err != nil {
-				return 0, err
-			}
-			err = ctx.Err()
-			return pos, err
+			return rec.Offset, lineNum, ctx.Err()
 		default:
 		}
 
-		line := scanner.Text() // with newline
-		lp.HandleLine(filename, line)
+		rec.Raw = scanner.Bytes() // with newline
+		rec.Line = string(rec.Raw)
+		rec.Number = lineNum
+		if err := lp.HandleLine(&rec); err != nil {
+			return 0, lineNum, err
+		}
+		if lineNum > 0 {
+			lineNum++
+		}
 	}
 
-	pos, err := file.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return 0, err
+	if err := scanner.Err(); err != nil {
+		return 0, lineNum, err
 	}
 
-	return pos, nil
+	return end, lineNum, nil
 }
 
-// func catGzip(file io.Reader, filename string, lp linehandler.LineHandler) error {
-//	scanner := bufio.NewScanner(file)
-//	for scanner.Scan() {
-//		line := scanner.Text()
-//		lp.HandleLine(filename, line)
-//	}
-
-//	return nil
-// }
-
 // Cat a tsar log file, possibly ignoring some lines
 func Cat(ctx context.Context, directory, filename string, lp linehandler.LineHandler) (int64, error) {
 	// directory and filename should come from filepath.Split()
@@ -373,84 +185,172 @@ func Cat(ctx context.Context, directory, filename string, lp linehandler.LineHan
 }
 
 // CatFrom cats a file starting from the specified byte offset
+//
+// Files compressed with gzip, bzip2 or zstd (as told by their first bytes) are decompressed as they are read;
+// pos and the returned offset are then offsets in the decompressed bytes.
 func CatFrom(ctx context.Context, directory, filename string, pos int64, lp linehandler.LineHandler) (int64, error) {
+	pos, _, err := catFrom(ctx, directory, filename, pos, 0, lp)
+	return pos, err
+}
+
+// catFrom is CatFrom, numbering lines from lineNum (counting them up to pos when lineNum is 0)
+//
+// It also returns the line number of the line at the position it stopped at.
+func catFrom(ctx context.Context, directory, filename string, pos int64, lineNum int, lp linehandler.LineHandler) (int64, int, error) {
 	// debug print
 	// fmt.Printf("Catting %s%s from %d\n", directory, filename, pos)
 
 	file, err := os.Open(directory + filename)
 	if err != nil {
-		return 0, err
+		return 0, lineNum, err
 	}
 	defer deferutil.CheckDefer(file.Close)
 
-	if strings.HasSuffix(filename, ".gz") {
-		// debug print
-		// fmt.Println("Opening a gzip wrapper around the file")
+	compression, err := DetectCompression(file)
+	if err != nil {
+		return 0, lineNum, err
+	}
 
-		// if pos == 0 {
-		//	gzf, gzerr := gzip.NewReader(file)
-		//	if gzerr != nil {
-		//		return 0, gzerr
-		//	}
-		//	defer deferutil.MustClose(gzf)
+	if compression != Uncompressed {
+		return catCompressed(ctx, file, compression, directory+filename, pos, lineNum, lp)
+	}
 
-		//	return 0, catGzip(gzf, filename, lp)
-		// }
+	_, err = file.Seek(pos, io.SeekStart)
+	if err != nil {
+		return 0, lineNum, err
+	}
 
-		fmt.Fprintln(os.Stderr, "Cannot specify a pos to CatFrom with a gzip file. Treating as a normal file (probably with bad results)")
+	if lineNum == 0 && linehandler.UsesLineNumbers(lp) {
+		if lineNum, err = lineNumberAt(file); err != nil {
+			return 0, lineNum, err
+		}
 	}
 
-	// debug print
-	// fmt.Println("No gzip wrapper required")
+	return catFile(ctx, file, directory+filename, lineNum, lp)
+}
 
-	// not gzip
-	_, err = file.Seek(pos, io.SeekStart)
+// catCompressed is catFrom for a compressed file, which has to be decompressed from the start to get to pos
+func catCompressed(ctx context.Context, file io.Reader, compression Compression, path string, pos int64, lineNum int, lp linehandler.LineHandler) (int64, int, error) {
+	r, closeReader, err := decompress(file, compression)
 	if err != nil {
-		return 0, err
+		return 0, lineNum, fmt.Errorf("could not read %s as %s: %w", path, compression, err)
+	}
+	defer deferutil.CheckDefer(closeReader)
+
+	skipped, err := skipBytes(r, pos)
+	if err != nil {
+		return 0, lineNum, err
+	}
+
+	if lineNum == 0 && linehandler.UsesLineNumbers(lp) {
+		lineNum = skipped + 1
 	}
 
-	return catFile(ctx, file, filename, lp)
+	return catReader(ctx, r, path, pos, lineNum, lp)
 }
 
 // Tail will tail a file, possibly ignoring some lines
+//
+// Compressed files are decompressed to a temporary file first (see CatFrom for the formats).
+// Lines are found by reading backwards, which takes lines of any length, but are then read forwards,
+// so a line over MaxLineSizeBytes still fails with bufio.ErrTooLong.
 func Tail(ctx context.Context, directory, filename string, skipLines int, lp linehandler.LineHandler) (int64, error) {
 	// directory and filename should come from filepath.Split()
-	file, err := os.Open(directory + filename)
+	file, closeFile, err := openSeekable(directory + filename)
 	if err != nil {
 		return 0, err
 	}
-	defer deferutil.CheckDefer(file.Close)
+	defer deferutil.CheckDefer(closeFile)
+
+	return tailFile(ctx, file, directory+filename, skipLines, lp)
+}
 
+// tailFile hands the lines of file to lp, after skipping skipLines lines from its start (or all but -skipLines from its end)
+//
+// The lines are only numbered when lp uses line numbers, as that means reading the file up to them.
+func tailFile(ctx context.Context, file io.ReadSeeker, path string, skipLines int, lp linehandler.LineHandler) (int64, error) {
 	if skipLines < 0 {
-		pos, serr := skipToEnd(file)
-		if serr != nil {
-			return pos, serr
+		pos, err := skipToEnd(file)
+		if err != nil {
+			return pos, err
 		}
 	}
 
-	_, err = moveLines(file, skipLines)
+	_, err := NewReader(file).MoveLines(skipLines)
 	if err != nil {
 		return 0, err
 	}
 
-	return catFile(ctx, file, filename, lp)
+	lineNum := 0
+	if linehandler.UsesLineNumbers(lp) {
+		if lineNum, err = lineNumberAt(file); err != nil {
+			return 0, err
+		}
+	}
+
+	pos, _, err := catFile(ctx, file, path, lineNum, lp)
+
+	return pos, err
+}
+
+// tailedFiles are the files opened by TailFiles, kept open from finding where the lines start until they are read,
+// so compressed ones are only decompressed once
+type tailedFiles struct {
+	names   []string
+	files   []io.ReadSeeker
+	closers []func() error
+}
+
+func newTailedFiles(filenames []string) *tailedFiles {
+	return &tailedFiles{
+		names: filenames,
+		files: make([]io.ReadSeeker, len(filenames)),
+	}
 }
 
-func checkFileForStartingFile(fname string, remainingLines int) (int, error) {
-	file, err := os.Open(fname)
+// open gives file i, opening it the first time
+func (tf *tailedFiles) open(i int) (io.ReadSeeker, error) {
+	if tf.files[i] != nil {
+		return tf.files[i], nil
+	}
+
+	file, closeFile, err := openSeekable(tf.names[i])
 	if err != nil {
+		return nil, err
+	}
+
+	tf.files[i] = file
+	tf.closers = append(tf.closers, closeFile)
+
+	return file, nil
+}
+
+// Close closes the files that were opened
+func (tf *tailedFiles) Close() error {
+	var firstErr error
+	for _, closeFile := range tf.closers {
+		if err := closeFile(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	tf.closers = nil
+
+	return firstErr
+}
+
+func checkFileForStartingFile(file io.ReadSeeker, remainingLines int) (int, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
 		return 0, err
 	}
-	defer deferutil.CheckDefer(file.Close)
 
 	if remainingLines < 0 {
-		_, err = skipToEnd(file)
+		_, err := skipToEnd(file)
 		if err != nil {
 			return 0, err
 		}
 	}
 
-	linesMoved, err := moveLines(file, remainingLines)
+	linesMoved, err := NewReader(file).MoveLines(remainingLines)
 	if err != nil {
 		return remainingLines, err
 	}
@@ -458,12 +358,17 @@ func checkFileForStartingFile(fname string, remainingLines int) (int, error) {
 	return remainingLines - linesMoved, nil
 }
 
-func findStartingFile(filenames []string, remainingLines int) (startIndex, startLineNum int, err error) {
-	for i := len(filenames) - 1; i >= 0; i-- {
+func findStartingFile(files *tailedFiles, remainingLines int) (startIndex, startLineNum int, err error) {
+	for i := len(files.names) - 1; i >= 0; i-- {
 		startIndex = i
 		startLineNum = remainingLines
 
-		remainingLines, err = checkFileForStartingFile(filenames[i], remainingLines)
+		var file io.ReadSeeker
+		if file, err = files.open(i); err != nil {
+			return startIndex, startLineNum, err
+		}
+
+		remainingLines, err = checkFileForStartingFile(file, remainingLines)
 		if err != nil {
 			return startIndex, startLineNum, err
 		}
@@ -476,28 +381,17 @@ func findStartingFile(filenames []string, remainingLines int) (startIndex, start
 	return startIndex, startLineNum, err
 }
 
-func tailFileCat(ctx context.Context, fname string, startLineNum int, lp linehandler.LineHandler) (int64, error) {
-	file, err := os.Open(fname)
+func tailFileCat(ctx context.Context, files *tailedFiles, i, startLineNum int, lp linehandler.LineHandler) (int64, error) {
+	file, err := files.open(i)
 	if err != nil {
 		return 0, err
 	}
-	defer deferutil.CheckDefer(file.Close)
-
-	_, fileName := filepath.Split(fname)
-
-	if startLineNum < 0 {
-		_, err = skipToEnd(file)
-		if err != nil {
-			return 0, err
-		}
-	}
 
-	_, err = moveLines(file, startLineNum)
-	if err != nil {
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
 		return 0, err
 	}
 
-	endFilePos, err := catFile(ctx, file, fileName, lp)
+	endFilePos, err := tailFile(ctx, file, files.names[i], startLineNum, lp)
 	if err != nil {
 		return 0, err
 	}
@@ -506,12 +400,17 @@ func tailFileCat(ctx context.Context, fname string, startLineNum int, lp linehan
 }
 
 // TailFiles gets the last numLines lines from the files
+//
+// As for Tail, the lines are read forwards, so none of them may be over MaxLineSizeBytes.
 func TailFiles(ctx context.Context, filenames []string, numLines int, lp linehandler.LineHandler) (int64, error) {
 	if len(filenames) == 0 {
 		return 0, errors.New("cannot TailFiles on an empty list of filenames")
 	}
 
-	startIndex, startLineNum, err := findStartingFile(filenames, numLines)
+	files := newTailedFiles(filenames)
+	defer deferutil.CheckDefer(files.Close)
+
+	startIndex, startLineNum, err := findStartingFile(files, numLines)
 	if err != nil {
 		return 0, err
 	}
@@ -519,9 +418,9 @@ func TailFiles(ctx context.Context, filenames []string, numLines int, lp linehan
 	var endFilePos int64
 	for i := startIndex; i < len(filenames); i++ {
 		if i == startIndex {
-			endFilePos, err = tailFileCat(ctx, filenames[i], startLineNum, lp)
+			endFilePos, err = tailFileCat(ctx, files, i, startLineNum, lp)
 		} else {
-			endFilePos, err = tailFileCat(ctx, filenames[i], 0, lp)
+			endFilePos, err = tailFileCat(ctx, files, i, 0, lp)
 		}
 
 		if err != nil {
@@ -532,15 +431,30 @@ func TailFiles(ctx context.Context, filenames []string, numLines int, lp linehan
 	return endFilePos, nil
 }
 
-func tacFile(ctx context.Context, file io.ReadSeeker, filename string, lp linehandler.LineHandler) (int64, error) {
+// tacFile hands the lines of file before its current position to lp, last first
+//
+// The lines are only numbered when lp uses line numbers, as that means reading the whole file before the first of them.
+func tacFile(ctx context.Context, file io.ReadSeeker, path string, lp linehandler.LineHandler) (int64, error) {
 	// debug print
-	// fmt.Printf("taccing %s\n", filename)
+	// fmt.Printf("taccing %s\n", path)
 
 	pos, err := file.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return pos, err
 	}
 
+	numbered := linehandler.UsesLineNumbers(lp)
+
+	var newlines int // the newlines before the line being handled, to number it
+	if numbered {
+		if newlines, err = countNewlines(file, pos); err != nil {
+			return pos, err
+		}
+	}
+
+	rec := linehandler.Record{Path: path}
+	reader := NewReader(file)
+
 	// debug print
 	// fmt.Printf("pos post skip %d\n", pos)
 
@@ -551,7 +465,7 @@ func tacFile(ctx context.Context, file io.ReadSeeker, filename string, lp lineha
 		default:
 		}
 
-		lineBytes, newPos, readErr := readLineBefore(file)
+		lineBytes, newPos, readErr := reader.ReadLineBefore()
 		if readErr != nil {
 			return newPos, readErr
 		}
@@ -561,8 +475,21 @@ func tacFile(ctx context.Context, file io.ReadSeeker, filename string, lp lineha
 		// debug print
 		// fmt.Printf("lineBytes %v\n", lineBytes)
 
-		line := string(lineBytes)
-		lp.HandleLine(filename, line)
+		rec.Raw = lineBytes
+		rec.Line = string(lineBytes)
+		if len(lineBytes) > 0 && lineBytes[len(lineBytes)-1] == '\n' {
+			newlines--
+		} else if len(lineBytes) > 0 {
+			rec.Line += "\n" // the last line of a file without a newline at its end is printed first, so it needs one
+		}
+		rec.Number = 0
+		if numbered {
+			rec.Number = newlines + 1
+		}
+		rec.Offset = pos
+		if err := lp.HandleLine(&rec); err != nil {
+			return pos, err
+		}
 
 		// // debug print
 		// // fmt.Printf("pos after print %d\n", pos)
@@ -572,25 +499,23 @@ func tacFile(ctx context.Context, file io.ReadSeeker, filename string, lp lineha
 }
 
 // Tac prints out contents of a file backwards
+//
+// Compressed files are decompressed to a temporary file first (see CatFrom for the formats).
 func Tac(ctx context.Context, directory, filename string, lp linehandler.LineHandler) (int64, error) {
 	// directory and filename should come from filepath.Split()
 	// debug print
 	// fmt.Printf("Catting %s%s from %d\n", directory, filename, pos)
 
-	file, err := os.Open(directory + filename)
+	file, closeFile, err := openSeekable(directory + filename)
 	if err != nil {
 		return 0, err
 	}
-	defer deferutil.CheckDefer(file.Close)
-
-	if strings.HasSuffix(filename, ".gz") {
-		fmt.Fprintln(os.Stderr, "Cannot Tac a gzip file. Treating as a normal file (probably with bad results)")
-	}
+	defer deferutil.CheckDefer(closeFile)
 
 	pos, err := skipToEnd(file)
 	if err != nil {
 		return pos, err
 	}
 
-	return tacFile(ctx, file, filename, lp)
+	return tacFile(ctx, file, directory+filename, lp)
 }
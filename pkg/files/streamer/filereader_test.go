@@ -1,9 +1,14 @@
 package streamer
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/gsmcwhirter/prettify/pkg/pathutil"
@@ -122,7 +127,7 @@ func Test_peekEndPos(t *testing.T) {
 	}
 }
 
-func Test_forwardOneLine(t *testing.T) {
+func TestReader_forwardOneLine(t *testing.T) {
 	type args struct {
 		file io.ReadSeeker
 	}
@@ -210,7 +215,7 @@ func Test_forwardOneLine(t *testing.T) {
 				}
 			}
 
-			gotPos, err := forwardOneLine(tt.args.file)
+			gotPos, err := NewReader(tt.args.file).forwardOneLine()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("forwardOneLine() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -222,7 +227,7 @@ func Test_forwardOneLine(t *testing.T) {
 	}
 }
 
-func Test_reverseOneLine(t *testing.T) {
+func TestReader_reverseOneLine(t *testing.T) {
 	type args struct {
 		file io.ReadSeeker
 	}
@@ -328,7 +333,7 @@ func Test_reverseOneLine(t *testing.T) {
 				}
 			}
 
-			gotPos, err := reverseOneLine(tt.args.file)
+			gotPos, err := NewReader(tt.args.file).reverseOneLine()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("reverseOneLine() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -340,7 +345,7 @@ func Test_reverseOneLine(t *testing.T) {
 	}
 }
 
-func Test_readLineBefore(t *testing.T) {
+func TestReader_ReadLineBefore(t *testing.T) {
 	type args struct {
 		file io.ReadSeeker
 	}
@@ -368,7 +373,7 @@ func Test_readLineBefore(t *testing.T) {
 				file: testutil.NewReadSeeker([]byte("testing 1\ntesting 2\n")),
 			},
 			startPos:  19,
-			wantSlice: []byte("testing "),
+			wantSlice: []byte("testing 2"),
 			wantPos:   10,
 			wantErr:   false,
 		},
@@ -415,22 +420,22 @@ func Test_readLineBefore(t *testing.T) {
 				}
 			}
 
-			gotSlice, gotPos, err := readLineBefore(tt.args.file)
+			gotSlice, gotPos, err := NewReader(tt.args.file).ReadLineBefore()
 			if (err != nil) != tt.wantErr {
-				t.Errorf("readLineBefore() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("ReadLineBefore() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !reflect.DeepEqual(gotSlice, tt.wantSlice) && (len(gotSlice) > 0 || len(tt.wantSlice) > 0) {
-				t.Errorf("readLineBefore() gotSlice = %v, want %v", gotSlice, tt.wantSlice)
+				t.Errorf("ReadLineBefore() gotSlice = %v, want %v", gotSlice, tt.wantSlice)
 			}
 			if gotPos != tt.wantPos {
-				t.Errorf("readLineBefore() gotPos = %v, want %v", gotPos, tt.wantPos)
+				t.Errorf("ReadLineBefore() gotPos = %v, want %v", gotPos, tt.wantPos)
 			}
 		})
 	}
 }
 
-func Test_moveLines(t *testing.T) {
+func TestReader_MoveLines(t *testing.T) {
 	type args struct {
 		file      io.ReadSeeker
 		skipLines int
@@ -523,16 +528,16 @@ func Test_moveLines(t *testing.T) {
 				}
 			}
 
-			linesMoved, err := moveLines(tt.args.file, tt.args.skipLines)
+			linesMoved, err := NewReader(tt.args.file).MoveLines(tt.args.skipLines)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("moveLines() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("MoveLines() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if linesMoved != tt.wantLinesMoved {
-				t.Errorf("moveLines() linesMoved = %v, wantLinesMoved %v", linesMoved, tt.wantLinesMoved)
+				t.Errorf("MoveLines() linesMoved = %v, wantLinesMoved %v", linesMoved, tt.wantLinesMoved)
 			}
 			pos, err = tt.args.file.Seek(0, io.SeekCurrent)
 			if err != nil || pos != tt.wantPos {
-				t.Errorf("moveLines() post pos = %d, want %d (err = %v)", pos, tt.wantPos, err)
+				t.Errorf("MoveLines() post pos = %d, want %d (err = %v)", pos, tt.wantPos, err)
 			}
 		})
 	}
@@ -688,7 +693,7 @@ func Test_catFile(t *testing.T) {
 				Pretty:       tt.lpArgs.withPretty,
 				Color:        tt.lpArgs.withColor,
 				Sort:         tt.lpArgs.withSort,
-				Printf:       buffer.Printf,
+				Output:       &buffer,
 			})
 
 			pos, err := tt.args.file.Seek(tt.startPos, io.SeekStart)
@@ -701,7 +706,7 @@ func Test_catFile(t *testing.T) {
 				}
 			}
 
-			gotPos, err := catFile(context.Background(), tt.args.file, tt.args.filename, lp)
+			gotPos, _, err := catFile(context.Background(), tt.args.file, tt.args.filename, 1, lp)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("catFile() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -855,6 +860,25 @@ func Test_tacFile(t *testing.T) {
 			wantErr:   false,
 			wantBytes: []byte("test: testing 3\ntest: \ntest: testing 2\ntest: \ntest: \ntest: testing 1\n"),
 		},
+		{
+			name: "no newline at the end",
+			args: args{
+				file:     testutil.NewReadSeeker([]byte("testing 1\ntesting 2\ntesting 3")),
+				filename: "test",
+			},
+			lpArgs: lpArgs{
+				withBlanks:   false,
+				withFilename: false,
+				withPretty:   false,
+				withColor:    false,
+				withSort:     false,
+				withPath:     "",
+			},
+			startPos:  29,
+			wantPos:   0,
+			wantErr:   false,
+			wantBytes: []byte("testing 3\ntesting 2\ntesting 1\n"),
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -867,7 +891,7 @@ func Test_tacFile(t *testing.T) {
 				Pretty:       tt.lpArgs.withPretty,
 				Color:        tt.lpArgs.withColor,
 				Sort:         tt.lpArgs.withSort,
-				Printf:       buffer.Printf,
+				Output:       &buffer,
 			})
 
 			pos, err := tt.args.file.Seek(tt.startPos, io.SeekStart)
@@ -896,6 +920,141 @@ func Test_tacFile(t *testing.T) {
 	}
 }
 
+func Test_handlerError(t *testing.T) {
+	errClosed := errors.New("closed")
+
+	tests := []struct {
+		name      string
+		read      func(context.Context, io.ReadSeeker, string, linehandler.LineHandler) (int64, error)
+		startPos  int64
+		wantLines []string
+	}{
+		{
+			name: "catFile",
+			read: func(ctx context.Context, file io.ReadSeeker, path string, lp linehandler.LineHandler) (int64, error) {
+				pos, _, err := catFile(ctx, file, path, 1, lp)
+				return pos, err
+			},
+			startPos:  0,
+			wantLines: []string{"testing 1\n", "testing 2\n"},
+		},
+		{
+			name:      "tacFile",
+			read:      tacFile,
+			startPos:  30,
+			wantLines: []string{"testing 3\n", "testing 2\n"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			lh := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+				got = append(got, rec.Line)
+				if len(got) == 2 {
+					return errClosed
+				}
+				return nil
+			})
+
+			file := testutil.NewReadSeeker([]byte("testing 1\ntesting 2\ntesting 3\n"))
+			if _, err := file.Seek(tt.startPos, io.SeekStart); err != nil {
+				t.Fatalf("Could not set file to desired test position (err = %v)", err)
+			}
+
+			if _, err := tt.read(context.Background(), file, "test", lh); !errors.Is(err, errClosed) {
+				t.Errorf("%s() error = %v, want %v", tt.name, err, errClosed)
+			}
+
+			if !reflect.DeepEqual(got, tt.wantLines) {
+				t.Errorf("%s() handled lines = %q, want %q", tt.name, got, tt.wantLines)
+			}
+		})
+	}
+}
+
+func Test_records(t *testing.T) {
+	data := []byte("testing 1\r\n\ntesting 3\ntesting 4\n")
+
+	tests := []struct {
+		name     string
+		startPos int64
+		read     func(context.Context, io.ReadSeeker, linehandler.LineHandler) (int64, error)
+		want     []linehandler.Record
+	}{
+		{
+			name:     "catFile",
+			startPos: 0,
+			read: func(ctx context.Context, file io.ReadSeeker, lh linehandler.LineHandler) (int64, error) {
+				pos, _, err := catFile(ctx, file, "dir/test", 1, lh)
+				return pos, err
+			},
+			want: []linehandler.Record{
+				{Path: "dir/test", Line: "testing 1\n", Number: 1, Offset: 0},
+				{Path: "dir/test", Line: "\n", Number: 2, Offset: 11},
+				{Path: "dir/test", Line: "testing 3\n", Number: 3, Offset: 12},
+				{Path: "dir/test", Line: "testing 4\n", Number: 4, Offset: 22},
+			},
+		},
+		{
+			name:     "catFile from line 3",
+			startPos: 12,
+			read: func(ctx context.Context, file io.ReadSeeker, lh linehandler.LineHandler) (int64, error) {
+				lineNum, err := lineNumberAt(file)
+				if err != nil {
+					return 0, err
+				}
+				pos, _, err := catFile(ctx, file, "dir/test", lineNum, lh)
+				return pos, err
+			},
+			want: []linehandler.Record{
+				{Path: "dir/test", Line: "testing 3\n", Number: 3, Offset: 12},
+				{Path: "dir/test", Line: "testing 4\n", Number: 4, Offset: 22},
+			},
+		},
+		{
+			name:     "tacFile",
+			startPos: 32,
+			read: func(ctx context.Context, file io.ReadSeeker, lh linehandler.LineHandler) (int64, error) {
+				return tacFile(ctx, file, "dir/test", lh)
+			},
+			want: []linehandler.Record{
+				{Path: "dir/test", Line: "testing 4\n", Number: 4, Offset: 22},
+				{Path: "dir/test", Line: "testing 3\n", Number: 3, Offset: 12},
+				{Path: "dir/test", Line: "\n", Number: 2, Offset: 11},
+				{Path: "dir/test", Line: "testing 1\r\n", Number: 1, Offset: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var got []linehandler.Record
+			lh := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+				if string(rec.Raw) != rec.Line {
+					t.Errorf("%s() Raw = %q, want %q", tt.name, rec.Raw, rec.Line)
+				}
+
+				got = append(got, linehandler.Record{Path: rec.Path, Line: rec.Line, Number: rec.Number, Offset: rec.Offset})
+				return nil
+			})
+
+			file := testutil.NewReadSeeker(data)
+			if _, err := file.Seek(tt.startPos, io.SeekStart); err != nil {
+				t.Fatalf("Could not set file to desired test position (err = %v)", err)
+			}
+
+			if _, err := tt.read(context.Background(), file, lh); err != nil {
+				t.Fatalf("%s() error = %v", tt.name, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s() records = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCat(t *testing.T) {
 	buffer := testutil.NewPrintfBuffer(1024) // 1Kb to start
 
@@ -1021,7 +1180,7 @@ func TestCat(t *testing.T) {
 				Pretty:       tt.lpArgs.withPretty,
 				Color:        tt.lpArgs.withColor,
 				Sort:         tt.lpArgs.withSort,
-				Printf:       buffer.Printf,
+				Output:       &buffer,
 			})
 
 			got, err := Cat(context.Background(), tt.args.directory, tt.args.filename, lp)
@@ -1190,7 +1349,7 @@ func TestCatFrom(t *testing.T) {
 				Pretty:       tt.lpArgs.withPretty,
 				Color:        tt.lpArgs.withColor,
 				Sort:         tt.lpArgs.withSort,
-				Printf:       buffer.Printf,
+				Output:       &buffer,
 			})
 
 			got, err := CatFrom(context.Background(), tt.args.directory, tt.args.filename, tt.args.pos, lp)
@@ -1334,7 +1493,7 @@ func TestTac(t *testing.T) {
 				Pretty:       tt.lpArgs.withPretty,
 				Color:        tt.lpArgs.withColor,
 				Sort:         tt.lpArgs.withSort,
-				Printf:       buffer.Printf,
+				Output:       &buffer,
 			})
 
 			got, err := Tac(context.Background(), tt.args.directory, tt.args.filename, lp)
@@ -1522,7 +1681,7 @@ func TestTail(t *testing.T) {
 				Pretty:       tt.lpArgs.withPretty,
 				Color:        tt.lpArgs.withColor,
 				Sort:         tt.lpArgs.withSort,
-				Printf:       buffer.Printf,
+				Output:       &buffer,
 			})
 
 			got, err := Tail(context.Background(), tt.args.directory, tt.args.filename, tt.args.startLine, lp)
@@ -1622,7 +1781,7 @@ func TestTailFiles(t *testing.T) {
 				Pretty:       tt.lpArgs.withPretty,
 				Color:        tt.lpArgs.withColor,
 				Sort:         tt.lpArgs.withSort,
-				Printf:       buffer.Printf,
+				Output:       &buffer,
 			})
 
 			got, err := TailFiles(context.Background(), tt.args.filenames, tt.args.numLines, lp)
@@ -1717,7 +1876,10 @@ func Test_findStartingFile(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			gotStartIndex, gotStartLineNum, err := findStartingFile(tt.args.filenames, tt.args.numLines)
+			files := newTailedFiles(tt.args.filenames)
+			t.Cleanup(func() { _ = files.Close() })
+
+			gotStartIndex, gotStartLineNum, err := findStartingFile(files, tt.args.numLines)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("findStartingFile() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1731,3 +1893,118 @@ func Test_findStartingFile(t *testing.T) {
 		})
 	}
 }
+
+// countingReadSeeker counts the bytes read from the io.ReadSeeker it wraps
+type countingReadSeeker struct {
+	io.ReadSeeker
+	read int64
+}
+
+func (c *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := c.ReadSeeker.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+// lineNumberTestData is a few MB of numbered lines, for checking how much of it is read
+func lineNumberTestData() []byte {
+	var sb strings.Builder
+	for i := 1; sb.Len() < 4*1024*1024; i++ {
+		fmt.Fprintf(&sb, "testing %d\n", i)
+	}
+
+	return []byte(sb.String())
+}
+
+func Test_tailFile_longLine(t *testing.T) {
+	t.Parallel()
+	long := strings.Repeat("x", MaxLineSizeBytes+1) + "\n"
+
+	// the line is found reading backwards, but reading it forwards is still capped
+	file := testutil.NewReadSeeker([]byte("short\n" + long))
+	if _, err := tailFile(context.Background(), file, "test", -1, linehandler.Discard); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("tailFile() error = %v, want %v", err, bufio.ErrTooLong)
+	}
+
+	// lines after it can be tailed
+	file = testutil.NewReadSeeker([]byte(long + "short\n"))
+	var got []string
+	lh := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+		got = append(got, rec.Line)
+		return nil
+	})
+	if _, err := tailFile(context.Background(), file, "test", -1, lh); err != nil {
+		t.Fatalf("tailFile() error = %v", err)
+	}
+	if want := []string{"short\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tailFile() lines = %q, want %q", got, want)
+	}
+}
+
+func Test_tailFile_lineNumbers(t *testing.T) {
+	t.Parallel()
+	data := lineNumberTestData()
+	lastLine := string(data[bytes.LastIndexByte(data[:len(data)-1], '\n')+1:])
+
+	tests := []struct {
+		name       string
+		numbered   bool
+		wantNumber int
+	}{
+		{name: "numbered", numbered: true, wantNumber: bytes.Count(data, []byte{'\n'})},
+		{name: "not numbered", numbered: false, wantNumber: 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			file := &countingReadSeeker{ReadSeeker: testutil.NewReadSeeker(data)}
+
+			var got []linehandler.Record
+			var lh linehandler.LineHandler = linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+				got = append(got, linehandler.Record{Line: rec.Line, Number: rec.Number})
+				return nil
+			})
+			if !tt.numbered {
+				lh = linehandler.WithoutLineNumbers(lh)
+			}
+
+			if _, err := tailFile(context.Background(), file, "test", -1, lh); err != nil {
+				t.Fatalf("tailFile() error = %v", err)
+			}
+
+			if want := []linehandler.Record{{Line: lastLine, Number: tt.wantNumber}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("tailFile() records = %+v, want %+v", got, want)
+			}
+
+			if readAll := file.read >= int64(len(data)); readAll != tt.numbered {
+				t.Errorf("tailFile() read %d of %d bytes, want the whole file read = %v", file.read, len(data), tt.numbered)
+			}
+		})
+	}
+}
+
+func Test_tacFile_lineNumbers(t *testing.T) {
+	t.Parallel()
+	data := lineNumberTestData()
+	file := &countingReadSeeker{ReadSeeker: testutil.NewReadSeeker(data)}
+	if _, err := skipToEnd(file); err != nil {
+		t.Fatalf("skipToEnd() error = %v", err)
+	}
+
+	errStop := errors.New("stop")
+	lh := linehandler.WithoutLineNumbers(linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+		if rec.Number != 0 {
+			t.Errorf("tacFile() Number = %d, want 0 when not numbering", rec.Number)
+		}
+		return errStop
+	}))
+
+	if _, err := tacFile(context.Background(), file, "test", lh); !errors.Is(err, errStop) {
+		t.Fatalf("tacFile() error = %v, want %v", err, errStop)
+	}
+
+	if file.read >= int64(len(data))/4 {
+		t.Errorf("tacFile() read %d of %d bytes before the first line, want much less", file.read, len(data))
+	}
+}
@@ -0,0 +1,307 @@
+package streamer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/gsmcwhirter/prettify/pkg/minmax"
+)
+
+// Reader moves around the lines of a file, and reads them backwards
+//
+// Each Reader has its own buffers, so several can be used at once (e.g. one per file being followed),
+// but a single Reader is not safe for concurrent use.
+type Reader struct {
+	file      io.ReadSeeker
+	searchBuf []byte
+
+	// for reading lines backwards (see ReadLineBefore)
+	scanning bool
+	block    []byte
+	lo, hi   int
+	blockPos int64
+}
+
+// NewReader creates a Reader for file, starting from its current position
+func NewReader(file io.ReadSeeker) *Reader {
+	return &Reader{file: file}
+}
+
+// searchBuffer is the buffer for looking for newlines, made on first use
+func (r *Reader) searchBuffer() []byte {
+	if r.searchBuf == nil {
+		r.searchBuf = make([]byte, searchBufferSize)
+	}
+
+	return r.searchBuf
+}
+
+// reverseOneLine moves back to the start of the line before the one the file is at, returning the new position
+func (r *Reader) reverseOneLine() (pos int64, err error) {
+	file, searchBuffer := r.file, r.searchBuffer()
+
+	pos, err = file.Seek(0, io.SeekCurrent)
+	if pos == 0 || err != nil {
+		return pos, err
+	}
+
+	// assume we are at the start of a line. back up one character over the newline
+	pos, err = file.Seek(-1, io.SeekCurrent)
+	if err != nil {
+		return pos, err
+	}
+
+	// debug print
+	// fmt.Printf("step back pos %d\n", pos)
+
+	var bytesRead int
+	var seekBack int64
+	for pos > 0 {
+		seekBack = minmax.Int64Min(pos, searchBufferSize)
+
+		// jump back to read a bit
+		pos, err = file.Seek(-seekBack, io.SeekCurrent)
+		if err != nil {
+			return pos, err
+		}
+
+		// debug print
+		// fmt.Printf("read at pos %d\n", pos)
+
+		bytesRead, err = file.Read(searchBuffer)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return pos, err
+		}
+
+		// debug print
+		// fmt.Printf("read buffer %v\n", searchBuffer[:bytesRead])
+
+		if bytesRead == 0 {
+			return pos, nil
+		}
+
+		for i := minmax.IntMin(int(seekBack), bytesRead) - 1; i >= 0; i-- {
+			if searchBuffer[i] == NewlineByte {
+				return file.Seek(pos+int64(i)+1, io.SeekStart)
+			}
+		}
+
+		// debug block
+		// pos, err = file.Seek(0, io.SeekCurrent)
+		// if err != nil {
+		//	return
+		// }
+		// fmt.Printf("curr pos %d\n", pos)
+
+		pos, err = file.Seek(-int64(bytesRead), io.SeekCurrent)
+		if err != nil {
+			return pos, err
+		}
+	}
+
+	return pos, nil
+}
+
+// forwardOneLine moves to the start of the line after the one the file is at, returning the new position
+func (r *Reader) forwardOneLine() (int64, error) {
+	file, searchBuffer := r.file, r.searchBuffer()
+
+	pos, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return pos, err
+	}
+
+	endPos, fErr := peekEndPos(file)
+	if fErr != nil {
+		return pos, fErr
+	}
+
+	// debug print
+	// fmt.Printf("start pos %d\n", pos)
+
+	if pos == endPos {
+		return pos, nil
+	}
+
+	for pos < endPos {
+		// read the character we are at
+		// debug print
+		// fmt.Printf("read at pos %d\n", pos)
+
+		bytesRead, readErr := file.Read(searchBuffer)
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return pos, readErr
+		}
+
+		if bytesRead == 0 {
+			return pos, nil
+		}
+
+		// debug print
+		// fmt.Printf("read buffer %v\n", searchBuffer[:bytesRead])
+
+		for i := 0; i < bytesRead; i++ {
+			if searchBuffer[i] == NewlineByte {
+				pos = pos + int64(i) + 1
+
+				// debug print
+				// fmt.Printf("Found newline at i=%d, pos=%d\n", i, pos)
+
+				pos, err = file.Seek(pos, io.SeekStart)
+				return pos, err
+			}
+		}
+
+		// debug block
+		// pos, err = file.Seek(0, io.SeekCurrent)
+		// if err != nil {
+		//	return
+		// }
+		// fmt.Printf("curr pos %d\n", pos)
+
+		pos += int64(bytesRead)
+	}
+
+	return pos, nil
+}
+
+// ReadLineBefore reads the line before the current position of the Reader (with its newline, if it has one),
+// and moves back to its start, returning the new position
+//
+// The file is read backwards a block at a time, with partial lines carried over to the next block, so lines can be any length.
+// While reading lines backwards, the Reader keeps its own position rather than moving the file every line,
+// so the file should only be moved through the Reader. The returned line is only valid until the next call.
+func (r *Reader) ReadLineBefore() ([]byte, int64, error) {
+	if !r.scanning {
+		pos, err := r.file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return []byte{}, pos, err
+		}
+
+		if r.block == nil {
+			r.block = make([]byte, 2*reverseBlockSize)
+		}
+		r.lo, r.hi = len(r.block), len(r.block)
+		r.blockPos = pos
+		r.scanning = true
+	}
+
+	// block[lo:hi] are the bytes of the file from blockPos that have been read but not returned yet;
+	// the line before the position is the end of them, after the last newline but one (the line's own)
+	for {
+		if r.hi > r.lo {
+			if i := bytes.LastIndexByte(r.block[r.lo:r.hi-1], NewlineByte); i >= 0 {
+				start := r.lo + i + 1
+				line := r.block[start:r.hi]
+				r.hi = start
+
+				return line, r.pos(), nil
+			}
+		}
+
+		if r.blockPos == 0 {
+			line := r.block[r.lo:r.hi]
+			r.hi = r.lo
+
+			return line, 0, nil
+		}
+
+		if err := r.readBlockBefore(); err != nil {
+			return []byte{}, r.pos(), err
+		}
+	}
+}
+
+// pos is the position of the Reader while reading lines backwards: the start of the last line returned
+func (r *Reader) pos() int64 {
+	return r.blockPos + int64(r.hi-r.lo)
+}
+
+// readBlockBefore reads the block of the file before block[lo:hi] into the block buffer, just in front of them,
+// growing the buffer if they are too many to fit it in
+func (r *Reader) readBlockBefore() error {
+	n := int(minmax.Int64Min(r.blockPos, reverseBlockSize))
+
+	if r.lo < n {
+		pending := r.hi - r.lo
+		block := r.block
+		if pending+n > len(block) {
+			block = make([]byte, 2*(pending+n))
+		}
+		copy(block[len(block)-pending:], r.block[r.lo:r.hi])
+		r.block, r.lo, r.hi = block, len(block)-pending, len(block)
+	}
+
+	if _, err := r.file.Seek(r.blockPos-int64(n), io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err := io.ReadFull(r.file, r.block[r.lo-n:r.lo]); err != nil {
+		return err
+	}
+
+	r.lo -= n
+	r.blockPos -= int64(n)
+
+	return nil
+}
+
+// sync moves the file to the position of the Reader after reading lines backwards, so it can be moved from there
+func (r *Reader) sync() error {
+	if !r.scanning {
+		return nil
+	}
+	r.scanning = false
+
+	_, err := r.file.Seek(r.pos(), io.SeekStart)
+
+	return err
+}
+
+// MoveLines moves the file skipLines lines forward (or back, when negative), stopping at its start or end
+//
+// It returns the number of lines moved (negative when moving back).
+func (r *Reader) MoveLines(skipLines int) (linesMoved int, err error) {
+	file := r.file
+
+	if err := r.sync(); err != nil {
+		return 0, err
+	}
+
+	// Skip to the end of the file and set up to read only abs(startLine) many lines
+	var pos int64 = 1
+	var endPos int64
+
+	switch {
+	case skipLines == 0:
+		return 0, nil
+	case skipLines < 0:
+		for pos > 0 && skipLines < 0 {
+			pos, err = r.reverseOneLine()
+			if err != nil {
+				return linesMoved, err
+			}
+
+			skipLines++
+			linesMoved--
+		}
+	default:
+		endPos, err = peekEndPos(file)
+		if err != nil {
+			return linesMoved, err
+		}
+
+		for pos < endPos && skipLines > 0 {
+			pos, err = r.forwardOneLine()
+			if err != nil {
+				return linesMoved, err
+			}
+
+			skipLines--
+			linesMoved++
+		}
+	}
+
+	return linesMoved, nil
+}
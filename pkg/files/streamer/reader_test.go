@@ -0,0 +1,242 @@
+package streamer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/testutil"
+)
+
+// concurrentTestLines are the lines of file i for the concurrency tests; some are longer than searchBufferSize,
+// so the readers have to search past more than one buffer-full
+func concurrentTestLines(i int) []string {
+	lines := make([]string, 0, 20)
+	for j := 0; j < 20; j++ {
+		size := 10 + (i*7+j*13)%50
+		if j%6 == i%6 {
+			size = searchBufferSize + 100*i
+		}
+		prefix := fmt.Sprintf("file %d line %d ", i, j)
+		lines = append(lines, prefix+strings.Repeat(string(rune('a'+i%26)), size)+"\n")
+	}
+
+	return lines
+}
+
+// These run many readers at once, so the race detector can tell if they share any state (like their buffers)
+
+func TestReader_concurrent(t *testing.T) {
+	t.Parallel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			lines := concurrentTestLines(i)
+			file := testutil.NewReadSeeker([]byte(strings.Join(lines, "")))
+			r := NewReader(file)
+
+			if _, err := skipToEnd(file); err != nil {
+				t.Errorf("file %d: skipToEnd() error = %v", i, err)
+				return
+			}
+
+			for j := len(lines) - 1; j >= 0; j-- {
+				got, _, err := r.ReadLineBefore()
+				if err != nil {
+					t.Errorf("file %d: ReadLineBefore() error = %v", i, err)
+					return
+				}
+				if string(got) != lines[j] {
+					t.Errorf("file %d: ReadLineBefore() line %d = %.30q..., want %.30q...", i, j, got, lines[j])
+					return
+				}
+			}
+
+			moved, err := r.MoveLines(5)
+			if err != nil || moved != 5 {
+				t.Errorf("file %d: MoveLines(5) = %d, %v, want 5, nil", i, moved, err)
+				return
+			}
+
+			moved, err = r.MoveLines(-3)
+			if err != nil || moved != -3 {
+				t.Errorf("file %d: MoveLines(-3) = %d, %v, want -3, nil", i, moved, err)
+				return
+			}
+
+			got, _, err := r.ReadLineBefore()
+			if err != nil || string(got) != lines[1] {
+				t.Errorf("file %d: ReadLineBefore() after moving = %.30q..., %v, want %.30q...", i, got, err, lines[1])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTac_concurrent(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir() + "/"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		lines := concurrentTestLines(i)
+		name := fmt.Sprintf("test-%d.log", i)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "")), 0o600); err != nil {
+			t.Fatalf("could not write test file: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var got []string
+			lh := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+				got = append(got, rec.Line)
+				return nil
+			})
+
+			if _, err := Tac(context.Background(), dir, name, lh); err != nil {
+				t.Errorf("Tac(%s) error = %v", name, err)
+				return
+			}
+
+			want := make([]string, 0, len(lines))
+			for j := len(lines) - 1; j >= 0; j-- {
+				want = append(want, lines[j])
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Tac(%s) gave different lines than the file's, backwards", name)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTailFiles_concurrent(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		lines := concurrentTestLines(i)
+		fname := filepath.Join(dir, fmt.Sprintf("test-%d.log", i))
+		if err := os.WriteFile(fname, []byte(strings.Join(lines, "")), 0o600); err != nil {
+			t.Fatalf("could not write test file: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var got []string
+			lh := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+				got = append(got, rec.Line)
+				return nil
+			})
+
+			if _, err := TailFiles(context.Background(), []string{fname}, -7, lh); err != nil {
+				t.Errorf("TailFiles(%s) error = %v", fname, err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, lines[len(lines)-7:]) {
+				t.Errorf("TailFiles(%s) gave different lines than the file's last 7", fname)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReader_ReadLineBefore_blocks(t *testing.T) {
+	t.Parallel()
+
+	// lines around and across block boundaries, including ones longer than a few blocks and blank ones
+	lines := []string{
+		"first\n",
+		strings.Repeat("a", reverseBlockSize-1) + "\n",
+		"\n",
+		strings.Repeat("b", 3*reverseBlockSize+17) + "\n",
+		strings.Repeat("c", reverseBlockSize) + "\n",
+		"\n",
+		"short\n",
+		strings.Repeat("d", 2*reverseBlockSize),
+	}
+	data := []byte(strings.Join(lines, ""))
+
+	tests := []struct {
+		name string
+		file func() io.ReadSeeker
+	}{
+		{
+			name: "memory",
+			file: func() io.ReadSeeker { return testutil.NewReadSeeker(data) },
+		},
+		{
+			name: "file",
+			file: func() io.ReadSeeker {
+				fname := filepath.Join(t.TempDir(), "test.log")
+				if err := os.WriteFile(fname, data, 0o600); err != nil {
+					t.Fatalf("could not write test file: %v", err)
+				}
+				f, err := os.Open(fname)
+				if err != nil {
+					t.Fatalf("could not open test file: %v", err)
+				}
+				t.Cleanup(func() { _ = f.Close() })
+				return f
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			file := tt.file()
+			if _, err := skipToEnd(file); err != nil {
+				t.Fatalf("skipToEnd() error = %v", err)
+			}
+
+			r := NewReader(file)
+			end := int64(len(data))
+			for j := len(lines) - 1; j >= 0; j-- {
+				got, pos, err := r.ReadLineBefore()
+				if err != nil {
+					t.Fatalf("ReadLineBefore() line %d error = %v", j, err)
+				}
+				if string(got) != lines[j] {
+					t.Errorf("ReadLineBefore() line %d = %.20q... (%d bytes), want %.20q... (%d bytes)", j, got, len(got), lines[j], len(lines[j]))
+				}
+				end -= int64(len(lines[j]))
+				if pos != end {
+					t.Errorf("ReadLineBefore() line %d pos = %d, want %d", j, pos, end)
+				}
+			}
+
+			if got, pos, err := r.ReadLineBefore(); len(got) != 0 || pos != 0 || err != nil {
+				t.Errorf("ReadLineBefore() at the start = %q, %d, %v, want empty, 0, nil", got, pos, err)
+			}
+
+			// moving the file picks up from where the lines were read back to
+			if moved, err := r.MoveLines(2); moved != 2 || err != nil {
+				t.Fatalf("MoveLines(2) = %d, %v, want 2, nil", moved, err)
+			}
+			if got, _, err := r.ReadLineBefore(); string(got) != lines[1] || err != nil {
+				t.Errorf("ReadLineBefore() after MoveLines = %.20q..., %v, want %.20q...", got, err, lines[1])
+			}
+		})
+	}
+}
@@ -12,9 +12,12 @@ import (
 )
 
 // TailFollower is a stuct that will spawn a goroutine to scan for not-yet-seen files and otherwise behaves like tail -f
+//
+// Flush, when set, is called whenever the files have no more lines ready (e.g. to flush buffered output while idle)
 type TailFollower struct {
 	FileWatcher *watcher.Watcher
 	LineHandler linehandler.LineHandler
+	Flush       func() error
 }
 
 func (tf *TailFollower) watchForNewFiles(ctx context.Context, newFiles chan string) error {
@@ -29,7 +32,11 @@ func (tf *TailFollower) watchForNewFiles(ctx context.Context, newFiles chan stri
 			}
 
 			for _, path := range tf.FileWatcher.SeenThisTime() {
-				newFiles <- path
+				select {
+				case newFiles <- path:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
 
 			time.Sleep(10 * time.Millisecond)
@@ -38,11 +45,14 @@ func (tf *TailFollower) watchForNewFiles(ctx context.Context, newFiles chan stri
 }
 
 // FollowTail kicks off the file watching goroutine and otherwise behaves like tail -f
+//
+// It stops at the first error reading a file or handling a line (like a closed output), and returns it.
 func (tf *TailFollower) FollowTail(ctx context.Context, lastFile string, lastFilePos int64) (err error) {
 	newFiles := make(chan string, 16)
 	defer close(newFiles)
 
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	var g errgroup.Group
 	g.Go(func() error {
@@ -58,6 +68,8 @@ func (tf *TailFollower) FollowTail(ctx context.Context, lastFile string, lastFil
 		return err
 	})
 
+	lastLineNum := 0 // counted when the last file is first read
+
 SCAN_LOOP:
 	for {
 		select {
@@ -68,19 +80,29 @@ SCAN_LOOP:
 			dirName, fileName := filepath.Split(filePath)
 
 			if filePath == lastFile {
-				lastFilePos, err = CatFrom(ctx, dirName, fileName, lastFilePos, tf.LineHandler)
-				if err != nil {
-					break
-				}
+				lastFilePos, lastLineNum, err = catFrom(ctx, dirName, fileName, lastFilePos, lastLineNum, tf.LineHandler)
 			} else {
 				lastFile = filePath
-				lastFilePos, err = Cat(ctx, dirName, fileName, tf.LineHandler)
-				if err != nil {
-					break
+				lastFilePos, lastLineNum, err = catFrom(ctx, dirName, fileName, 0, 1, tf.LineHandler)
+			}
+
+			if err != nil && ctx.Err() == nil {
+				break SCAN_LOOP
+			}
+
+			if len(newFiles) == 0 && tf.Flush != nil {
+				if err = tf.Flush(); err != nil {
+					break SCAN_LOOP
 				}
 			}
 		}
 	}
 
+	if ctx.Err() == nil { // stopped by an error here, so the watcher has to be stopped too
+		cancel()
+		_ = g.Wait()
+		return err
+	}
+
 	return g.Wait()
 }
@@ -1,6 +1,7 @@
 package streamer
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -225,6 +226,7 @@ test4
 			fp := pattern.NewPattern(fmt.Sprintf("%s/test.log", testDir))
 
 			buffer.Reset()
+			out := bufio.NewWriter(&buffer) // only flushed by the TailFollower, so the lines show up only if it does
 			lp := linehandler.NewLinePrinter(linehandler.Options{
 				WithBlanks:   tt.lpArgs.withBlanks,
 				WithFilename: tt.lpArgs.withFilename,
@@ -232,7 +234,7 @@ test4
 				Pretty:       tt.lpArgs.withPretty,
 				Color:        tt.lpArgs.withColor,
 				Sort:         tt.lpArgs.withSort,
-				Printf:       buffer.Printf,
+				Output:       out,
 			})
 
 			var wg sync.WaitGroup
@@ -282,6 +284,7 @@ test4
 				tf := TailFollower{
 					FileWatcher: watcher.NewWatcher(&fp),
 					LineHandler: lp,
+					Flush:       out.Flush,
 				}
 
 				ctx := context.Background()
@@ -0,0 +1,270 @@
+// Package dedupe contains functionality for collapsing runs of repeated log lines (e.g. from retry loops) into one
+package dedupe
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
+)
+
+// DefaultTimestampFields are where a line's timestamp is looked for when Options.TimestampField is empty, in order
+var DefaultTimestampFields = []string{"timestamp", "@timestamp", "time", "ts"}
+
+// Options controls which lines are duplicates of each other
+//
+// MessageField is the field with the message of a json line ("message" when empty)
+// Fields are the fields that must match, along with the message; when there are none, all fields must match
+// (except the timestamp and IgnoreFields)
+// IgnoreFields are volatile fields (like attempt counters or request ids) that may differ between duplicates
+// TimestampField is the field with the time of a json line (the first of DefaultTimestampFields when empty);
+// it never has to match, and the times of the first and last duplicates are shown in the Suffix
+// Reversed is for lines given last first (as by tac), so First and Last still follow the time order
+// AsFields makes a Handler add collapsed Runs to their json lines as the CountField, FirstField and LastField fields,
+// rather than as a Suffix, so the lines stay json (for machine-readable output)
+type Options struct {
+	MessageField   string
+	Fields         []string
+	IgnoreFields   []string
+	TimestampField string
+	Reversed       bool
+	AsFields       bool
+}
+
+// The fields a Run is added to a json line as (see Options.AsFields)
+const (
+	CountField = "dedupe_count"
+	FirstField = "dedupe_first"
+	LastField  = "dedupe_last"
+)
+
+// Run is a run of consecutive duplicate lines
+//
+// Line is the first of them, Count how many there were, and First and Last the timestamps
+// of the first and last of them (empty for lines without one)
+type Run struct {
+	Line  string
+	Count int
+	First string
+	Last  string
+}
+
+// Suffix is the text shown after the Line of a collapsed run, as in " (×3, 10:00:01..10:00:07)"
+//
+// It is empty when there was only one line
+func (r Run) Suffix() string {
+	switch {
+	case r.Count < 2:
+		return ""
+	case r.First == "" && r.Last == "":
+		return fmt.Sprintf(" (×%d)", r.Count)
+	default:
+		return fmt.Sprintf(" (×%d, %s..%s)", r.Count, r.First, r.Last)
+	}
+}
+
+// WithFields returns line, a json object, with the Run added as the CountField field
+// (and the FirstField and LastField fields, when there are timestamps)
+//
+// It returns line unchanged when there was only one line (like Suffix), and false when line is not a json object
+func (r Run) WithFields(line string) (string, bool) {
+	text := strings.TrimRight(line, " \t\r\n")
+	if !strings.HasPrefix(strings.TrimSpace(text), "{") || !strings.HasSuffix(text, "}") || !gjson.Valid(text) {
+		return line, false
+	}
+
+	if r.Count < 2 {
+		return line, true
+	}
+
+	body := text[:len(text)-1]
+
+	var b strings.Builder
+	b.Grow(len(line) + len(CountField) + len(FirstField) + len(LastField) + len(r.First) + len(r.Last) + 32)
+	b.WriteString(body)
+	if strings.TrimSpace(body) != "{" {
+		b.WriteByte(',')
+	}
+
+	b.WriteString(`"` + CountField + `":`)
+	b.WriteString(strconv.Itoa(r.Count))
+
+	if r.First != "" || r.Last != "" {
+		b.WriteString(`,"` + FirstField + `":`)
+		b.Write(quote(r.First))
+		b.WriteString(`,"` + LastField + `":`)
+		b.Write(quote(r.Last))
+	}
+
+	b.WriteByte('}')
+	b.WriteString(line[len(text):])
+
+	return b.String(), true
+}
+
+// quote gives s as a json string
+func quote(s string) []byte {
+	q, _ := json.Marshal(s) //nolint:errcheck // strings always marshal
+	return q
+}
+
+// Deduper collapses consecutive duplicate lines into Runs
+//
+// Lines are json objects or plain text; plain text lines are duplicates when they are the same
+// (without surrounding whitespace)
+type Deduper struct {
+	messageField string   // a gjson path, with any modifiers escaped
+	fields       []string // gjson paths, with any modifiers escaped
+	ignore       map[string]bool
+	tsFields     []string // where the timestamp is looked for, in order
+	tsPaths      []string // tsFields, as gjson paths with any modifiers escaped
+	reversed     bool
+
+	key     string
+	run     Run
+	keyBuf  []byte
+	pending bool
+}
+
+// NewDeduper creates a new Deduper configured by opts
+func NewDeduper(opts Options) *Deduper {
+	d := &Deduper{
+		messageField: "message",
+		ignore:       map[string]bool{},
+		tsFields:     DefaultTimestampFields,
+		reversed:     opts.Reversed,
+	}
+
+	if opts.MessageField != "" {
+		d.messageField = formatter.EscapeModifiers(opts.MessageField)
+	}
+
+	for _, f := range opts.Fields {
+		d.fields = append(d.fields, formatter.EscapeModifiers(f))
+	}
+
+	if opts.TimestampField != "" {
+		d.tsFields = []string{opts.TimestampField}
+	}
+
+	for _, f := range d.tsFields {
+		d.tsPaths = append(d.tsPaths, formatter.EscapeModifiers(f))
+	}
+
+	for _, f := range opts.IgnoreFields {
+		d.ignore[f] = true
+	}
+
+	return d
+}
+
+// Add adds a line to the current run, or starts a new run with it
+//
+// When the line starts a new run, the previous one (if any) is returned, and should be shown before anything else
+func (d *Deduper) Add(line string) (Run, bool) {
+	text := strings.TrimSpace(line)
+
+	var obj gjson.Result
+	if strings.HasPrefix(text, "{") {
+		obj = gjson.Parse(text)
+	}
+
+	return d.add(line, obj)
+}
+
+// Pending is the number of lines in the current run (0 when there is none)
+func (d *Deduper) Pending() int {
+	if !d.pending {
+		return 0
+	}
+
+	return d.run.Count
+}
+
+// Flush ends the current run and returns it, if there is one
+//
+// This should be called when the stream ends or goes idle, so the run is not held back
+func (d *Deduper) Flush() (Run, bool) {
+	if !d.pending {
+		return Run{}, false
+	}
+
+	run := d.run
+	d.pending, d.key, d.run = false, "", Run{}
+
+	return run, true
+}
+
+// add is Add for a line whose json (when it is an object) is already parsed
+func (d *Deduper) add(line string, obj gjson.Result) (Run, bool) {
+	tsField, ts := d.timestamp(obj)
+	d.keyBuf = d.appendKey(d.keyBuf[:0], line, obj, tsField)
+
+	if d.pending && string(d.keyBuf) == d.key {
+		d.run.Count++
+		if d.reversed {
+			d.run.First = ts
+		} else {
+			d.run.Last = ts
+		}
+		return Run{}, false
+	}
+
+	prev, done := d.Flush()
+	d.key = string(d.keyBuf)
+	d.run = Run{Line: line, Count: 1, First: ts, Last: ts}
+	d.pending = true
+
+	return prev, done
+}
+
+// timestamp finds the timestamp field of a json line, and its value
+func (d *Deduper) timestamp(obj gjson.Result) (string, string) {
+	if !obj.IsObject() {
+		return "", ""
+	}
+
+	for i, f := range d.tsFields {
+		if res := obj.Get(d.tsPaths[i]); res.Exists() {
+			return f, res.String()
+		}
+	}
+
+	return "", ""
+}
+
+// appendKey appends what has to match for lines to be duplicates to dst
+func (d *Deduper) appendKey(dst []byte, line string, obj gjson.Result, tsField string) []byte {
+	if !obj.IsObject() {
+		return append(dst, strings.TrimSpace(line)...)
+	}
+
+	if len(d.fields) > 0 {
+		dst = append(dst, obj.Get(d.messageField).Raw...)
+		for _, f := range d.fields {
+			dst = append(dst, 0)
+			dst = append(dst, obj.Get(f).Raw...)
+		}
+
+		return dst
+	}
+
+	obj.ForEach(func(k, v gjson.Result) bool {
+		if k.String() == tsField || d.ignore[k.String()] {
+			return true
+		}
+
+		dst = append(dst, k.Raw...)
+		dst = append(dst, ':')
+		dst = append(dst, v.Raw...)
+		dst = append(dst, 0)
+
+		return true
+	})
+
+	return dst
+}
@@ -0,0 +1,191 @@
+package dedupe
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeduper_Add(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		opts  Options
+		lines []string
+		want  []Run
+	}{
+		{
+			name:  "plain text",
+			lines: []string{"retrying", " retrying ", "retrying", "done", "retrying"},
+			want: []Run{
+				{Line: "retrying", Count: 3},
+				{Line: "done", Count: 1},
+				{Line: "retrying", Count: 1},
+			},
+		},
+		{
+			name: "ignoring the timestamp",
+			lines: []string{
+				`{"ts": "10:00:01", "message": "retrying", "host": "a"}`,
+				`{"ts": "10:00:02", "message": "retrying", "host": "a"}`,
+				`{"ts": "10:00:04", "message": "retrying", "host": "b"}`,
+			},
+			want: []Run{
+				{Line: `{"ts": "10:00:01", "message": "retrying", "host": "a"}`, Count: 2, First: "10:00:01", Last: "10:00:02"},
+				{Line: `{"ts": "10:00:04", "message": "retrying", "host": "b"}`, Count: 1, First: "10:00:04", Last: "10:00:04"},
+			},
+		},
+		{
+			name: "fields named like modifiers",
+			opts: Options{MessageField: "@this", Fields: []string{"@keys"}},
+			lines: []string{
+				`{"@this": "retrying", "@keys": "a", "n": 1}`,
+				`{"@this": "retrying", "@keys": "a", "n": 2}`,
+				`{"@this": "retrying", "@keys": "b", "n": 3}`,
+			},
+			want: []Run{
+				{Line: `{"@this": "retrying", "@keys": "a", "n": 1}`, Count: 2},
+				{Line: `{"@this": "retrying", "@keys": "b", "n": 3}`, Count: 1},
+			},
+		},
+		{
+			name: "reversed",
+			opts: Options{Reversed: true},
+			lines: []string{
+				`{"ts": "10:00:03", "message": "retrying"}`,
+				`{"ts": "10:00:02", "message": "retrying"}`,
+				`{"ts": "10:00:01", "message": "retrying"}`,
+			},
+			want: []Run{
+				{Line: `{"ts": "10:00:03", "message": "retrying"}`, Count: 3, First: "10:00:01", Last: "10:00:03"},
+			},
+		},
+		{
+			name: "ignoring volatile fields",
+			opts: Options{IgnoreFields: []string{"attempt"}, TimestampField: "at"},
+			lines: []string{
+				`{"at": 1, "message": "retrying", "attempt": 1}`,
+				`{"at": 2, "message": "retrying", "attempt": 2}`,
+				`{"at": 3, "message": "retrying", "attempt": 3}`,
+			},
+			want: []Run{
+				{Line: `{"at": 1, "message": "retrying", "attempt": 1}`, Count: 3, First: "1", Last: "3"},
+			},
+		},
+		{
+			name: "chosen fields",
+			opts: Options{MessageField: "msg", Fields: []string{"host"}},
+			lines: []string{
+				`{"msg": "retrying", "host": "a", "attempt": 1}`,
+				`{"msg": "retrying", "host": "a", "attempt": 2}`,
+				`{"msg": "retrying", "host": "b", "attempt": 3}`,
+				`{"msg": "failed", "host": "b", "attempt": 3}`,
+			},
+			want: []Run{
+				{Line: `{"msg": "retrying", "host": "a", "attempt": 1}`, Count: 2},
+				{Line: `{"msg": "retrying", "host": "b", "attempt": 3}`, Count: 1},
+				{Line: `{"msg": "failed", "host": "b", "attempt": 3}`, Count: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			d := NewDeduper(tt.opts)
+
+			var got []Run
+			for _, line := range tt.lines {
+				if run, ok := d.Add(line); ok {
+					got = append(got, run)
+				}
+			}
+
+			if run, ok := d.Flush(); ok {
+				got = append(got, run)
+			}
+
+			if _, ok := d.Flush(); ok {
+				t.Errorf("Flush() returned a run twice")
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("runs = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_Suffix(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		run  Run
+		want string
+	}{
+		{name: "single", run: Run{Count: 1, First: "a", Last: "a"}, want: ""},
+		{name: "no timestamps", run: Run{Count: 3}, want: " (×3)"},
+		{name: "timestamps", run: Run{Count: 2, First: "10:00:01", Last: "10:00:05"}, want: " (×2, 10:00:01..10:00:05)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.run.Suffix(); got != tt.want {
+				t.Errorf("Suffix() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_WithFields(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		run    Run
+		line   string
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "timestamps",
+			run:    Run{Count: 2, First: "10:00:01", Last: "10:00:05"},
+			line:   "{\"ts\": \"10:00:01\", \"message\": \"retrying\"}\n",
+			want:   "{\"ts\": \"10:00:01\", \"message\": \"retrying\",\"dedupe_count\":2,\"dedupe_first\":\"10:00:01\",\"dedupe_last\":\"10:00:05\"}\n",
+			wantOk: true,
+		},
+		{
+			name:   "single",
+			run:    Run{Count: 1, First: "1", Last: "1"},
+			line:   `{"message": "done"}`,
+			want:   `{"message": "done"}`,
+			wantOk: true,
+		},
+		{
+			name:   "empty object",
+			run:    Run{Count: 3},
+			line:   ` { } `,
+			want:   ` { "dedupe_count":3} `,
+			wantOk: true,
+		},
+		{
+			name:   "quoted timestamps",
+			run:    Run{Count: 2, First: `a"b`, Last: "c"},
+			line:   `{}`,
+			want:   `{"dedupe_count":2,"dedupe_first":"a\"b","dedupe_last":"c"}`,
+			wantOk: true,
+		},
+		{name: "plain text", run: Run{Count: 2}, line: "retrying {}\n", want: "retrying {}\n"},
+		{name: "array", run: Run{Count: 2}, line: "[{}]", want: "[{}]"},
+		{name: "broken json", run: Run{Count: 2}, line: `{"message": }`, want: `{"message": }`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := tt.run.WithFields(tt.line)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("WithFields() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
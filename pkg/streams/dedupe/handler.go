@@ -0,0 +1,73 @@
+package dedupe
+
+import (
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+)
+
+// Handler is a linehandler.LineHandler that collapses consecutive duplicate lines into their first one,
+// with the Run's Suffix (or, with Options.AsFields, its fields for json lines)
+//
+// Runs end at the end of a file, so lines from different files are never collapsed together.
+// A run is only passed along once it ends, so Flush must be called when a file ends or the lines go idle.
+type Handler struct {
+	next     linehandler.LineHandler
+	deduper  *Deduper
+	asFields bool
+	first    linehandler.Record
+}
+
+// NewHandler wraps next in a Handler configured by opts
+func NewHandler(next linehandler.LineHandler, opts Options) *Handler {
+	return &Handler{
+		next:     next,
+		deduper:  NewDeduper(opts),
+		asFields: opts.AsFields,
+	}
+}
+
+// HandleLine holds on to the line if it starts a run, and passes along any run it ends
+func (h *Handler) HandleLine(rec *linehandler.Record) error {
+	if h.deduper.Pending() > 0 && rec.Path != h.first.Path {
+		if err := h.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if run, ok := h.deduper.add(rec.Line, rec.JSON()); ok {
+		if err := h.send(run); err != nil {
+			return err
+		}
+	}
+
+	if h.deduper.Pending() == 1 {
+		h.first = rec.Copy()
+	}
+
+	return nil
+}
+
+// Flush passes along the current run, if there is one
+func (h *Handler) Flush() error {
+	run, ok := h.deduper.Flush()
+	if !ok {
+		return nil
+	}
+
+	return h.send(run)
+}
+
+func (h *Handler) send(run Run) error {
+	rec := h.first
+	h.first = linehandler.Record{}
+
+	if h.asFields {
+		if line, ok := run.WithFields(rec.Line); ok {
+			rec.Line = line
+			return h.next.HandleLine(&rec)
+		}
+	}
+
+	rec.Suffix += run.Suffix()
+
+	return h.next.HandleLine(&rec)
+}
@@ -0,0 +1,87 @@
+package dedupe
+
+import (
+	"testing"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/testutil"
+)
+
+func TestHandler_HandleLine(t *testing.T) {
+	t.Parallel()
+	buffer := testutil.NewPrintfBuffer(1024)
+	lp := linehandler.NewLinePrinter(linehandler.Options{
+		WithFilename:    true,
+		WithLineNumbers: true,
+		JSONPath:        "message",
+		Output:          &buffer,
+	})
+	h := NewHandler(lp, Options{})
+
+	lines := []struct {
+		path string
+		line string
+	}{
+		{"a.log", "{\"ts\": \"1\", \"message\": \"retrying\"}\n"},
+		{"a.log", "{\"ts\": \"2\", \"message\": \"retrying\"}\n"},
+		{"a.log", "{\"ts\": \"3\", \"message\": \"retrying\"}\n"},
+		{"b.log", "{\"ts\": \"4\", \"message\": \"retrying\"}\n"},
+		{"b.log", "{\"ts\": \"5\", \"message\": \"retrying\"}\n"},
+		{"b.log", "{\"ts\": \"6\", \"message\": \"done\"}\n"},
+	}
+
+	rec := &linehandler.Record{}
+	for i, l := range lines {
+		*rec = linehandler.Record{Path: l.path, Line: l.line, Number: i + 1, Raw: []byte(l.line)} // reused, like the streamer does
+		if err := h.HandleLine(rec); err != nil {
+			t.Errorf("HandleLine() error = %v", err)
+		}
+	}
+
+	want := "a.log:1: retrying (×3, 1..3)\nb.log:4: retrying (×2, 4..5)\n"
+	if got := string(buffer.GetData()); got != want {
+		t.Errorf("HandleLine() output = %q, want %q", got, want)
+	}
+
+	if err := h.Flush(); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+
+	want += "b.log:6: done\n"
+	if got := string(buffer.GetData()); got != want {
+		t.Errorf("Flush() output = %q, want %q", got, want)
+	}
+}
+
+func TestHandler_HandleLine_asFields(t *testing.T) {
+	t.Parallel()
+	buffer := testutil.NewPrintfBuffer(1024)
+	lp := linehandler.NewLinePrinter(linehandler.Options{
+		JSONPath: "message,dedupe_count,dedupe_first,dedupe_last,|@csv",
+		Output:   &buffer,
+	})
+	h := NewHandler(lp, Options{AsFields: true})
+
+	lines := []string{
+		"{\"ts\": \"1\", \"message\": \"retrying\"}\n",
+		"{\"ts\": \"2\", \"message\": \"retrying\"}\n",
+		"{\"ts\": \"3\", \"message\": \"done\"}\n",
+	}
+
+	rec := &linehandler.Record{}
+	for i, l := range lines {
+		*rec = linehandler.Record{Path: "a.log", Line: l, Number: i + 1, Raw: []byte(l)}
+		if err := h.HandleLine(rec); err != nil {
+			t.Errorf("HandleLine() error = %v", err)
+		}
+	}
+
+	if err := h.Flush(); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+
+	want := "retrying,2,1,2\ndone,,,\n"
+	if got := string(buffer.GetData()); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/gsmcwhirter/prettify/pkg/testutil"
+)
+
+const benchSelectors = "timestamp,level,message,trace_id,status,headers,|@tsv"
+
+func BenchmarkFormatLine(b *testing.B) {
+	for _, c := range testutil.LogCorpora(1000) {
+		c := c
+		lines := make([]string, len(c.Lines))
+		for i, l := range c.Lines {
+			lines[i] = string(l)
+		}
+
+		b.Run(c.Name, func(b *testing.B) {
+			b.SetBytes(c.AverageLineSize())
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = FormatLine(lines[i%len(lines)], benchSelectors, false, false, false)
+			}
+		})
+	}
+}
+
+func BenchmarkExpression_AppendFormat(b *testing.B) {
+	for _, c := range testutil.LogCorpora(1000) {
+		c := c
+		b.Run(c.Name, func(b *testing.B) {
+			e, err := Compile(benchSelectors)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var buf []byte
+
+			b.SetBytes(c.AverageLineSize())
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf = e.AppendFormat(buf[:0], c.Lines[i%len(c.Lines)], false, false, false)
+			}
+		})
+	}
+}
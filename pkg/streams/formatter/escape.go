@@ -0,0 +1,161 @@
+package formatter
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gsmcwhirter/prettify/internal/textutil"
+)
+
+// csvSpecials are the characters that make RFC 4180 quote a field
+const csvSpecials = ",\"\r\n"
+
+// tsvSpecials are the characters TSV (and |@table) has to escape (with a backslash)
+const tsvSpecials = "\t\n\r\\"
+
+// markdownSpecials are the characters that would break a Markdown table cell
+const markdownSpecials = "|\r\n"
+
+func needsEscape(value string, separatorType gJSONOutputType) bool {
+	switch separatorType {
+	case csv:
+		return strings.ContainsAny(value, csvSpecials)
+	case tsv, table:
+		return strings.ContainsAny(value, tsvSpecials)
+	case markdown:
+		return strings.ContainsAny(value, markdownSpecials)
+	case logfmt:
+		return needsLogfmtQuote(value)
+	default:
+		return false
+	}
+}
+
+// appendField appends a single value to dst, escaped as the separator type needs
+//
+// csv fields are quoted (doubling any quotes) when they contain a comma, quote or line break,
+// tsv and table fields have tabs, line breaks and backslashes escaped as \t, \n, \r and \\,
+// markdown cells have pipes escaped and line breaks turned into <br>, and logfmt values are
+// quoted (Go-style) when they contain spaces, quotes, equals signs or control characters
+func appendField(dst []byte, value string, separatorType gJSONOutputType) []byte {
+	if !needsEscape(value, separatorType) {
+		return append(dst, value...)
+	}
+
+	switch separatorType {
+	case csv:
+		dst = append(dst, '"')
+		for i := 0; i < len(value); i++ {
+			if value[i] == '"' {
+				dst = append(dst, '"')
+			}
+			dst = append(dst, value[i])
+		}
+		return append(dst, '"')
+	case markdown:
+		for i := 0; i < len(value); i++ {
+			switch c := value[i]; c {
+			case '|':
+				dst = append(dst, `\|`...)
+			case '\n':
+				dst = append(dst, "<br>"...)
+			case '\r':
+			default:
+				dst = append(dst, c)
+			}
+		}
+		return dst
+	case logfmt:
+		return strconv.AppendQuote(dst, value)
+	}
+
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\t':
+			dst = append(dst, `\t`...)
+		case '\n':
+			dst = append(dst, `\n`...)
+		case '\r':
+			dst = append(dst, `\r`...)
+		case '\\':
+			dst = append(dst, `\\`...)
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return dst
+}
+
+// escapeTail escapes dst[start:] in place, for a value that was appended to dst unescaped
+func escapeTail(dst []byte, start int, separatorType gJSONOutputType) []byte {
+	if !needsEscape(textutil.BytesToString(dst[start:]), separatorType) {
+		return dst
+	}
+
+	end := len(dst)
+	// the escaped copy goes after the original (which stays intact even if append moves dst), then replaces it
+	dst = appendField(dst, textutil.BytesToString(dst[start:end]), separatorType)
+	n := copy(dst[start:], dst[end:])
+
+	return dst[:start+n]
+}
+
+func escapeField(value string, separatorType gJSONOutputType) string {
+	if !needsEscape(value, separatorType) {
+		return value
+	}
+
+	return string(appendField(nil, value, separatorType))
+}
+
+func needsLogfmtQuote(value string) bool {
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appendLogfmtKey appends a selector as a logfmt key, replacing the characters keys cannot hold with _
+func appendLogfmtKey(dst []byte, key string) []byte {
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			c = '_'
+		}
+		dst = append(dst, c)
+	}
+
+	return dst
+}
+
+// appendJSONString appends s as a quoted json string
+func appendJSONString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	dst = append(dst, '"')
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c == '\n':
+			dst = append(dst, `\n`...)
+		case c == '\r':
+			dst = append(dst, `\r`...)
+		case c == '\t':
+			dst = append(dst, `\t`...)
+		case c < ' ':
+			dst = append(dst, `\u00`...)
+			dst = append(dst, hex[c>>4], hex[c&0xf])
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return append(dst, '"')
+}
@@ -0,0 +1,553 @@
+package formatter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/pretty"
+
+	"github.com/gsmcwhirter/prettify/internal/textutil"
+)
+
+// outputFormat is what an output format directive (like |@csv:header) asks for
+type outputFormat struct {
+	separatorType gJSONOutputType
+	header        bool
+}
+
+var outputFormats = map[string]outputFormat{
+	"|@ssv":        {separatorType: space},
+	"|@csv":        {separatorType: csv},
+	"|@csv:header": {separatorType: csv, header: true},
+	"|@tsv":        {separatorType: tsv},
+	"|@tsv:header": {separatorType: tsv, header: true},
+	"|@nlsv":       {separatorType: nlsv},
+	"|@json":       {separatorType: jsonObject},
+	"|@logfmt":     {separatorType: logfmt},
+	"|@table":      {separatorType: table, header: true},
+	"|@markdown":   {separatorType: markdown, header: true},
+}
+
+// formatNames lists the output formats for error messages
+const formatNames = "|@ssv, |@csv, |@tsv, |@nlsv, |@csv:header, |@tsv:header, |@json, |@logfmt, |@table or |@markdown"
+
+// SyntaxError describes a problem with an output expression
+//
+// Column is the 1-based byte position in Expr where the problem was found
+type SyntaxError struct {
+	Expr   string
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid output expression %q at column %d: %s", e.Expr, e.Column, e.Msg)
+}
+
+// Expression is a parsed output expression: comma-separated gjson selectors and an optional output format
+//
+// Commas only separate selectors outside of brackets, braces, parentheses and double quotes, so gjson
+// queries like `items.#(status=="ok")#.id` and multipaths like `{a,b}` can be used. Any other comma (or a
+// leading |, for a field named like an output format) can be escaped with a backslash, as in `k\,1`.
+//
+// The separated formats are |@ssv, |@csv, |@tsv and |@nlsv (the default). |@csv values are quoted as RFC 4180
+// requires, and |@tsv values have tabs, line breaks and backslashes escaped. |@csv:header and |@tsv:header
+// additionally ask for a header row of the selectors.
+//
+// The structured formats are |@json (an object keyed by selector), |@logfmt (selector=value pairs),
+// |@table (columns aligned with spaces, under a header row) and |@markdown (a Markdown table).
+//
+// A selector may end with functions applied to its value, like `message|trunc(80)|upper`, and may be
+// renamed (for headers and keys) with `as`, like `@timestamp|time(kitchen) as time`. The functions are
+// upper, lower, len, trunc(n), time(layout), default(x), json and keys.
+//
+// gjson modifiers must be allowed in the CompileOptions to be used.
+type Expression struct {
+	source        string
+	selectors     []selector
+	separatorType gJSONOutputType
+	header        bool
+	modifiers     map[string]bool // the gjson modifiers allowed in the selectors
+}
+
+// selector is one field of an output expression
+type selector struct {
+	path  string     // the gjson path as written
+	query string     // path, with the modifiers that are not allowed escaped
+	name  string     // the alias, or the selector as written
+	funcs []function // applied in order to the value found
+}
+
+// newSelector creates the selector for path, also returning the first modifier in it that is not allowed (if any)
+func newSelector(path, name string, modifiers map[string]bool) (selector, string) {
+	query, disallowed := guardModifiers(path, modifiers)
+	return selector{path: path, query: query, name: name}, disallowed
+}
+
+// get finds the selected value in a json line
+func (sel *selector) get(s string) gjson.Result {
+	res := gjson.Get(s, sel.query)
+	for _, fn := range sel.funcs {
+		res = fn(res)
+	}
+
+	return res
+}
+
+// parseSelector reads a selector with its functions and alias; error columns are 0-based offsets into text
+//
+// modifiers are the gjson modifiers the selector may use
+func parseSelector(text string, modifiers map[string]bool) (selector, *SyntaxError) {
+	if strings.HasSuffix(text, " as") {
+		return selector{}, &SyntaxError{Column: len(text) - len("as"), Msg: "missing name after as"}
+	}
+
+	path, name := text, text
+	if i := strings.LastIndex(text, " as "); i >= 0 {
+		if alias := strings.TrimSpace(text[i+len(" as "):]); !strings.ContainsAny(alias, `()"|`) { // otherwise it is part of a function argument
+			path, name = strings.TrimSpace(text[:i]), alias
+		}
+	}
+
+	parts, err := tokenize(path, '|')
+	if err != nil {
+		return selector{}, err
+	}
+
+	first := len(parts)
+	for first > 1 {
+		fnName, _, _ := splitCall(parts[first-1].text)
+		if _, ok := functions[fnName]; !ok {
+			break
+		}
+		first--
+	}
+
+	var funcs []function
+	for _, part := range parts[first:] {
+		fnName, args, ok := splitCall(part.text)
+		if !ok {
+			return selector{}, &SyntaxError{Column: part.start, Msg: fmt.Sprintf("unexpected text after the %s arguments", fnName)}
+		}
+
+		fn, err := functions[fnName](args)
+		if err != nil {
+			return selector{}, &SyntaxError{Column: part.start, Msg: fmt.Sprintf("%s %s", fnName, err)}
+		}
+
+		funcs = append(funcs, fn)
+	}
+
+	if first < len(parts) {
+		path = strings.TrimSpace(path[:parts[first].start-1])
+	}
+
+	if path == "" {
+		return selector{}, &SyntaxError{Msg: "missing field before functions"}
+	}
+
+	sel, disallowed := newSelector(path, name, modifiers)
+	if disallowed != "" {
+		return selector{}, &SyntaxError{Column: strings.Index(text, "@"+disallowed), Msg: fmt.Sprintf("gjson modifier @%s is not allowed", disallowed)}
+	}
+	sel.funcs = funcs
+
+	return sel, nil
+}
+
+// splitCall splits a function call like `trunc(10)` into its name and arguments (nil without parentheses)
+//
+// ok is false when the closing parenthesis is missing
+func splitCall(s string) (name string, args []string, ok bool) {
+	s = strings.TrimSpace(s)
+
+	open := strings.IndexByte(s, '(')
+	if open < 0 {
+		return s, nil, true
+	}
+
+	name = strings.TrimSpace(s[:open])
+	if !strings.HasSuffix(s, ")") {
+		return name, nil, false
+	}
+
+	inner := s[open+1 : len(s)-1]
+	args = []string{}
+	if strings.TrimSpace(inner) == "" {
+		return name, args, true
+	}
+
+	parts, _ := tokenize(inner, ',') // the call as a whole was already checked
+	for _, seg := range parts {
+		args = append(args, unquoteArg(strings.TrimSpace(seg.text)))
+	}
+
+	return name, args, true
+}
+
+// unquoteArg removes the quotes around a function argument, if it has them
+func unquoteArg(arg string) string {
+	if len(arg) < 2 {
+		return arg
+	}
+
+	if arg[0] == '"' {
+		if s, err := strconv.Unquote(arg); err == nil {
+			return s
+		}
+	}
+
+	return arg
+}
+
+// Compile parses and checks an output expression once, so it can be used for many lines
+//
+// It allows no gjson modifiers; see CompileWith.
+func Compile(expr string) (*Expression, error) {
+	return CompileWith(expr, CompileOptions{})
+}
+
+// CompileWith is Compile, configured by opts
+func CompileWith(expr string, opts CompileOptions) (*Expression, error) {
+	modifiers, merr := opts.allowedModifiers()
+	if merr != nil {
+		return nil, merr
+	}
+
+	e := &Expression{source: expr, separatorType: nlsv, modifiers: modifiers}
+
+	components, err := tokenize(expr, ',')
+	if err != nil {
+		err.Expr = expr
+		err.Column++
+		return nil, err
+	}
+
+	formatSeen := false
+
+	for _, component := range components {
+		trimmed := strings.TrimSpace(component.text)
+		column := component.start + strings.Index(component.text, trimmed) + 1
+		if trimmed == "" {
+			column = component.start + 1
+		}
+
+		switch {
+		case trimmed == "":
+			return nil, &SyntaxError{Expr: expr, Column: column, Msg: "empty selector"}
+		case strings.HasPrefix(trimmed, "|"):
+			format, ok := outputFormats[strings.ToLower(trimmed)]
+			if !ok {
+				return nil, &SyntaxError{Expr: expr, Column: column, Msg: fmt.Sprintf("unknown output format %q (want one of %s)", trimmed, formatNames)}
+			}
+
+			if formatSeen {
+				return nil, &SyntaxError{Expr: expr, Column: column, Msg: "only one output format may be given"}
+			}
+
+			formatSeen = true
+			e.separatorType = format.separatorType
+			e.header = format.header
+		default:
+			sel, err := parseSelector(trimmed, e.modifiers)
+			if err != nil {
+				err.Expr = expr
+				err.Column += column
+				return nil, err
+			}
+
+			e.selectors = append(e.selectors, sel)
+		}
+	}
+
+	if len(e.selectors) == 0 {
+		return nil, &SyntaxError{Expr: expr, Column: 1, Msg: "no fields selected"}
+	}
+
+	return e, nil
+}
+
+// ParseExpression is the lenient form of Compile (as used by FormatLine), which never fails
+//
+// Empty selectors are kept, unknown output formats and selectors with bad functions are treated as plain paths,
+// and the last output format wins. gjson modifiers are never run (they are looked up as field names).
+func ParseExpression(formatSelectors string) *Expression {
+	e := &Expression{
+		source:        formatSelectors,
+		selectors:     make([]selector, 0),
+		separatorType: nlsv,
+	}
+
+	components, _ := tokenize(formatSelectors, ',')
+	for _, tok := range components {
+		component := strings.TrimSpace(tok.text)
+
+		if format, ok := outputFormats[strings.ToLower(component)]; ok {
+			e.separatorType = format.separatorType
+			e.header = format.header
+			continue
+		}
+
+		sel, err := parseSelector(component, nil)
+		if err != nil {
+			sel, _ = newSelector(component, component, nil) // any modifiers are just field names here
+		}
+
+		e.selectors = append(e.selectors, sel)
+	}
+
+	return e
+}
+
+// paths lists the gjson paths of the selectors, as written
+func (e *Expression) paths() []string {
+	paths := make([]string, len(e.selectors))
+	for i := range e.selectors {
+		paths[i] = e.selectors[i].path
+	}
+
+	return paths
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// HasHeader reports whether the output should start with a header row (see AppendHeader)
+func (e *Expression) HasHeader() bool {
+	return e.header
+}
+
+// AppendHeader appends the header row (the selector names, escaped like values) to dst
+//
+// For |@markdown this is two lines: the column names and the line separating them from the rows.
+func (e *Expression) AppendHeader(dst []byte) []byte {
+	switch e.separatorType {
+	case markdown:
+		for i := range e.selectors {
+			dst = append(dst, "| "...)
+			dst = appendField(dst, e.selectors[i].name, markdown)
+			dst = append(dst, ' ')
+		}
+		dst = append(dst, "|\n"...)
+
+		for range e.selectors {
+			dst = append(dst, "| --- "...)
+		}
+		return append(dst, '|')
+	case table:
+		for i := range e.selectors {
+			if i > 0 {
+				dst = append(dst, tableGap...)
+			}
+			dst = appendField(dst, e.selectors[i].name, table)
+		}
+		return dst
+	}
+
+	for i := range e.selectors {
+		if i > 0 {
+			dst = appendSeparator(dst, e.separatorType)
+		}
+
+		dst = appendField(dst, e.selectors[i].name, e.separatorType)
+	}
+
+	return dst
+}
+
+// Format applies the expression to a json line, producing compact, uncolored output
+func (e *Expression) Format(line []byte) []byte {
+	return e.AppendFormat(nil, line, false, false, false)
+}
+
+// AppendFormat applies the expression to a json line, appending the output to dst
+//
+// Selected strings and (uncolored) scalars are copied straight from the line, so only json values
+// that need reformatting allocate. line is not retained.
+//
+// |@table columns are only padded to the width of their header here; a Stream also keeps them
+// aligned with the widest value seen so far.
+func (e *Expression) AppendFormat(dst, line []byte, prettyFmt, withColor, sortKeys bool) []byte {
+	return e.appendFormat(dst, line, prettyFmt, withColor, &PrettyOptions{SortKeys: sortKeys}, nil)
+}
+
+func (e *Expression) appendFormat(dst, line []byte, prettyFmt, withColor bool, popts *PrettyOptions, widths []int) []byte {
+	s := textutil.BytesToString(line)
+
+	switch e.separatorType {
+	case jsonObject:
+		return e.appendJSON(dst, s, prettyFmt, withColor, popts)
+	case logfmt, table, markdown:
+		prettyFmt = false // values have to stay on one line
+	}
+
+	if e.separatorType == markdown {
+		dst = append(dst, "| "...)
+	}
+
+	for i := range e.selectors {
+		sel := &e.selectors[i]
+		if i > 0 {
+			dst = e.appendGap(dst)
+		}
+
+		if e.separatorType == logfmt {
+			dst = appendLogfmtKey(dst, sel.name)
+			dst = append(dst, '=')
+		}
+
+		start := len(dst)
+		dst = appendResult(dst, sel.get(s), prettyFmt, withColor, popts)
+		dst = escapeTail(dst, start, e.separatorType)
+
+		if e.separatorType == table {
+			width := utf8.RuneCount(dst[start:])
+			if widths == nil {
+				widths = e.headerWidths()
+			}
+			if width > widths[i] {
+				widths[i] = width
+			}
+
+			if i < len(e.selectors)-1 { // pad up to the column width (no trailing spaces on the last column)
+				dst = appendSpaces(dst, widths[i]-width)
+			}
+		}
+	}
+
+	if e.separatorType == markdown {
+		dst = append(dst, " |"...)
+	}
+
+	return dst
+}
+
+// tableGap separates |@table columns
+const tableGap = "  "
+
+func (e *Expression) appendGap(dst []byte) []byte {
+	switch e.separatorType {
+	case logfmt:
+		return append(dst, ' ')
+	case table:
+		return append(dst, tableGap...)
+	case markdown:
+		return append(dst, " | "...)
+	default:
+		return appendSeparator(dst, e.separatorType)
+	}
+}
+
+func (e *Expression) headerWidths() []int {
+	widths := make([]int, len(e.selectors))
+	for i := range e.selectors {
+		widths[i] = utf8.RuneCountInString(escapeField(e.selectors[i].name, table))
+	}
+
+	return widths
+}
+
+func appendSpaces(dst []byte, n int) []byte {
+	for ; n > 0; n-- {
+		dst = append(dst, ' ')
+	}
+
+	return dst
+}
+
+// appendResult appends the text of a selected value: strings unquoted, and json compacted (or pretty) and maybe colored
+func appendResult(dst []byte, res gjson.Result, prettyFmt, withColor bool, popts *PrettyOptions) []byte {
+	switch {
+	case !res.Exists():
+		return dst
+	case res.Type == gjson.String:
+		return append(dst, res.String()...)
+	case res.Type != gjson.JSON && !withColor:
+		return textutil.AppendScalar(dst, res)
+	case prettyFmt:
+		return popts.AppendPretty(dst, []byte(res.String()), withColor)
+	default:
+		return popts.AppendUgly(dst, []byte(res.String()), withColor)
+	}
+}
+
+// appendJSON appends an object with a member per selector (null when the selector finds nothing)
+//
+// It stays json in YAML mode, as |@json asks for json.
+func (e *Expression) appendJSON(dst []byte, s string, prettyFmt, withColor bool, popts *PrettyOptions) []byte {
+	start := len(dst)
+
+	dst = append(dst, '{')
+	for i := range e.selectors {
+		sel := &e.selectors[i]
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+
+		dst = appendJSONString(dst, sel.name)
+		dst = append(dst, ':')
+
+		res := sel.get(s)
+		switch {
+		case !res.Exists():
+			dst = append(dst, "null"...)
+		case res.Type == gjson.JSON:
+			dst = append(dst, pretty.Ugly([]byte(res.Raw))...)
+		default:
+			dst = append(dst, res.Raw...)
+		}
+	}
+	dst = append(dst, '}')
+
+	if !prettyFmt && !withColor && !popts.SortKeys && len(popts.PinnedKeys) == 0 {
+		return dst
+	}
+
+	obj := append([]byte(nil), dst[start:]...)
+	if prettyFmt {
+		jsonOpts := *popts
+		jsonOpts.YAML = false
+		return jsonOpts.AppendPretty(dst[:start], obj, withColor)
+	}
+
+	return popts.AppendUgly(dst[:start], obj, withColor)
+}
+
+// Stream formats the lines of one output stream, keeping the state some formats need between lines
+// (the column widths of |@table, so columns stay aligned as wider values show up)
+type Stream struct {
+	expr   *Expression
+	widths []int
+	pretty PrettyOptions
+}
+
+// NewStream starts a new output stream for the expression
+func (e *Expression) NewStream() *Stream {
+	return &Stream{
+		expr:   e,
+		widths: e.headerWidths(),
+	}
+}
+
+// Expression returns the expression the stream formats with
+func (st *Stream) Expression() *Expression {
+	return st.expr
+}
+
+// SetPrettyOptions sets how the stream pretty-prints json values (the sortKeys of AppendFormat still applies)
+func (st *Stream) SetPrettyOptions(opts PrettyOptions) {
+	st.pretty = opts
+}
+
+// AppendFormat is Expression.AppendFormat, keeping |@table columns aligned across the stream
+func (st *Stream) AppendFormat(dst, line []byte, prettyFmt, withColor, sortKeys bool) []byte {
+	popts := st.pretty
+	popts.SortKeys = popts.SortKeys || sortKeys
+
+	return st.expr.appendFormat(dst, line, prettyFmt, withColor, &popts, st.widths)
+}
@@ -0,0 +1,257 @@
+package formatter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		expr       string
+		wantPaths  []string
+		wantType   gJSONOutputType
+		wantColumn int
+	}{
+		{
+			name:      "fields",
+			expr:      "foo, bar",
+			wantPaths: []string{"foo", "bar"},
+			wantType:  nlsv,
+		},
+		{
+			name:      "format",
+			expr:      "@timestamp, message, |@TSV",
+			wantPaths: []string{"@timestamp", "message"},
+			wantType:  tsv,
+		},
+		{
+			name:       "empty",
+			expr:       "",
+			wantColumn: 1,
+		},
+		{
+			name:       "empty selector",
+			expr:       "foo,,bar",
+			wantColumn: 5,
+		},
+		{
+			name:       "trailing comma",
+			expr:       "foo, bar,",
+			wantColumn: 10,
+		},
+		{
+			name:       "unknown format",
+			expr:       "foo, |@xml",
+			wantColumn: 6,
+		},
+		{
+			name:       "two formats",
+			expr:       "foo,|@csv,|@tsv",
+			wantColumn: 11,
+		},
+		{
+			name:       "only a format",
+			expr:       "|@csv",
+			wantColumn: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := Compile(tt.expr)
+
+			if tt.wantColumn > 0 {
+				var serr *SyntaxError
+				if !errors.As(err, &serr) {
+					t.Fatalf("Compile() error = %v, want a *SyntaxError", err)
+				}
+				if serr.Column != tt.wantColumn {
+					t.Errorf("Compile() error column = %v, want %v (%v)", serr.Column, tt.wantColumn, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.paths(), tt.wantPaths) {
+				t.Errorf("Compile() paths = %v, want %v", got.paths(), tt.wantPaths)
+			}
+			if got.separatorType != tt.wantType {
+				t.Errorf("Compile() type = %v, want %v", got.separatorType, tt.wantType)
+			}
+			if got.String() != tt.expr {
+				t.Errorf("String() = %v, want %v", got.String(), tt.expr)
+			}
+		})
+	}
+}
+
+func TestExpression_Format(t *testing.T) {
+	t.Parallel()
+	e, err := Compile("a,b.c,missing,n,|@csv")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	got := string(e.Format([]byte(`{"a": "x", "b": {"c": [1, 2]}, "n": 1.50}`)))
+	if want := `x,"[1,2]",,1.5`; got != want {
+		t.Errorf("Format() = %v, want %v", got, want)
+	}
+}
+
+func TestExpression_Format_escaping(t *testing.T) {
+	t.Parallel()
+	line := []byte(`{"a": "plain", "b": "x,y", "c": "say \"hi\"", "d": "one\ntwo\tthree", "e": "back\\slash"}`)
+	tests := []struct {
+		name       string
+		expr       string
+		want       string
+		wantHeader string
+	}{
+		{
+			name: "csv",
+			expr: "a,b,c,d,e,|@csv",
+			want: "plain,\"x,y\",\"say \"\"hi\"\"\",\"one\ntwo\tthree\",back\\slash",
+		},
+		{
+			name: "tsv",
+			expr: "a,b,c,d,e,|@tsv",
+			want: "plain\tx,y\tsay \"hi\"\tone\\ntwo\\tthree\tback\\\\slash",
+		},
+		{
+			name:       "csv header",
+			expr:       `a,b,|@csv:header`,
+			want:       `plain,"x,y"`,
+			wantHeader: "a,b",
+		},
+		{
+			name:       "tsv header",
+			expr:       `a,b,|@TSV:Header`,
+			want:       "plain\tx,y",
+			wantHeader: "a\tb",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+
+			if got := string(e.Format(line)); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+
+			if e.HasHeader() != (tt.wantHeader != "") {
+				t.Fatalf("HasHeader() = %v, want %v", e.HasHeader(), tt.wantHeader != "")
+			}
+
+			if got := string(e.AppendHeader(nil)); tt.wantHeader != "" && got != tt.wantHeader {
+				t.Errorf("AppendHeader() = %q, want %q", got, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestExpression_Format_structured(t *testing.T) {
+	t.Parallel()
+	line := []byte(`{"@timestamp": "2021-01-01", "level": "info", "message": "a \"b\" | c", "obj": {"x": [1, 2]}, "n": 3}`)
+	tests := []struct {
+		name       string
+		expr       string
+		want       string
+		wantHeader string
+	}{
+		{
+			name: "json",
+			expr: "@timestamp,message,obj,n,missing,|@json",
+			want: `{"@timestamp":"2021-01-01","message":"a \"b\" | c","obj":{"x":[1,2]},"n":3,"missing":null}`,
+		},
+		{
+			name: "logfmt",
+			expr: "@timestamp,level,message,obj,missing,|@logfmt",
+			want: `@timestamp=2021-01-01 level=info message="a \"b\" | c" obj="{\"x\":[1,2]}" missing=`,
+		},
+		{
+			name:       "table",
+			expr:       "level,n,message,|@table",
+			want:       "info   3  a \"b\" | c",
+			wantHeader: "level  n  message",
+		},
+		{
+			name:       "markdown",
+			expr:       "level,message,|@markdown",
+			want:       `| info | a "b" \| c |`,
+			wantHeader: "| level | message |\n| --- | --- |",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+
+			if got := string(e.Format(line)); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+
+			if e.HasHeader() != (tt.wantHeader != "") {
+				t.Fatalf("HasHeader() = %v, want %v", e.HasHeader(), tt.wantHeader != "")
+			}
+
+			if got := string(e.AppendHeader(nil)); tt.wantHeader != "" && got != tt.wantHeader {
+				t.Errorf("AppendHeader() = %q, want %q", got, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestExpression_Format_prettyJSON(t *testing.T) {
+	t.Parallel()
+	e, err := Compile("b,a,|@json")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	got := string(e.AppendFormat(nil, []byte(`{"a": 1, "b": 2}`), true, false, true))
+	if want := "{\n  \"a\": 1,\n  \"b\": 2\n}"; got != want {
+		t.Errorf("AppendFormat() = %q, want %q", got, want)
+	}
+}
+
+func TestStream_AppendFormat_table(t *testing.T) {
+	t.Parallel()
+	e, err := Compile("a,b,c,|@table")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	st := e.NewStream()
+	var got []string
+	for _, line := range []string{
+		`{"a": "x", "b": "y", "c": "z"}`,
+		`{"a": "wider", "b": "tab\there", "c": "z"}`,
+		`{"a": "x", "b": "y", "c": "z"}`,
+	} {
+		got = append(got, string(st.AppendFormat(nil, []byte(line), false, false, false)))
+	}
+
+	want := []string{
+		"x  y  z",
+		"wider  tab\\there  z",
+		"x      y          z",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AppendFormat() = %q, want %q", got, want)
+	}
+}
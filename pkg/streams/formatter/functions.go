@@ -0,0 +1,248 @@
+package formatter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/pretty"
+)
+
+// function transforms a selected value (which may not exist) in an output expression
+type function func(gjson.Result) gjson.Result
+
+// functionMaker builds a function from its arguments (nil when it was used without parentheses)
+type functionMaker func(args []string) (function, error)
+
+var functions = map[string]functionMaker{
+	"upper":   noArgs(func(s string) string { return strings.ToUpper(s) }),
+	"lower":   noArgs(func(s string) string { return strings.ToLower(s) }),
+	"len":     makeLen,
+	"trunc":   makeTrunc,
+	"time":    makeTime,
+	"default": makeDefault,
+	"json":    makeJSON,
+	"keys":    makeKeys,
+}
+
+// timeLayouts are the names time(layout) accepts besides Go layouts
+var timeLayouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"kitchen":     time.Kitchen,
+	"stamp":       time.Stamp,
+	"stampmilli":  time.StampMilli,
+	"datetime":    "2006-01-02 15:04:05",
+	"date":        "2006-01-02",
+	"time":        "15:04:05",
+}
+
+// timeInputs are the string forms time(layout) can read
+var timeInputs = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+func stringResult(s string) gjson.Result {
+	return gjson.Result{Type: gjson.String, Str: s, Raw: string(appendJSONString(nil, s))}
+}
+
+func intResult(n int) gjson.Result {
+	return gjson.Result{Type: gjson.Number, Num: float64(n), Raw: strconv.Itoa(n)}
+}
+
+func wantArgs(args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("takes %d argument(s), got %d", n, len(args))
+	}
+
+	return nil
+}
+
+func noArgs(fn func(string) string) functionMaker {
+	return func(args []string) (function, error) {
+		if err := wantArgs(args, 0); err != nil {
+			return nil, err
+		}
+
+		return func(res gjson.Result) gjson.Result {
+			if !res.Exists() {
+				return res
+			}
+
+			return stringResult(fn(res.String()))
+		}, nil
+	}
+}
+
+func makeLen(args []string) (function, error) {
+	if err := wantArgs(args, 0); err != nil {
+		return nil, err
+	}
+
+	return func(res gjson.Result) gjson.Result {
+		switch {
+		case !res.Exists():
+			return res
+		case res.IsArray():
+			return intResult(len(res.Array()))
+		case res.IsObject():
+			n := 0
+			res.ForEach(func(_, _ gjson.Result) bool {
+				n++
+				return true
+			})
+			return intResult(n)
+		default:
+			return intResult(utf8.RuneCountInString(res.String()))
+		}
+	}, nil
+}
+
+func makeTrunc(args []string) (function, error) {
+	if err := wantArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("needs a non-negative number of characters, got %q", args[0])
+	}
+
+	return func(res gjson.Result) gjson.Result {
+		if !res.Exists() {
+			return res
+		}
+
+		s := res.String()
+		if utf8.RuneCountInString(s) <= n {
+			return stringResult(s)
+		}
+
+		runes := 0
+		for i := range s {
+			if runes == n {
+				return stringResult(s[:i])
+			}
+			runes++
+		}
+
+		return stringResult(s)
+	}, nil
+}
+
+func makeTime(args []string) (function, error) {
+	if err := wantArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	layout := args[0]
+	if named, ok := timeLayouts[strings.ToLower(layout)]; ok {
+		layout = named
+	}
+
+	return func(res gjson.Result) gjson.Result {
+		t, ok := parseTime(res)
+		if !ok {
+			return res
+		}
+
+		return stringResult(t.Format(layout))
+	}, nil
+}
+
+// parseTime reads a timestamp string, or a unix time in seconds, milliseconds, microseconds or nanoseconds
+// (told apart by magnitude; these come out in UTC)
+func parseTime(res gjson.Result) (time.Time, bool) {
+	switch res.Type {
+	case gjson.String:
+		for _, layout := range timeInputs {
+			if t, err := time.Parse(layout, res.Str); err == nil {
+				return t, true
+			}
+		}
+
+		n, err := strconv.ParseFloat(res.Str, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		return unixTime(n), true
+	case gjson.Number:
+		return unixTime(res.Num), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func unixTime(n float64) time.Time {
+	switch {
+	case n > 1e17:
+		return time.Unix(0, int64(n)).UTC()
+	case n > 1e14:
+		return time.Unix(0, int64(n*1e3)).UTC()
+	case n > 1e11:
+		return time.Unix(0, int64(n*1e6)).UTC()
+	default:
+		return time.Unix(0, int64(n*1e9)).UTC()
+	}
+}
+
+func makeDefault(args []string) (function, error) {
+	if err := wantArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	fallback := stringResult(args[0])
+
+	return func(res gjson.Result) gjson.Result {
+		if !res.Exists() || res.Type == gjson.Null || (res.Type == gjson.String && res.Str == "") {
+			return fallback
+		}
+
+		return res
+	}, nil
+}
+
+func makeJSON(args []string) (function, error) {
+	if err := wantArgs(args, 0); err != nil {
+		return nil, err
+	}
+
+	return func(res gjson.Result) gjson.Result {
+		if !res.Exists() {
+			return stringResult("null")
+		}
+
+		return stringResult(string(pretty.Ugly([]byte(res.Raw))))
+	}, nil
+}
+
+func makeKeys(args []string) (function, error) {
+	if err := wantArgs(args, 0); err != nil {
+		return nil, err
+	}
+
+	return func(res gjson.Result) gjson.Result {
+		if !res.IsObject() {
+			return gjson.Result{}
+		}
+
+		raw := []byte{'['}
+		res.ForEach(func(key, _ gjson.Result) bool {
+			if len(raw) > 1 {
+				raw = append(raw, ',')
+			}
+			raw = appendJSONString(raw, key.String())
+			return true
+		})
+		raw = append(raw, ']')
+
+		return gjson.Parse(string(raw))
+	}, nil
+}
@@ -0,0 +1,155 @@
+package formatter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpression_Format_functions(t *testing.T) {
+	t.Parallel()
+	line := []byte(`{"@timestamp": "2021-03-04T05:06:07.5Z", "ts": 1614834367500, "msg": "Hello, Wörld", "empty": "", "nil": null, "tags": ["a", "b"], "obj": {"b": 1, "a": [1, 2]}}`)
+	tests := []struct {
+		name       string
+		expr       string
+		want       string
+		wantHeader string
+	}{
+		{
+			name: "upper and lower",
+			expr: "msg|upper,msg|lower,|@ssv",
+			want: "HELLO, WÖRLD hello, wörld",
+		},
+		{
+			name: "len",
+			expr: "msg|len,tags|len,obj|len,missing|len,|@csv",
+			want: "12,2,2,",
+		},
+		{
+			name: "trunc",
+			expr: "msg|trunc(7),msg|trunc(100),|@tsv",
+			want: "Hello, \tHello, Wörld",
+		},
+		{
+			name: "time",
+			expr: `@timestamp|time(kitchen),ts|time("2006-01-02 15:04:05.000"),msg|time(kitchen),|@tsv`,
+			want: "5:06AM\t2021-03-04 05:06:07.500\tHello, Wörld",
+		},
+		{
+			name: "default",
+			expr: `missing|default(-),nil|default(none),empty|default("n/a"),msg|default(x),|@ssv`,
+			want: "- none n/a Hello, Wörld",
+		},
+		{
+			name: "json and keys",
+			expr: "obj|json,obj|keys,missing|json,|@tsv",
+			want: "{\"b\":1,\"a\":[1,2]}\t[\"b\",\"a\"]\tnull",
+		},
+		{
+			name: "chained",
+			expr: "msg|trunc(5)|upper|len",
+			want: "5",
+		},
+		{
+			name:       "aliases",
+			expr:       "@timestamp|time(date) as day,msg as message,|@csv:header",
+			want:       `2021-03-04,"Hello, Wörld"`,
+			wantHeader: "day,message",
+		},
+		{
+			name: "aliases as keys",
+			expr: "msg|upper as m,tags|len as n,|@json",
+			want: `{"m":"HELLO, WÖRLD","n":2}`,
+		},
+		{
+			name: "unknown functions are paths",
+			expr: "obj|a,obj|b",
+			want: "[1,2]\n1",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+
+			if got := string(e.Format(line)); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+
+			if got := string(e.AppendHeader(nil)); tt.wantHeader != "" && got != tt.wantHeader {
+				t.Errorf("AppendHeader() = %q, want %q", got, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestCompile_functionErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		expr       string
+		wantColumn int
+	}{
+		{
+			name:       "bad trunc",
+			expr:       "a, msg|trunc(x)",
+			wantColumn: 8,
+		},
+		{
+			name:       "missing argument",
+			expr:       "msg|time",
+			wantColumn: 5,
+		},
+		{
+			name:       "unexpected argument",
+			expr:       "msg|upper(1)",
+			wantColumn: 5,
+		},
+		{
+			name:       "unclosed",
+			expr:       "msg|trunc(1",
+			wantColumn: 10,
+		},
+		{
+			name:       "text after arguments",
+			expr:       "msg|trunc(1)x",
+			wantColumn: 5,
+		},
+		{
+			name:       "no field",
+			expr:       "|upper as x",
+			wantColumn: 1,
+		},
+		{
+			name:       "missing alias",
+			expr:       "msg as ",
+			wantColumn: 5,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Compile(tt.expr)
+
+			var serr *SyntaxError
+			if !errors.As(err, &serr) {
+				t.Fatalf("Compile() error = %v, want a *SyntaxError", err)
+			}
+			if serr.Column != tt.wantColumn {
+				t.Errorf("Compile() error column = %v, want %v (%v)", serr.Column, tt.wantColumn, err)
+			}
+		})
+	}
+}
+
+func TestParseExpression_badFunctions(t *testing.T) {
+	t.Parallel()
+	got := FormatLine(`{"a": {"trunc(x)": 1}}`, "a|trunc(x)", false, false, false)
+	if want := "1"; got != want {
+		t.Errorf("FormatLine() = %q, want %q", got, want)
+	}
+}
@@ -6,11 +6,9 @@ import (
 
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/pretty"
-)
 
-func init() {
-	gjson.DisableModifiers = true
-}
+	"github.com/gsmcwhirter/prettify/internal/textutil"
+)
 
 type gJSONOutputType int
 
@@ -19,6 +17,10 @@ const (
 	csv
 	tsv
 	nlsv
+	jsonObject
+	logfmt
+	table
+	markdown
 )
 
 var (
@@ -29,6 +31,14 @@ var (
 )
 
 func formatResultStrings(resStrings []string, separatorType gJSONOutputType) string {
+	if separatorType == csv || separatorType == tsv {
+		escaped := make([]string, len(resStrings))
+		for i, s := range resStrings {
+			escaped[i] = escapeField(s, separatorType)
+		}
+		resStrings = escaped
+	}
+
 	switch separatorType {
 	case csv:
 		return strings.Join(resStrings, ",")
@@ -42,6 +52,14 @@ func formatResultStrings(resStrings []string, separatorType gJSONOutputType) str
 }
 
 func formatResultBytes(resBytes [][]byte, separatorType gJSONOutputType) []byte {
+	if separatorType == csv || separatorType == tsv {
+		escaped := make([][]byte, len(resBytes))
+		for i, b := range resBytes {
+			escaped[i] = appendField(nil, textutil.BytesToString(b), separatorType)
+		}
+		resBytes = escaped
+	}
+
 	switch separatorType {
 	case csv:
 		return bytes.Join(resBytes, commaBytes)
@@ -55,28 +73,8 @@ func formatResultBytes(resBytes [][]byte, separatorType gJSONOutputType) []byte
 }
 
 func getGJSONPaths(formatSelectors string) ([]string, gJSONOutputType) {
-	rawGJSONPaths := strings.Split(formatSelectors, ",")
-	gJSONPaths := make([]string, 0)
-	separatorType := nlsv
-
-	for _, component := range rawGJSONPaths {
-		component = strings.TrimSpace(component)
-
-		switch strings.ToLower(component) {
-		case "|@ssv":
-			separatorType = space
-		case "|@csv":
-			separatorType = csv
-		case "|@tsv":
-			separatorType = tsv
-		case "|@nlsv":
-			separatorType = nlsv
-		default:
-			gJSONPaths = append(gJSONPaths, component)
-		}
-	}
-
-	return gJSONPaths, separatorType
+	e := ParseExpression(formatSelectors)
+	return e.paths(), e.separatorType
 }
 
 func gjsonResultBytesToByteArray(line []byte, res gjson.Result) []byte {
@@ -84,14 +82,14 @@ func gjsonResultBytesToByteArray(line []byte, res gjson.Result) []byte {
 		return []byte(res.Raw)
 	}
 
-	if res.Index <= 0 {
-		return []byte(res.Raw)
-	}
-
 	if res.Type == gjson.String {
 		return []byte(res.String())
 	}
 
+	if res.Index <= 0 {
+		return []byte(res.Raw)
+	}
+
 	return line[res.Index : res.Index+len(res.Raw)]
 
 	// if res.Type == gjson.String { // strip off quotes
@@ -104,44 +102,31 @@ func gjsonResultBytesToByteArray(line []byte, res gjson.Result) []byte {
 // FormatLine runs the formatSelectors (csv gjson selectors and an optional custom separator indicator)
 // against the line and reformats the data into the requested format.
 func FormatLine(line, formatSelectors string, prettyFmt, withColor, sortKeys bool) string {
-	gJSONPaths, separatorType := getGJSONPaths(formatSelectors)
-
-	resList := gjson.GetMany(line, gJSONPaths...)
-	resStrings := make([]string, len(resList))
-	for i := 0; i < len(resList); i++ {
-		res := resList[i]
-		switch {
-		case !res.Exists():
-			resStrings[i] = ""
-		case res.Type == gjson.String:
-			resStrings[i] = res.String()
-		default:
-			var toPrint string
-			if prettyFmt {
-				toPrint = PrettyLine(res.String(), withColor, sortKeys)
-			} else {
-				toPrintBytes := []byte(res.String())
-				toPrintBytes = UglyLineBytes(toPrintBytes, withColor, sortKeys)
-				toPrint = string(toPrintBytes)
-			}
+	return string(ParseExpression(formatSelectors).AppendFormat(nil, []byte(line), prettyFmt, withColor, sortKeys))
+}
 
-			toPrint = strings.TrimRight(toPrint, "\n")
-			resStrings[i] = toPrint
-		}
+func appendSeparator(dst []byte, separatorType gJSONOutputType) []byte {
+	switch separatorType {
+	case csv:
+		return append(dst, ',')
+	case tsv:
+		return append(dst, '\t')
+	case space:
+		return append(dst, ' ')
+	default:
+		return append(dst, '\n')
 	}
-
-	return formatResultStrings(resStrings, separatorType)
 }
 
 // FormatLineBytes runs the formatSelectors (csv gjson selectors and an optional custom separator indicator)
 // against the line and reformats the data into the requested format.
 func FormatLineBytes(line []byte, formatSelectors string, prettyFmt, withColor, sortKeys bool) []byte {
-	gJSONPaths, separatorType := getGJSONPaths(formatSelectors)
+	e := ParseExpression(formatSelectors)
 
-	resList := gjson.GetManyBytes(line, gJSONPaths...)
-	resSlices := make([][]byte, len(resList))
-	for i := 0; i < len(resList); i++ {
-		res := resList[i]
+	s := textutil.BytesToString(line)
+	resSlices := make([][]byte, len(e.selectors))
+	for i := range e.selectors {
+		res := e.selectors[i].get(s)
 		if !res.Exists() {
 			resSlices[i] = line[0:0]
 		} else {
@@ -159,7 +144,7 @@ func FormatLineBytes(line []byte, formatSelectors string, prettyFmt, withColor,
 		}
 	}
 
-	return formatResultBytes(resSlices, separatorType)
+	return formatResultBytes(resSlices, e.separatorType)
 }
 
 // PrettyLine turns a json line into pretty form (one field per line, etc), possibly with sorted keys and colorized
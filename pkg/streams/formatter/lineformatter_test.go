@@ -153,6 +153,12 @@ func Test_getGJSONPaths(t *testing.T) {
 			wantPaths: []string{"foo", "bar"},
 			wantType:  nlsv,
 		},
+		{
+			name:      "commas inside selectors",
+			gJSONPath: `items.#(status=="ok")#.id, {a,b}, k\,1, |@csv`,
+			wantPaths: []string{`items.#(status=="ok")#.id`, "{a,b}", `k\,1`},
+			wantType:  csv,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
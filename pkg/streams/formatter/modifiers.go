@@ -0,0 +1,94 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// CompileOptions controls the behavior of CompileWith
+//
+// AllowModifiers names the gjson modifiers (like reverse or flatten) the expression may use; expressions using
+// others fail to compile. Other names starting with @, like @timestamp, are always field names.
+type CompileOptions struct {
+	AllowModifiers []string
+}
+
+// Validate checks the options the way CompileWith does, for reporting problems with them on their own
+func (opts CompileOptions) Validate() error {
+	_, err := opts.allowedModifiers()
+	return err
+}
+
+// allowedModifiers checks the names in AllowModifiers, and returns them as a set (without their @)
+func (opts CompileOptions) allowedModifiers() (map[string]bool, error) {
+	allowed := map[string]bool{}
+
+	for _, name := range opts.AllowModifiers {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+		if name == "" {
+			continue
+		}
+
+		if !gjson.ModifierExists(name, nil) {
+			return nil, fmt.Errorf("unknown gjson modifier %q", name)
+		}
+
+		allowed[name] = true
+	}
+
+	return allowed, nil
+}
+
+// EscapeModifiers escapes the @ of every name in path that gjson would otherwise run as a modifier,
+// so it is looked up as a field name (like @timestamp)
+//
+// Paths given by users outside of output expressions (like --sample-key or --dedupe-fields) should go through it,
+// as modifiers are only run where an expression allows them.
+func EscapeModifiers(path string) string {
+	if strings.IndexByte(path, '@') < 0 {
+		return path
+	}
+
+	query, _ := guardModifiers(path, nil)
+
+	return query
+}
+
+// guardModifiers escapes the @ of every name in path that is not an allowed modifier, so gjson looks it up
+// as a field name, and also returns the first modifier in path that has not been allowed ("" when there is none)
+func guardModifiers(path string, allowed map[string]bool) (query, disallowed string) {
+	var b strings.Builder
+	segmentStart := true
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+
+		switch {
+		case c == '\\' && i+1 < len(path):
+			b.WriteByte(c)
+			b.WriteByte(path[i+1])
+			i++
+			segmentStart = false
+			continue
+		case c == '@' && segmentStart:
+			end := i + 1
+			for end < len(path) && !strings.ContainsRune(".|:,)]}", rune(path[end])) {
+				end++
+			}
+
+			if name := path[i+1 : end]; !allowed[name] {
+				b.WriteByte('\\')
+				if disallowed == "" && gjson.ModifierExists(name, nil) {
+					disallowed = name
+				}
+			}
+		}
+
+		b.WriteByte(c)
+		segmentStart = strings.IndexByte(".|,:{[(", c) >= 0
+	}
+
+	return b.String(), disallowed
+}
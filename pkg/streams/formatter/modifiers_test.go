@@ -0,0 +1,145 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestCompileOptions_AllowModifiers(t *testing.T) {
+	t.Parallel()
+	line := []byte(`{"@timestamp": "t", "@reverse": "field", "a": [1, 2, 3]}`)
+	opts := CompileOptions{AllowModifiers: []string{"@reverse", " "}}
+
+	if got := FormatLine(string(line), "@timestamp,a|@reverse,|@ssv", false, false, false); got != "t " {
+		t.Errorf("FormatLine() = %q, want %q (no modifiers run)", got, "t ")
+	}
+
+	if _, err := CompileWith("a", CompileOptions{AllowModifiers: []string{"nope"}}); err == nil {
+		t.Error("CompileWith() error = nil, want an error for an unknown modifier")
+	}
+
+	if err := (CompileOptions{AllowModifiers: []string{"nope"}}).Validate(); err == nil {
+		t.Error("Validate() error = nil, want an error for an unknown modifier")
+	}
+
+	tests := []struct {
+		name    string
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "allowed",
+			expr: "a|@reverse",
+			want: "[3,2,1]",
+		},
+		{
+			name:    "not allowed",
+			expr:    "@timestamp,a|@flatten",
+			wantErr: "@flatten is not allowed",
+		},
+		{
+			name: "field named like a modifier",
+			expr: "@timestamp",
+			want: "t",
+		},
+		{
+			name: "multipath",
+			expr: `{"ts":@timestamp,"r":a|@reverse},|@ssv`,
+			want: `{"ts":"t","r":[3,2,1]}`,
+		},
+		{
+			name: "escaped",
+			expr: `\@reverse`,
+			want: "field",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e, err := CompileWith(tt.expr, opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("CompileWith() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CompileWith() error = %v", err)
+			}
+
+			if got := string(e.Format(line)); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Compile("a|@reverse"); err == nil {
+		t.Error("Compile() error = nil, want modifiers rejected without CompileOptions")
+	}
+
+	if gjson.DisableModifiers {
+		t.Error("gjson.DisableModifiers = true, want the gjson setting left alone")
+	}
+}
+
+func TestEscapeModifiers(t *testing.T) {
+	t.Parallel()
+	line := `{"@this": "v", "@keys": "k", "@timestamp": "t", "a": {"@reverse": "r"}}`
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "@this", want: "v"},
+		{path: "@keys", want: "k"},
+		{path: "@timestamp", want: "t"},
+		{path: "a.@reverse", want: "r"},
+		{path: "a|@reverse", want: "r"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+			if got := gjson.Get(line, EscapeModifiers(tt.path)).String(); got != tt.want {
+				t.Errorf("gjson.Get(EscapeModifiers(%q)) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if got := EscapeModifiers("a.b"); got != "a.b" {
+		t.Errorf("EscapeModifiers(%q) = %q, want it unchanged", "a.b", got)
+	}
+}
+
+func Test_guardModifiers(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path           string
+		want           string
+		wantDisallowed string
+	}{
+		{path: "@timestamp", want: `\@timestamp`},
+		{path: "a.@this", want: `a.\@this`, wantDisallowed: "this"},
+		{path: `a\@b`, want: `a\@b`},
+		{path: "a@b", want: "a@b"},
+		{path: "a|@reverse", want: "a|@reverse"},
+		{path: "{a,@pretty}", want: `{a,\@pretty}`, wantDisallowed: "pretty"},
+		{path: `{"t":@timestamp}`, want: `{"t":\@timestamp}`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+			got, disallowed := guardModifiers(tt.path, map[string]bool{"reverse": true})
+			if got != tt.want {
+				t.Errorf("guardModifiers(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+			if disallowed != tt.wantDisallowed {
+				t.Errorf("guardModifiers(%q) disallowed = %q, want %q", tt.path, disallowed, tt.wantDisallowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package formatter
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/pretty"
+
+	"github.com/gsmcwhirter/prettify/internal/textutil"
+)
+
+// PrettyOptions controls how json is pretty-printed; the zero value prints the same way as PrettyLineBytes
+//
+// Indent is the indentation of each nesting level (two spaces when empty)
+// Width is the widest an array or object may be to still be printed on one line (80 when 0)
+// Prefix starts every printed line
+// SortKeys sorts the keys of every object
+// PinnedKeys are top-level keys printed first, in the order given (the other keys follow, sorted or in their original order)
+// YAML prints YAML-like `key: value` lines instead of indented json, which is easier to read for deeply nested values
+// Style is the palette for colorized json (pretty.TerminalStyle when nil)
+type PrettyOptions struct {
+	Indent     string
+	Width      int
+	Prefix     string
+	SortKeys   bool
+	PinnedKeys []string
+	YAML       bool
+	Style      *pretty.Style
+}
+
+func (o *PrettyOptions) indent() string {
+	if o.Indent == "" {
+		return pretty.DefaultOptions.Indent
+	}
+
+	return o.Indent
+}
+
+// AppendPretty appends the pretty form of a json line (without a trailing newline) to dst, possibly colorized
+//
+// Lines that are not json are appended as they are in YAML mode (as pretty-printing json does its best with them otherwise).
+func (o *PrettyOptions) AppendPretty(dst, line []byte, withColor bool) []byte {
+	line = o.order(line)
+
+	if o.YAML {
+		if !gjson.ValidBytes(line) {
+			return append(dst, line...)
+		}
+
+		w := yamlWriter{indent: o.indent(), prefix: o.Prefix}
+		if withColor {
+			w.style = o.style()
+		}
+
+		return w.appendDocument(dst, gjson.ParseBytes(line))
+	}
+
+	output := pretty.PrettyOptions(line, &pretty.Options{
+		Width:    o.width(),
+		Prefix:   o.Prefix,
+		Indent:   o.indent(),
+		SortKeys: o.SortKeys && len(o.PinnedKeys) == 0, // already sorted by order otherwise
+	})
+
+	if withColor {
+		output = pretty.Color(output, o.style())
+	}
+
+	return append(dst, bytes.TrimRight(output, "\n")...)
+}
+
+// AppendUgly appends the compact form of a json line (without a trailing newline) to dst, possibly colorized
+//
+// Only the key order options apply.
+func (o *PrettyOptions) AppendUgly(dst, line []byte, withColor bool) []byte {
+	line = pretty.Ugly(o.order(line))
+	if withColor {
+		line = pretty.Color(line, o.style())
+	}
+
+	return append(dst, bytes.TrimRight(line, "\n")...)
+}
+
+func (o *PrettyOptions) style() *pretty.Style {
+	if o.Style == nil {
+		return pretty.TerminalStyle
+	}
+
+	return o.Style
+}
+
+func (o *PrettyOptions) width() int {
+	if o.Width <= 0 {
+		return pretty.DefaultOptions.Width
+	}
+
+	return o.Width
+}
+
+// order applies SortKeys (when keys are pinned, or in YAML mode, where pretty does not sort) and PinnedKeys
+func (o *PrettyOptions) order(line []byte) []byte {
+	if o.SortKeys && (len(o.PinnedKeys) > 0 || o.YAML) {
+		line = pretty.PrettyOptions(line, &pretty.Options{Width: o.width(), Indent: o.indent(), SortKeys: true})
+	}
+
+	if len(o.PinnedKeys) == 0 {
+		return line
+	}
+
+	return pinKeys(line, o.PinnedKeys)
+}
+
+// pinKeys moves the pinned members of a json object to its start (other json is returned as it is)
+func pinKeys(line []byte, pinned []string) []byte {
+	obj := gjson.ParseBytes(line)
+	if !obj.IsObject() {
+		return line
+	}
+
+	type member struct {
+		key  string
+		raw  string
+		done bool
+	}
+
+	var members []member
+	obj.ForEach(func(key, value gjson.Result) bool {
+		members = append(members, member{key: key.String(), raw: key.Raw + ":" + value.Raw})
+		return true // keep iterating
+	})
+
+	out := make([]byte, 0, len(line))
+	out = append(out, '{')
+
+	add := func(m *member) {
+		if len(out) > 1 {
+			out = append(out, ',')
+		}
+		out = append(out, m.raw...)
+		m.done = true
+	}
+
+	for _, key := range pinned {
+		for i := range members {
+			if !members[i].done && members[i].key == key {
+				add(&members[i])
+			}
+		}
+	}
+
+	for i := range members {
+		if !members[i].done {
+			add(&members[i])
+		}
+	}
+
+	return append(out, '}')
+}
+
+// yamlWriter renders json values as YAML-like text
+type yamlWriter struct {
+	indent string
+	prefix string
+	style  *pretty.Style // no color when nil
+}
+
+func (w *yamlWriter) appendDocument(dst []byte, v gjson.Result) []byte {
+	dst = append(dst, w.prefix...)
+	return w.appendItem(dst, v, 0)
+}
+
+// appendItem appends a value whose line has already been started (at depth, or after a "- " at depth-1)
+func (w *yamlWriter) appendItem(dst []byte, v gjson.Result, depth int) []byte {
+	switch {
+	case isEmptyOrScalar(v):
+		return w.appendScalar(dst, v, depth)
+	case v.IsArray():
+		return w.appendArray(dst, v, depth)
+	default:
+		return w.appendObject(dst, v, depth)
+	}
+}
+
+// appendObject appends the members of an object, one per line
+func (w *yamlWriter) appendObject(dst []byte, v gjson.Result, depth int) []byte {
+	first := true
+
+	v.ForEach(func(key, value gjson.Result) bool {
+		if !first {
+			dst = w.newline(dst, depth)
+		}
+		first = false
+
+		dst = w.appendKey(dst, key.String())
+		dst = append(dst, ':')
+
+		if isEmptyOrScalar(value) {
+			dst = append(dst, ' ')
+		} else {
+			dst = w.newline(dst, depth+1)
+		}
+
+		dst = w.appendItem(dst, value, depth+1)
+
+		return true // keep iterating
+	})
+
+	return dst
+}
+
+// appendArray appends the items of an array, one per line after a "- "
+func (w *yamlWriter) appendArray(dst []byte, v gjson.Result, depth int) []byte {
+	first := true
+
+	v.ForEach(func(_, item gjson.Result) bool {
+		if !first {
+			dst = w.newline(dst, depth)
+		}
+		first = false
+
+		dst = append(dst, '-')
+		if item.IsArray() && !isEmptyOrScalar(item) {
+			dst = w.newline(dst, depth+1)
+		} else {
+			dst = append(dst, ' ')
+		}
+
+		dst = w.appendItem(dst, item, depth+1)
+
+		return true // keep iterating
+	})
+
+	return dst
+}
+
+func (w *yamlWriter) newline(dst []byte, depth int) []byte {
+	dst = append(dst, '\n')
+	dst = append(dst, w.prefix...)
+	for i := 0; i < depth; i++ {
+		dst = append(dst, w.indent...)
+	}
+
+	return dst
+}
+
+func (w *yamlWriter) appendKey(dst []byte, key string) []byte {
+	var seq [2]string
+	if w.style != nil {
+		seq = w.style.Key
+	}
+
+	dst = append(dst, seq[0]...)
+	if yamlPlain(key) {
+		dst = append(dst, key...)
+	} else {
+		dst = appendJSONString(dst, key)
+	}
+
+	return append(dst, seq[1]...)
+}
+
+// appendScalar appends a scalar (or an empty object or array) at the end of a line, with multiline strings
+// as indented blocks at depth
+func (w *yamlWriter) appendScalar(dst []byte, v gjson.Result, depth int) []byte {
+	var seq [2]string
+	if w.style != nil {
+		switch v.Type {
+		case gjson.String:
+			seq = w.style.String
+		case gjson.Number:
+			seq = w.style.Number
+		case gjson.True:
+			seq = w.style.True
+		case gjson.False:
+			seq = w.style.False
+		case gjson.Null:
+			seq = w.style.Null
+		}
+	}
+
+	switch {
+	case v.Type == gjson.String && strings.Contains(strings.TrimRight(v.Str, "\n"), "\n"):
+		dst = append(dst, '|')
+		if !strings.HasSuffix(v.Str, "\n") {
+			dst = append(dst, '-')
+		}
+
+		for _, l := range strings.Split(strings.TrimRight(v.Str, "\n"), "\n") {
+			dst = w.newline(dst, depth)
+			dst = append(dst, seq[0]...)
+			dst = append(dst, l...)
+			dst = append(dst, seq[1]...)
+		}
+
+		return dst
+	case v.Type == gjson.String && yamlPlain(v.Str):
+		dst = append(dst, seq[0]...)
+		dst = append(dst, v.Str...)
+	case v.Type == gjson.String:
+		dst = append(dst, seq[0]...)
+		dst = appendJSONString(dst, v.Str)
+	case v.Type == gjson.JSON:
+		dst = append(dst, pretty.Ugly([]byte(v.Raw))...) // {} or []
+	case v.Type == gjson.Null:
+		dst = append(dst, seq[0]...)
+		dst = append(dst, "null"...)
+	default:
+		dst = append(dst, seq[0]...)
+		dst = textutil.AppendScalar(dst, v)
+	}
+
+	return append(dst, seq[1]...)
+}
+
+func isEmptyOrScalar(v gjson.Result) bool {
+	if v.Type != gjson.JSON {
+		return true
+	}
+
+	empty := true
+	v.ForEach(func(_, _ gjson.Result) bool {
+		empty = false
+		return false // stop iterating
+	})
+
+	return empty
+}
+
+// yamlPlain reports whether s can be written without quotes and still read back as the same string
+func yamlPlain(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s, "\n\t\"") {
+		return false
+	}
+
+	if strings.ContainsRune("-?:,[]{}#&*!|>'%@`", rune(s[0])) {
+		return false
+	}
+
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+
+	switch strings.ToLower(s) {
+	case "true", "false", "null", "yes", "no", "on", "off", "~":
+		return false
+	}
+
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return false
+	}
+
+	return true
+}
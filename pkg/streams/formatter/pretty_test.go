@@ -0,0 +1,142 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/pretty"
+)
+
+func TestPrettyOptions_AppendPretty(t *testing.T) {
+	t.Parallel()
+	line := `{"level": "info", "msg": "hi", "ctx": {"b": [1, 2], "a": {"x": null}}, "time": "t"}`
+	tests := []struct {
+		name string
+		opts PrettyOptions
+		line string
+		want string
+	}{
+		{
+			name: "defaults",
+			line: `{"a": [1, 2], "b": {}}`,
+			want: "{\n  \"a\": [1, 2],\n  \"b\": {}\n}",
+		},
+		{
+			name: "indent, width and prefix",
+			opts: PrettyOptions{Indent: "    ", Width: 4, Prefix: "# "},
+			line: `{"a": [1, 2]}`,
+			want: "# {\n#     \"a\": [\n#         1,\n#         2\n#     ]\n# }",
+		},
+		{
+			name: "sorted",
+			opts: PrettyOptions{SortKeys: true},
+			line: `{"b": 1, "a": {"d": 1, "c": 2}}`,
+			want: "{\n  \"a\": {\n    \"c\": 2,\n    \"d\": 1\n  },\n  \"b\": 1\n}",
+		},
+		{
+			name: "pinned",
+			opts: PrettyOptions{PinnedKeys: []string{"time", "missing", "msg"}},
+			line: `{"b": 1, "msg": "m", "a": 2, "time": "t"}`,
+			want: "{\n  \"time\": \"t\",\n  \"msg\": \"m\",\n  \"b\": 1,\n  \"a\": 2\n}",
+		},
+		{
+			name: "pinned and sorted",
+			opts: PrettyOptions{PinnedKeys: []string{"time", "msg"}, SortKeys: true},
+			line: `{"b": {"y": 1, "x": 2}, "msg": "m", "a": 2, "time": "t"}`,
+			want: "{\n  \"time\": \"t\",\n  \"msg\": \"m\",\n  \"a\": 2,\n  \"b\": {\n    \"x\": 2,\n    \"y\": 1\n  }\n}",
+		},
+		{
+			name: "yaml",
+			opts: PrettyOptions{YAML: true, PinnedKeys: []string{"time"}},
+			line: line,
+			want: "time: t\nlevel: info\nmsg: hi\nctx:\n  b:\n    - 1\n    - 2\n  a:\n    x: null",
+		},
+		{
+			name: "yaml sorted",
+			opts: PrettyOptions{YAML: true, SortKeys: true},
+			line: line,
+			want: "ctx:\n  a:\n    x: null\n  b:\n    - 1\n    - 2\nlevel: info\nmsg: hi\ntime: t",
+		},
+		{
+			name: "yaml arrays",
+			opts: PrettyOptions{YAML: true},
+			line: `{"items": [{"id": 1, "tags": ["a"]}, [1, [2]], {}, []]}`,
+			want: "items:\n  - id: 1\n    tags:\n      - a\n  -\n    - 1\n    -\n      - 2\n  - {}\n  - []",
+		},
+		{
+			name: "yaml quoting",
+			opts: PrettyOptions{YAML: true},
+			line: `{"a": "", "b": "true", "c": "12", "d": "x: y", "e": "- x", "f": " pad", "g": "plain text", "key: x": 1.50}`,
+			want: "a: \"\"\nb: \"true\"\nc: \"12\"\nd: \"x: y\"\ne: \"- x\"\nf: \" pad\"\ng: plain text\n\"key: x\": 1.5",
+		},
+		{
+			name: "yaml multiline",
+			opts: PrettyOptions{YAML: true, Prefix: "| "},
+			line: `{"stack": "one\ntwo", "trailing": "a\nb\n"}`,
+			want: "| stack: |-\n|   one\n|   two\n| trailing: |\n|   a\n|   b",
+		},
+		{
+			name: "yaml scalar document",
+			opts: PrettyOptions{YAML: true},
+			line: `"x"`,
+			want: "x",
+		},
+		{
+			name: "yaml not json",
+			opts: PrettyOptions{YAML: true},
+			line: `plain {text`,
+			want: "plain {text",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := string(tt.opts.AppendPretty(nil, []byte(tt.line), false)); got != tt.want {
+				t.Errorf("AppendPretty() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrettyOptions_AppendPretty_yamlColor(t *testing.T) {
+	t.Parallel()
+	opts := PrettyOptions{YAML: true}
+	got := string(opts.AppendPretty([]byte("> "), []byte(`{"k": "v", "n": 1}`), true))
+
+	style := pretty.TerminalStyle
+	for _, want := range []string{"> ", style.Key[0] + "k" + style.Key[1], style.String[0] + "v", style.Number[0] + "1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("AppendPretty() = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestPrettyOptions_style(t *testing.T) {
+	t.Parallel()
+	style := *pretty.TerminalStyle
+	style.Key = [2]string{"<k>", "</k>"}
+	custom := PrettyOptions{Style: &style}
+	plain := PrettyOptions{}
+
+	line := []byte(`{"k":"v"}`)
+	if got := string(custom.AppendUgly(nil, line, true)); !strings.Contains(got, "<k>\"k\"</k>") {
+		t.Errorf("AppendUgly() with a style = %q, want the key in its colors", got)
+	}
+	if got := string(plain.AppendUgly(nil, line, true)); strings.Contains(got, "<k>") {
+		t.Errorf("AppendUgly() without a style = %q, want the terminal style", got)
+	}
+	if got := string(custom.AppendPretty(nil, line, true)); !strings.Contains(got, "<k>\"k\"</k>") {
+		t.Errorf("AppendPretty() with a style = %q, want the key in its colors", got)
+	}
+}
+
+func TestPrettyOptions_AppendUgly(t *testing.T) {
+	t.Parallel()
+	opts := PrettyOptions{PinnedKeys: []string{"m"}, SortKeys: true, Indent: "\t", YAML: true}
+	got := string(opts.AppendUgly(nil, []byte(`{"b": {"d": 1, "c": 2}, "m": "x", "a": 1}`), false))
+
+	if want := `{"m":"x","a":1,"b":{"c":2,"d":1}}`; got != want {
+		t.Errorf("AppendUgly() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+package formatter
+
+import "fmt"
+
+// token is a part of an output expression and the 0-based offset it starts at
+type token struct {
+	text  string
+	start int
+}
+
+// closers maps each opening bracket to the one closing it
+var closers = map[byte]byte{
+	'(': ')',
+	'[': ']',
+	'{': '}',
+}
+
+// tokenize splits s at every sep that is not escaped with a backslash, inside "double quotes",
+// or inside brackets, braces or parentheses (so gjson queries like `items.#(status=="ok")#.id`
+// and multipaths like `{a,b}` stay whole)
+//
+// Unbalanced brackets and unterminated quotes are reported with a 0-based Column (and no Expr);
+// the tokens are still returned, split as well as could be done.
+func tokenize(s string, sep byte) ([]token, *SyntaxError) {
+	var (
+		tokens []token
+		opened []int // offsets of the brackets not yet closed
+		err    *SyntaxError
+	)
+
+	start := 0
+	quoteAt := -1
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '\\':
+			i++ // the next character is literal
+		case quoteAt >= 0:
+			if c == '"' {
+				quoteAt = -1
+			}
+		case c == '"':
+			quoteAt = i
+		case closers[c] != 0:
+			opened = append(opened, i)
+		case c == ')' || c == ']' || c == '}':
+			if len(opened) == 0 || closers[s[opened[len(opened)-1]]] != c {
+				if err == nil {
+					err = &SyntaxError{Column: i, Msg: fmt.Sprintf("unexpected %c", c)}
+				}
+				continue
+			}
+			opened = opened[:len(opened)-1]
+		case c == sep && len(opened) == 0:
+			tokens = append(tokens, token{text: s[start:i], start: start})
+			start = i + 1
+		}
+	}
+
+	tokens = append(tokens, token{text: s[start:], start: start})
+
+	switch {
+	case err != nil:
+	case quoteAt >= 0:
+		err = &SyntaxError{Column: quoteAt, Msg: "unterminated quote"}
+	case len(opened) > 0:
+		at := opened[len(opened)-1]
+		err = &SyntaxError{Column: at, Msg: fmt.Sprintf("%c is never closed", s[at])}
+	}
+
+	return tokens, err
+}
@@ -0,0 +1,167 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_tokenize(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		s          string
+		want       []string
+		wantColumn int // 1-based, 0 for no error
+	}{
+		{
+			name: "plain",
+			s:    "a, b.c,d",
+			want: []string{"a", " b.c", "d"},
+		},
+		{
+			name: "query",
+			s:    `items.#(status=="ok")#.id,message`,
+			want: []string{`items.#(status=="ok")#.id`, "message"},
+		},
+		{
+			name: "query with a comma in the value",
+			s:    `items.#(name=="a,b").id,x`,
+			want: []string{`items.#(name=="a,b").id`, "x"},
+		},
+		{
+			name: "nested queries",
+			s:    `friends.#(nets.#(=="fb"))#.first,|@csv`,
+			want: []string{`friends.#(nets.#(=="fb"))#.first`, "|@csv"},
+		},
+		{
+			name: "multipath",
+			s:    "{a,b.c,\"d\":[e,f]},g",
+			want: []string{"{a,b.c,\"d\":[e,f]}", "g"},
+		},
+		{
+			name: "escaped comma",
+			s:    `k\,1,k2`,
+			want: []string{`k\,1`, "k2"},
+		},
+		{
+			name: "escaped quote",
+			s:    `a\"b,c`,
+			want: []string{`a\"b`, "c"},
+		},
+		{
+			name: "escaped pipe format",
+			s:    `\|@csv,|@csv`,
+			want: []string{`\|@csv`, "|@csv"},
+		},
+		{
+			name: "function arguments",
+			s:    `a|default("x, y"),b|trunc(3)`,
+			want: []string{`a|default("x, y")`, "b|trunc(3)"},
+		},
+		{
+			name:       "unclosed",
+			s:          "a,#(b==1,c",
+			want:       []string{"a", "#(b==1,c"},
+			wantColumn: 4,
+		},
+		{
+			name:       "unterminated quote",
+			s:          `a,#(b=="1),c`,
+			want:       []string{"a", `#(b=="1),c`},
+			wantColumn: 8,
+		},
+		{
+			name:       "mismatched",
+			s:          "{a,b),c",
+			want:       []string{"{a,b),c"},
+			wantColumn: 5,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tokens, err := tokenize(tt.s, ',')
+
+			got := make([]string, len(tokens))
+			for i, tok := range tokens {
+				got[i] = tok.text
+				if tt.s[tok.start:tok.start+len(tok.text)] != tok.text {
+					t.Errorf("tokenize() token %d start = %d, does not match %q", i, tok.start, tok.text)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize() = %q, want %q", got, tt.want)
+			}
+
+			switch {
+			case tt.wantColumn == 0 && err != nil:
+				t.Errorf("tokenize() error = %v", err)
+			case tt.wantColumn != 0 && err == nil:
+				t.Errorf("tokenize() error = nil, want one at column %d", tt.wantColumn)
+			case err != nil && err.Column+1 != tt.wantColumn:
+				t.Errorf("tokenize() error column = %d, want %d (%s)", err.Column+1, tt.wantColumn, err.Msg)
+			}
+		})
+	}
+}
+
+func TestExpression_Format_trickySelectors(t *testing.T) {
+	t.Parallel()
+	line := []byte(`{"items": [{"id": 1, "status": "ok"}, {"id": 2, "status": "bad"}, {"id": 3, "status": "ok"}], "k,1": "comma", "|@csv": "pipe", "a": {"b": "x,y"}, "say \"hi\"": "quoted"}`)
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{
+			name: "query",
+			expr: `items.#(status=="ok")#.id,|@tsv`,
+			want: "[1,3]",
+		},
+		{
+			name: "query and other fields",
+			expr: `items.#(status=="ok")#.id,items.#(status!="ok").id,k\,1,|@tsv`,
+			want: "[1,3]\t2\tcomma",
+		},
+		{
+			name: "query with a comma in the value",
+			expr: `items.#(status=="o,k")#.id,items.#,|@ssv`,
+			want: "[] 3",
+		},
+		{
+			name: "literal format name",
+			expr: `\|@csv,a.b,|@csv`,
+			want: `pipe,"x,y"`,
+		},
+		{
+			name: "escaped quotes",
+			expr: `say \"hi\"`,
+			want: "quoted",
+		},
+		{
+			name: "functions of a query",
+			expr: `items.#(status=="ok")#.id|len as oks,|@logfmt`,
+			want: "oks=2",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+
+			if got := string(e.Format(line)); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+
+			if got := FormatLine(string(line), tt.expr, false, false, false); got != tt.want {
+				t.Errorf("FormatLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package human
+
+import (
+	"testing"
+
+	"github.com/gsmcwhirter/prettify/pkg/testutil"
+	"github.com/gsmcwhirter/prettify/pkg/theme"
+)
+
+func BenchmarkRenderer_Render(b *testing.B) {
+	for _, c := range testutil.LogCorpora(1000) {
+		c := c
+		lines := make([]string, len(c.Lines))
+		for i, l := range c.Lines {
+			lines[i] = string(l)
+		}
+
+		b.Run(c.Name, func(b *testing.B) {
+			r := NewRenderer(Options{})
+
+			b.SetBytes(c.AverageLineSize())
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = r.Render(lines[i%len(lines)])
+			}
+		})
+	}
+}
+
+func BenchmarkRenderer_AppendRender(b *testing.B) {
+	th, err := theme.Resolve(theme.DefaultName, nil, theme.Depth256)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, c := range testutil.LogCorpora(1000) {
+		c := c
+		b.Run(c.Name, func(b *testing.B) {
+			r := NewRenderer(Options{Theme: th})
+			var buf []byte
+
+			b.SetBytes(c.AverageLineSize())
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf = r.AppendRender(buf[:0], c.Lines[i%len(c.Lines)])
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+// Package human contains the human-friendly `ts |LEVL| message key=val` rendering of json log lines
+package human
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/gjson"
+
+	"github.com/gsmcwhirter/prettify/internal/textutil"
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/theme"
+)
+
+// autoFields are fields added automatically by loggers, which are hidden unless requested
+var autoFields = map[string]bool{
+	"caller": true,
+}
+
+// Options controls the behavior of NewRenderer-created objects
+//
+// MessageField, TimestampField, LevelField and StackField name the fields given special placement
+// Output lists the only other fields to show (all when empty), and Exclude lists fields never to show
+// MultilineFields are fields with multiline content, which are shown last and indented
+// AutoFields includes automatically added fields (like caller) in the output
+// AllStacks shows the stack field for non-error lines too, and SkipStacks never shows it
+// MultilineTags puts each field on its own line
+// Theme provides the colors (no color at all when nil)
+type Options struct {
+	MessageField    string
+	TimestampField  string
+	LevelField      string
+	StackField      string
+	Output          []string
+	Exclude         []string
+	MultilineFields []string
+	AutoFields      bool
+	AllStacks       bool
+	SkipStacks      bool
+	MultilineTags   bool
+	Theme           *theme.Theme
+}
+
+// Renderer turns json log lines into the human-friendly format
+//
+// A Renderer is safe for concurrent use
+type Renderer struct {
+	opts          Options
+	specialFields map[string]bool
+	outputFields  map[string]bool
+	excludeFields map[string]bool
+	fill          string
+	multilineFill string
+
+	tsSeq     [2]string
+	keySeq    [2]string
+	levelSeqs map[string][2]string
+
+	scratch sync.Pool
+}
+
+// NewRenderer creates a new Renderer, filling in the usual field names where they are not provided
+func NewRenderer(opts Options) *Renderer {
+	if opts.MessageField == "" {
+		opts.MessageField = "message"
+	}
+
+	if opts.TimestampField == "" {
+		opts.TimestampField = "timestamp"
+	}
+
+	if opts.LevelField == "" {
+		opts.LevelField = "level"
+	}
+
+	if opts.StackField == "" {
+		opts.StackField = "stack"
+	}
+
+	r := &Renderer{
+		opts: opts,
+		specialFields: map[string]bool{
+			opts.MessageField:   true,
+			opts.TimestampField: true,
+			opts.LevelField:     true,
+			opts.StackField:     true,
+		},
+		outputFields:  map[string]bool{},
+		excludeFields: map[string]bool{},
+		fill:          " ",
+		multilineFill: "\n\t",
+		levelSeqs:     map[string][2]string{},
+	}
+
+	r.scratch.New = func() interface{} {
+		return newScratch()
+	}
+
+	for _, f := range opts.MultilineFields {
+		r.specialFields[f] = true
+	}
+
+	for _, oField := range opts.Output {
+		r.outputFields[oField] = true
+	}
+
+	for _, eField := range opts.Exclude {
+		r.excludeFields[eField] = true
+	}
+
+	if opts.MultilineTags {
+		r.fill = "\n\t"
+		r.multilineFill = "\n\t\t"
+	}
+
+	if th := opts.Theme; th != nil {
+		r.tsSeq[0], r.tsSeq[1] = th.Sequences(th.Timestamp)
+		r.keySeq[0], r.keySeq[1] = th.Sequences(th.Key)
+
+		for level, style := range th.Levels {
+			var seq [2]string
+			seq[0], seq[1] = th.Sequences(style)
+			r.levelSeqs[level] = seq
+		}
+	}
+
+	return r
+}
+
+// field is a top-level key and value of a line
+type field struct {
+	key   string
+	value gjson.Result
+}
+
+// scratch holds the per-line working space, so it can be reused between lines
+type scratch struct {
+	fields   []field
+	lines    []string
+	addField func(key, value gjson.Result) bool
+	addLine  func(_, value gjson.Result) bool
+}
+
+func newScratch() *scratch {
+	sc := &scratch{}
+	sc.addField = func(key, value gjson.Result) bool {
+		if k := key.String(); k != "" {
+			sc.fields = append(sc.fields, field{key: k, value: value})
+		}
+		return true // keep iterating
+	}
+	sc.addLine = func(_, value gjson.Result) bool {
+		sc.lines = append(sc.lines, value.String())
+		return true // keep iterating
+	}
+
+	return sc
+}
+
+func (sc *scratch) reset() {
+	for i := range sc.fields {
+		sc.fields[i] = field{}
+	}
+	sc.fields = sc.fields[:0]
+
+	for i := range sc.lines {
+		sc.lines[i] = ""
+	}
+	sc.lines = sc.lines[:0]
+}
+
+// sortFields is an insertion sort by key, which suits the short field lists of log lines and does not allocate
+func sortFields(fields []field) {
+	for i := 1; i < len(fields); i++ {
+		for j := i; j > 0 && fields[j].key < fields[j-1].key; j-- {
+			fields[j], fields[j-1] = fields[j-1], fields[j]
+		}
+	}
+}
+
+// lookup finds the value of key (the last one, if a line repeats it)
+func lookup(fields []field, key string) gjson.Result {
+	var res gjson.Result
+	for i := range fields {
+		if fields[i].key == key {
+			res = fields[i].value
+		}
+	}
+
+	return res
+}
+
+// Render formats a single line (without its trailing newline); the result has no trailing newline either
+func (r *Renderer) Render(line string) string {
+	return string(r.render(make([]byte, 0, len(line)+64), line))
+}
+
+// AppendRender appends the formatted form of a single line (without its trailing newline) to dst
+//
+// line is not retained, so the caller may reuse it right away
+func (r *Renderer) AppendRender(dst, line []byte) []byte {
+	return r.render(dst, textutil.BytesToString(line))
+}
+
+// AppendRecord appends the formatted form of a record's line to dst, using the json the record has (or will have) parsed
+func (r *Renderer) AppendRecord(dst []byte, rec *linehandler.Record) []byte {
+	return r.renderJSON(dst, strings.TrimSpace(rec.Line), rec.JSON())
+}
+
+func (r *Renderer) render(dst []byte, line string) []byte {
+	line = strings.TrimSpace(line)
+	return r.renderJSON(dst, line, gjson.Parse(line))
+}
+
+// renderJSON formats a (trimmed) line, whose parsed json is obj
+func (r *Renderer) renderJSON(dst []byte, line string, obj gjson.Result) []byte {
+	sc := r.scratch.Get().(*scratch)
+	defer func() {
+		sc.reset()
+		r.scratch.Put(sc)
+	}()
+
+	obj.ForEach(sc.addField)
+	sortFields(sc.fields)
+
+	colored := r.opts.Theme != nil && !color.NoColor
+
+	tsSeq, keySeq := noSeq, noSeq
+	if colored {
+		tsSeq, keySeq = r.tsSeq, r.keySeq
+	}
+
+	dst = appendPainted(dst, tsSeq, lookup(sc.fields, r.opts.TimestampField).String())
+	dst = append(dst, " |"...)
+
+	isError := false
+	if lvl := lookup(sc.fields, r.opts.LevelField); lvl.Exists() {
+		level := lvl.String()
+		isError = strings.EqualFold(level, "ERROR")
+		dst = r.appendLevel(dst, level, colored)
+	} else {
+		dst = r.appendLevel(dst, "NONE", colored)
+	}
+
+	dst = append(dst, "| "...)
+
+	if !strings.HasPrefix(line, "{") {
+		dst = append(dst, line...)
+	} else {
+		dst = textutil.AppendScalar(dst, lookup(sc.fields, r.opts.MessageField))
+	}
+
+	for _, f := range sc.fields {
+		if _, ok := r.specialFields[f.key]; ok {
+			continue
+		}
+
+		if r.excludeFields[f.key] {
+			continue
+		}
+
+		if len(r.opts.Output) > 0 && !r.outputFields[f.key] { // only display requested fields
+			continue
+		}
+
+		if !r.opts.AutoFields && autoFields[f.key] && (len(r.opts.Output) == 0 || r.outputFields[f.key]) { // get rid of any non-requested auto-fields, unless AutoFields
+			continue
+		}
+
+		dst = append(dst, r.fill...)
+		dst = appendPainted(dst, keySeq, f.key)
+		dst = append(dst, '=')
+		dst = textutil.AppendScalar(dst, f.value)
+	}
+
+	for _, mlf := range r.opts.MultilineFields {
+		fld := lookup(sc.fields, mlf)
+		if !fld.Exists() {
+			continue
+		}
+
+		sc.lines = sc.lines[:0]
+		rest := strings.TrimSpace(fld.String())
+		for {
+			idx := strings.IndexByte(rest, '\n')
+			if idx < 0 {
+				sc.lines = append(sc.lines, rest)
+				break
+			}
+
+			sc.lines = append(sc.lines, rest[:idx])
+			rest = rest[idx+1:]
+		}
+
+		dst = r.appendMultiline(dst, keySeq, mlf, sc.lines)
+	}
+
+	if stack := lookup(sc.fields, r.opts.StackField); stack.Exists() && !r.opts.SkipStacks && (r.opts.AllStacks || isError) {
+		sc.lines = sc.lines[:0]
+		switch {
+		case stack.IsArray():
+			stack.ForEach(sc.addLine)
+		case stack.Type == gjson.Null:
+		default:
+			sc.lines = append(sc.lines, stack.String())
+		}
+
+		dst = r.appendMultiline(dst, keySeq, r.opts.StackField, sc.lines)
+	}
+
+	return dst
+}
+
+var noSeq = [2]string{"", ""}
+
+func appendPainted(dst []byte, seq [2]string, text string) []byte {
+	dst = append(dst, seq[0]...)
+	dst = append(dst, text...)
+	return append(dst, seq[1]...)
+}
+
+// appendLevel appends the first four letters of the upper-cased level, painted in the theme's style for it
+func (r *Renderer) appendLevel(dst []byte, level string, colored bool) []byte {
+	start := len(dst)
+
+	if isASCII(level) {
+		for i := 0; i < len(level) && i < 4; i++ {
+			c := level[i]
+			if 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			dst = append(dst, c)
+		}
+	} else {
+		level = strings.ToUpper(level)
+		if len(level) > 4 {
+			level = level[:4]
+		}
+		dst = append(dst, level...)
+	}
+
+	if !colored {
+		return dst
+	}
+
+	seq, ok := r.levelSeqs[string(dst[start:])]
+	if !ok || seq[0] == "" {
+		return dst
+	}
+
+	// re-append the level text inside the sequences
+	var text [4]byte
+	n := copy(text[:], dst[start:])
+	dst = dst[:start]
+
+	return appendPainted(dst, seq, textutil.BytesToString(text[:n]))
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Renderer) appendMultiline(dst []byte, keySeq [2]string, key string, lines []string) []byte {
+	dst = append(dst, "\n\t"...)
+	dst = appendPainted(dst, keySeq, key)
+	dst = append(dst, '=')
+	dst = append(dst, r.multilineFill...)
+
+	for i, l := range lines {
+		if i > 0 {
+			dst = append(dst, r.multilineFill...)
+		}
+		dst = append(dst, l...)
+	}
+
+	return dst
+}
+
+// NewLineHandler returns a linehandler.FilterLineHandler that prints lines rendered by a new Renderer
+//
+// lpOpts controls the printing as for linehandler.NewLinePrinter; its Expression, JSONPath and Pretty settings are ignored
+func NewLineHandler(opts Options, lpOpts linehandler.Options) linehandler.FilterLineHandler {
+	lpOpts.RecordFormatter = NewRenderer(opts).AppendRecord
+	return linehandler.NewLinePrinter(lpOpts)
+}
@@ -0,0 +1,146 @@
+package human
+
+import (
+	"testing"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/testutil"
+	"github.com/gsmcwhirter/prettify/pkg/theme"
+)
+
+func TestRenderer_Render(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		opts Options
+		line string
+		want string
+	}{
+		{
+			name: "basic",
+			line: `{"timestamp": "2021-01-01T00:00:00Z", "level": "info", "message": "hello", "b": 2, "a": "x"}`,
+			want: "2021-01-01T00:00:00Z |INFO| hello a=x b=2",
+		},
+		{
+			name: "not json",
+			line: "  plain text\n",
+			want: " |NONE| plain text",
+		},
+		{
+			name: "short level",
+			line: `{"level": "w", "message": "hi"}`,
+			want: " |W| hi",
+		},
+		{
+			name: "custom fields",
+			opts: Options{MessageField: "msg", TimestampField: "ts", LevelField: "lvl"},
+			line: `{"ts": "now", "lvl": "debug", "msg": "hi", "message": "other"}`,
+			want: "now |DEBU| hi message=other",
+		},
+		{
+			name: "output and exclude",
+			opts: Options{Output: []string{"a", "b"}, Exclude: []string{"b"}},
+			line: `{"message": "hi", "a": 1, "b": 2, "c": 3}`,
+			want: " |NONE| hi a=1",
+		},
+		{
+			name: "auto fields hidden",
+			line: `{"message": "hi", "caller": "main.go:1"}`,
+			want: " |NONE| hi",
+		},
+		{
+			name: "auto fields shown",
+			opts: Options{AutoFields: true},
+			line: `{"message": "hi", "caller": "main.go:1"}`,
+			want: " |NONE| hi caller=main.go:1",
+		},
+		{
+			name: "multiline tags",
+			opts: Options{MultilineTags: true},
+			line: `{"message": "hi", "a": 1, "b": 2}`,
+			want: " |NONE| hi\n\ta=1\n\tb=2",
+		},
+		{
+			name: "multiline fields",
+			opts: Options{MultilineFields: []string{"sql"}},
+			line: `{"message": "hi", "sql": "SELECT 1\nFROM t\n"}`,
+			want: " |NONE| hi\n\tsql=\n\tSELECT 1\n\tFROM t",
+		},
+		{
+			name: "error stack",
+			line: `{"level": "error", "message": "bad", "stack": ["a.go:1", "b.go:2"]}`,
+			want: " |ERRO| bad\n\tstack=\n\ta.go:1\n\tb.go:2",
+		},
+		{
+			name: "non-error stack",
+			line: `{"level": "info", "message": "ok", "stack": ["a.go:1"]}`,
+			want: " |INFO| ok",
+		},
+		{
+			name: "all stacks",
+			opts: Options{AllStacks: true},
+			line: `{"level": "info", "message": "ok", "stack": ["a.go:1"]}`,
+			want: " |INFO| ok\n\tstack=\n\ta.go:1",
+		},
+		{
+			name: "no stacks",
+			opts: Options{AllStacks: true, SkipStacks: true},
+			line: `{"level": "error", "message": "bad", "stack": ["a.go:1"]}`,
+			want: " |ERRO| bad",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := NewRenderer(tt.opts).Render(tt.line); got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderer_Render_Theme(t *testing.T) {
+	t.Parallel()
+	th, err := theme.Resolve(theme.DefaultName, nil, theme.Depth16)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	want := th.Paint(th.Timestamp, "now") + " |" + th.Paint(th.Levels["INFO"], "INFO") + "| hi " + th.Paint(th.Key, "a") + "=1"
+	if got := NewRenderer(Options{Theme: th}).Render(`{"timestamp": "now", "level": "info", "message": "hi", "a": 1}`); got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestNewLineHandler(t *testing.T) {
+	t.Parallel()
+	buffer := testutil.NewPrintfBuffer(1024)
+	lh := NewLineHandler(Options{}, linehandler.Options{
+		WithFilename: true,
+		JSONPath:     "message",
+		Output:       &buffer,
+	})
+
+	lh.HandleLine(&linehandler.Record{Path: "test", Line: "{\"level\": \"warn\", \"message\": \"hi\", \"a\": 1}\n"})
+
+	if got, want := string(buffer.GetData()), "test:  |WARN| hi a=1\n"; got != want {
+		t.Errorf("HandleLine() output = %q, want %q", got, want)
+	}
+}
+
+func TestRenderer_AppendRecord(t *testing.T) {
+	t.Parallel()
+	r := NewRenderer(Options{})
+	for _, line := range []string{
+		`{"timestamp": "now", "level": "error", "message": "hi", "a": 1.5, "stack": ["f1", "f2"]}` + "\n",
+		"  not json\n",
+	} {
+		rec := &linehandler.Record{Line: line}
+		rec.JSON() // parsed ahead, as an earlier handler would
+
+		if got, want := string(r.AppendRecord([]byte("> "), rec)), "> "+r.Render(line); got != want {
+			t.Errorf("AppendRecord() = %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package human
+
+import (
+	"io"
+	"log/slog"
+)
+
+// NewSlogHandler creates a slog.Handler that renders records onto out the way prettify would
+//
+// hOpts (which may be nil) are the usual slog handler options; the time, level and message attributes
+// are renamed to the fields named in opts, so a non-nil ReplaceAttr should use the slog key names
+func NewSlogHandler(out io.Writer, opts Options, hOpts *slog.HandlerOptions) slog.Handler {
+	r := NewRenderer(opts)
+
+	var handlerOpts slog.HandlerOptions
+	if hOpts != nil {
+		handlerOpts = *hOpts
+	}
+
+	replace := handlerOpts.ReplaceAttr
+	handlerOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if replace != nil {
+			a = replace(groups, a)
+		}
+
+		if len(groups) > 0 {
+			return a
+		}
+
+		switch a.Key {
+		case slog.TimeKey:
+			a.Key = r.opts.TimestampField
+		case slog.LevelKey:
+			a.Key = r.opts.LevelField
+		case slog.MessageKey:
+			a.Key = r.opts.MessageField
+		}
+
+		return a
+	}
+
+	w := &Writer{
+		out:      out,
+		renderer: r,
+	}
+
+	return slog.NewJSONHandler(w, &handlerOpts)
+}
@@ -0,0 +1,45 @@
+package human
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestNewSlogHandler(t *testing.T) {
+	t.Parallel()
+	dropTime := func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}
+
+	var out bytes.Buffer
+	logger := slog.New(NewSlogHandler(&out, Options{}, &slog.HandlerOptions{ReplaceAttr: dropTime}))
+
+	logger.Debug("hidden")
+	logger.With("svc", "api").Info("started", "port", 8080)
+	logger.WithGroup("req").Warn("slow", "ms", 1200)
+	logger.Error("failed", "err", errors.New("boom"))
+
+	want := " |INFO| started port=8080 svc=api\n" +
+		" |WARN| slow req={\"ms\":1200}\n" +
+		" |ERRO| failed err=boom\n"
+	if got := out.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestNewSlogHandler_Fields(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	logger := slog.New(NewSlogHandler(&out, Options{MessageField: "msg", LevelField: "lvl", TimestampField: "ts"}, nil))
+
+	logger.Info("hi")
+
+	if got := out.String(); bytes.Count(out.Bytes(), []byte("|INFO| hi\n")) != 1 || got[0] == ' ' {
+		t.Errorf("output = %q, want a timestamp then |INFO| hi", got)
+	}
+}
@@ -0,0 +1,75 @@
+package human
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Writer is an io.Writer that renders each complete line written to it onto an underlying writer
+//
+// Partial lines are held until their newline arrives (or Flush is called). It is safe for concurrent use.
+type Writer struct {
+	lock     sync.Mutex
+	out      io.Writer
+	renderer *Renderer
+	buf      []byte
+}
+
+// NewWriter creates a Writer rendering onto out with the given options
+func NewWriter(out io.Writer, opts Options) *Writer {
+	return &Writer{
+		out:      out,
+		renderer: NewRenderer(opts),
+	}
+}
+
+// Write buffers p and renders any lines it completes
+//
+// It reports len(p) written unless the underlying writer fails
+func (w *Writer) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+
+		line := string(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+
+		if err := w.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(w.buf) == 0 {
+		w.buf = nil // let go of any large backing array
+	}
+
+	return len(p), nil
+}
+
+// Flush renders any partial line still held
+func (w *Writer) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	line := string(w.buf)
+	w.buf = nil
+
+	return w.writeLine(line)
+}
+
+func (w *Writer) writeLine(line string) error {
+	_, err := io.WriteString(w.out, w.renderer.Render(line)+"\n")
+	return err
+}
@@ -0,0 +1,81 @@
+package human
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWriter_Write(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		writes []string
+		flush  bool
+		want   string
+	}{
+		{
+			name:   "whole lines",
+			writes: []string{"{\"message\": \"a\"}\n{\"message\": \"b\"}\n"},
+			want:   " |NONE| a\n |NONE| b\n",
+		},
+		{
+			name:   "partial lines",
+			writes: []string{"{\"mess", "age\": \"a\"}\n{\"message\"", ": \"b\"}"},
+			want:   " |NONE| a\n",
+		},
+		{
+			name:   "flushed partial line",
+			writes: []string{"{\"message\": \"a\"}\n{\"message\": \"b\"}"},
+			flush:  true,
+			want:   " |NONE| a\n |NONE| b\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var out bytes.Buffer
+			w := NewWriter(&out, Options{})
+
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				if n != len(s) {
+					t.Errorf("Write() = %v, want %v", n, len(s))
+				}
+			}
+
+			if tt.flush {
+				if err := w.Flush(); err != nil {
+					t.Fatalf("Flush() error = %v", err)
+				}
+			}
+
+			if got := out.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errors.New("boom")
+}
+
+func TestWriter_Write_Error(t *testing.T) {
+	t.Parallel()
+	w := NewWriter(failWriter{}, Options{})
+
+	if _, err := w.Write([]byte("partial")); err != nil {
+		t.Errorf("Write() error = %v, want nil for a partial line", err)
+	}
+
+	if _, err := w.Write([]byte(" line\n")); err == nil {
+		t.Errorf("Write() expected an error from the underlying writer")
+	}
+}
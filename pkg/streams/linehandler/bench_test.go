@@ -0,0 +1,43 @@
+package linehandler
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gsmcwhirter/prettify/pkg/testutil"
+)
+
+func BenchmarkLinePrinter_HandleLine(b *testing.B) {
+	modes := []struct {
+		name string
+		opts Options
+	}{
+		{name: "raw", opts: Options{}},
+		{name: "output", opts: Options{JSONPath: "timestamp,level,message,trace_id,|@tsv"}},
+		{name: "pretty", opts: Options{Pretty: true, Sort: true}},
+	}
+
+	for _, c := range testutil.LogCorpora(1000) {
+		c := c
+		lines := make([]string, len(c.Lines))
+		for i, l := range c.Lines {
+			lines[i] = string(l) + "\n"
+		}
+
+		for _, m := range modes {
+			m := m
+			b.Run(c.Name+"/"+m.name, func(b *testing.B) {
+				m.opts.Output = io.Discard
+				lp := NewLinePrinter(m.opts)
+
+				b.SetBytes(c.AverageLineSize())
+				b.ReportAllocs()
+				rec := Record{Path: "bench.log"}
+				for i := 0; i < b.N; i++ {
+					rec.Line = lines[i%len(lines)]
+					lp.HandleLine(&rec)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package linehandler
+
+// HandlerFunc adapts a function to the LineHandler interface
+type HandlerFunc func(rec *Record) error
+
+// HandleLine calls f(rec)
+func (f HandlerFunc) HandleLine(rec *Record) error {
+	return f(rec)
+}
+
+// Middleware is a stage of a line handling pipeline, which wraps the handler that comes after it
+//
+// A stage can drop lines (by not passing them along), change them, or pass them to several handlers.
+// It should pass back the errors of the handlers after it.
+type Middleware func(next LineHandler) LineHandler
+
+// Chain composes stages into one Middleware that passes lines through each of them in order, so
+//
+//	Chain(filter, redact, dedupe)(Tee(printer, fileSink))
+//
+// filters, then redacts, then dedupes lines before both printing them and writing them to a file.
+func Chain(stages ...Middleware) Middleware {
+	return func(next LineHandler) LineHandler {
+		for i := len(stages) - 1; i >= 0; i-- {
+			next = stages[i](next)
+		}
+
+		return next
+	}
+}
+
+// Tee returns a LineHandler that passes every line to each of handlers in turn
+//
+// It stops at the first handler that returns an error, and returns that error.
+func Tee(handlers ...LineHandler) LineHandler {
+	return HandlerFunc(func(rec *Record) error {
+		for _, h := range handlers {
+			if err := h.HandleLine(rec); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Filter returns a stage that only passes along the records keep returns true for
+func Filter(keep func(rec *Record) bool) Middleware {
+	return func(next LineHandler) LineHandler {
+		return HandlerFunc(func(rec *Record) error {
+			if !keep(rec) {
+				return nil
+			}
+
+			return next.HandleLine(rec)
+		})
+	}
+}
+
+// Map returns a stage that passes along records with their text as changed by fn (which returns it without a newline)
+func Map(fn func(rec *Record) string) Middleware {
+	return func(next LineHandler) LineHandler {
+		return HandlerFunc(func(rec *Record) error {
+			return next.HandleLine(rec.WithText(fn(rec)))
+		})
+	}
+}
+
+// Discard is a LineHandler that drops every line
+var Discard LineHandler = HandlerFunc(func(*Record) error {
+	return nil
+})
+
+// LineNumberer is implemented by line handlers that can tell whether they use the Number and Offset of records
+//
+// Working out line numbers means reading a file from its start, so readers skip it for handlers that do not use them.
+type LineNumberer interface {
+	UsesLineNumbers() bool
+}
+
+// UsesLineNumbers reports whether lh uses the Number (or Offset) of records, which it does unless it is a LineNumberer saying otherwise
+func UsesLineNumbers(lh LineHandler) bool {
+	if n, ok := lh.(LineNumberer); ok {
+		return n.UsesLineNumbers()
+	}
+
+	return true
+}
+
+// WithoutLineNumbers marks lh (usually the start of a chain) as not using the Number and Offset of records,
+// so the records it gets may not have them
+func WithoutLineNumbers(lh LineHandler) LineHandler {
+	return withoutLineNumbers{lh}
+}
+
+type withoutLineNumbers struct {
+	LineHandler
+}
+
+func (withoutLineNumbers) UsesLineNumbers() bool {
+	return false
+}
@@ -0,0 +1,110 @@
+package linehandler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recorder is a LineHandler that keeps the lines it is given
+type recorder struct {
+	name  string
+	lines *[]string
+}
+
+func (r recorder) HandleLine(rec *Record) error {
+	*r.lines = append(*r.lines, r.name+":"+rec.Path+":"+rec.Line)
+	return nil
+}
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+	var got []string
+	printer := recorder{name: "printer", lines: &got}
+	sink := recorder{name: "sink", lines: &got}
+
+	lh := Chain(
+		Filter(func(rec *Record) bool { return rec.Text() != "drop" }),
+		Map(func(rec *Record) string { return strings.ToUpper(rec.Text()) }),
+	)(Tee(printer, Chain(Filter(func(rec *Record) bool { return strings.HasPrefix(rec.Text(), "K") }))(sink)))
+
+	for _, line := range []string{"keep\n", "drop\n", "other", "drop"} {
+		if err := lh.HandleLine(&Record{Path: "f", Line: line}); err != nil {
+			t.Errorf("HandleLine(%q) error = %v", line, err)
+		}
+	}
+
+	want := []string{
+		"printer:f:KEEP\n",
+		"sink:f:KEEP\n",
+		"printer:f:OTHER",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("handled lines = %q, want %q", got, want)
+	}
+}
+
+func TestChain_empty(t *testing.T) {
+	t.Parallel()
+	var got []string
+	r := recorder{name: "r", lines: &got}
+
+	if err := Chain()(r).HandleLine(&Record{Path: "f", Line: "line\n"}); err != nil {
+		t.Errorf("HandleLine() error = %v", err)
+	}
+
+	if want := []string{"r:f:line\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("handled lines = %q, want %q", got, want)
+	}
+}
+
+func TestTee(t *testing.T) {
+	t.Parallel()
+	errBroken := errors.New("broken")
+
+	var got []string
+	failing := HandlerFunc(func(rec *Record) error {
+		got = append(got, "failing:"+rec.Line)
+		if rec.Line == "fail\n" {
+			return errBroken
+		}
+		return nil
+	})
+
+	lh := Tee(Discard, failing, recorder{name: "last", lines: &got})
+
+	if err := lh.HandleLine(&Record{Path: "f", Line: "x\n"}); err != nil {
+		t.Errorf("HandleLine() error = %v", err)
+	}
+
+	if err := lh.HandleLine(&Record{Path: "f", Line: "fail\n"}); !errors.Is(err, errBroken) {
+		t.Errorf("HandleLine() error = %v, want %v", err, errBroken)
+	}
+
+	if want := []string{"failing:x\n", "last:f:x\n", "failing:fail\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("handled lines = %q, want %q", got, want)
+	}
+}
+
+func TestWithoutLineNumbers(t *testing.T) {
+	t.Parallel()
+	var got []string
+	lh := WithoutLineNumbers(recorder{name: "r", lines: &got})
+
+	if UsesLineNumbers(lh) {
+		t.Error("UsesLineNumbers(WithoutLineNumbers()) = true, want false")
+	}
+
+	if !UsesLineNumbers(Discard) {
+		t.Error("UsesLineNumbers(Discard) = false, want true")
+	}
+
+	if err := lh.HandleLine(&Record{Path: "f", Line: "x\n"}); err != nil {
+		t.Errorf("HandleLine() error = %v", err)
+	}
+
+	if want := []string{"r:f:x\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("handled lines = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package linehandler
+
+// DefaultContextSeparator goes between groups of lines that are not next to each other (as with grep)
+const DefaultContextSeparator = "--"
+
+// ContextOptions controls the behavior of NewContextHandler-created objects
+//
+// Match decides which records are shown
+// Before and After are the numbers of lines to show before and after each matching line (as grep -B and -A do)
+// Reversed is for lines that arrive last-first (as tac prints them), so that Before and After still refer to file order
+// Separator is passed along between groups of lines that are not next to each other (DefaultContextSeparator when empty);
+// it is only used when there is context to separate
+type ContextOptions struct {
+	Match     func(rec *Record) bool
+	Before    int
+	After     int
+	Reversed  bool
+	Separator string
+}
+
+// contextLine is a line held back in case a later line matches
+type contextLine struct {
+	rec Record
+	seq int
+}
+
+// ContextHandler is a LineHandler that only passes along matching lines, with the lines around them
+//
+// Lines from different files are never each other's context.
+type ContextHandler struct {
+	next      LineHandler
+	match     func(rec *Record) bool
+	before    int
+	after     int
+	separator string
+
+	ring      []contextLine // the last (up to) before lines not passed along, oldest first
+	afterLeft int
+	seq       int
+	lastSent  int // seq of the last line passed along (0 when none was)
+	path      string
+}
+
+// NewContextHandler wraps next in a ContextHandler configured by opts
+func NewContextHandler(next LineHandler, opts ContextOptions) *ContextHandler {
+	h := &ContextHandler{
+		next:      next,
+		match:     opts.Match,
+		before:    opts.Before,
+		after:     opts.After,
+		separator: opts.Separator,
+	}
+
+	if h.before < 0 {
+		h.before = 0
+	}
+
+	if h.after < 0 {
+		h.after = 0
+	}
+
+	if opts.Reversed {
+		h.before, h.after = h.after, h.before
+	}
+
+	if h.separator == "" {
+		h.separator = DefaultContextSeparator
+	}
+
+	h.ring = make([]contextLine, 0, h.before)
+
+	return h
+}
+
+// NewContextMiddleware returns a Middleware that wraps handlers in a ContextHandler (see Chain)
+func NewContextMiddleware(opts ContextOptions) Middleware {
+	return func(next LineHandler) LineHandler {
+		return NewContextHandler(next, opts)
+	}
+}
+
+// HandleLine passes along matching lines, the lines held back from before them, and the lines after them
+func (h *ContextHandler) HandleLine(rec *Record) error {
+	if rec.Path != h.path { // context does not cross files
+		h.path = rec.Path
+		h.ring = h.ring[:0]
+		h.afterLeft = 0
+		h.seq++
+	}
+	h.seq++
+
+	switch {
+	case h.match(rec):
+		for i := range h.ring {
+			if err := h.send(&h.ring[i].rec, h.ring[i].seq); err != nil {
+				return err
+			}
+		}
+		h.ring = h.ring[:0]
+
+		h.afterLeft = h.after
+		return h.send(rec, h.seq)
+	case h.afterLeft > 0:
+		h.afterLeft--
+		return h.send(rec, h.seq)
+	case h.before > 0:
+		if len(h.ring) == h.before {
+			copy(h.ring, h.ring[1:])
+			h.ring = h.ring[:len(h.ring)-1]
+		}
+		h.ring = append(h.ring, contextLine{rec: rec.Copy(), seq: h.seq})
+	}
+
+	return nil
+}
+
+func (h *ContextHandler) send(rec *Record, seq int) error {
+	if h.lastSent > 0 && seq != h.lastSent+1 && (h.before > 0 || h.after > 0) {
+		if err := h.next.HandleLine(&Record{Path: rec.Path, Line: h.separator + "\n"}); err != nil {
+			return err
+		}
+	}
+	h.lastSent = seq
+
+	return h.next.HandleLine(rec)
+}
@@ -0,0 +1,115 @@
+package linehandler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContextHandler_HandleLine(t *testing.T) {
+	t.Parallel()
+	lines := []string{"a", "b", "ERR 1", "c", "d", "e", "f", "ERR 2", "g", "ERR 3", "h", "i", "j"}
+	tests := []struct {
+		name string
+		opts ContextOptions
+		in   []string
+		want []string
+	}{
+		{
+			name: "no context",
+			in:   lines,
+			want: []string{"ERR 1", "ERR 2", "ERR 3"},
+		},
+		{
+			name: "before",
+			opts: ContextOptions{Before: 1},
+			in:   lines,
+			want: []string{"b", "ERR 1", "--", "f", "ERR 2", "g", "ERR 3"},
+		},
+		{
+			name: "after",
+			opts: ContextOptions{After: 2},
+			in:   lines,
+			want: []string{"ERR 1", "c", "d", "--", "ERR 2", "g", "ERR 3", "h", "i"},
+		},
+		{
+			name: "both, merging groups",
+			opts: ContextOptions{Before: 2, After: 2},
+			in:   lines,
+			want: []string{"a", "b", "ERR 1", "c", "d", "e", "f", "ERR 2", "g", "ERR 3", "h", "i"},
+		},
+		{
+			name: "before the start",
+			opts: ContextOptions{Before: 5, Separator: "~~"},
+			in:   lines[:4],
+			want: []string{"a", "b", "ERR 1"},
+		},
+		{
+			name: "reversed",
+			opts: ContextOptions{Before: 1, Reversed: true},
+			in:   []string{"j", "i", "h", "ERR 3", "g", "ERR 2", "f", "e"},
+			want: []string{"ERR 3", "g", "ERR 2", "f"},
+		},
+		{
+			name: "reversed after",
+			opts: ContextOptions{After: 1, Reversed: true},
+			in:   []string{"j", "i", "h", "ERR 3", "g", "ERR 2", "f", "e"},
+			want: []string{"h", "ERR 3", "g", "ERR 2"},
+		},
+		{
+			name: "separator",
+			opts: ContextOptions{After: 1, Separator: "~~"},
+			in:   []string{"ERR 1", "a", "b", "ERR 2"},
+			want: []string{"ERR 1", "a", "~~", "ERR 2"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var got []string
+			next := HandlerFunc(func(rec *Record) error {
+				got = append(got, rec.Text())
+				return nil
+			})
+
+			tt.opts.Match = func(rec *Record) bool { return strings.HasPrefix(rec.Text(), "ERR") }
+			h := NewContextHandler(next, tt.opts)
+
+			for _, line := range tt.in {
+				if err := h.HandleLine(&Record{Path: "f", Line: line + "\n"}); err != nil {
+					t.Errorf("HandleLine(%q) error = %v", line, err)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("passed along %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextHandler_HandleLine_files(t *testing.T) {
+	t.Parallel()
+	var got []string
+	next := HandlerFunc(func(rec *Record) error {
+		got = append(got, rec.Path+":"+rec.Line)
+		return nil
+	})
+
+	h := NewContextHandler(next, ContextOptions{
+		Match:  func(rec *Record) bool { return rec.Text() == "x" },
+		Before: 1,
+		After:  1,
+	})
+
+	for _, fl := range [][2]string{{"1", "a"}, {"1", "x"}, {"2", "b"}, {"2", "c"}, {"2", "x"}} {
+		if err := h.HandleLine(&Record{Path: fl[0], Line: fl[1]}); err != nil {
+			t.Errorf("HandleLine() error = %v", err)
+		}
+	}
+
+	if want := []string{"1:a", "1:x", "2:--\n", "2:c", "2:x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("passed along %q, want %q", got, want)
+	}
+}
@@ -1,15 +1,19 @@
 package linehandler
 
 import (
-	"fmt"
-	"strings"
+	"bytes"
+	"io"
+	"os"
+	"strconv"
 
 	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
 )
 
 // LineHandler is an interface for things that handle formatting and possibly skipping lines that should be considered for printing
+//
+// An error means the line could not be handled (e.g. the output is gone), and no more lines should be given.
 type LineHandler interface {
-	HandleLine(filename, line string) bool
+	HandleLine(rec *Record) error
 }
 
 // FilterLineHandler is an interface for LineHandlers that can additionally filter lines for more than being blank
@@ -18,94 +22,227 @@ type FilterLineHandler interface {
 }
 
 // linePrinter is a FilterLineHandler implementation
+//
+// It reuses its buffers between lines, so it is not safe for concurrent use
 type linePrinter struct {
 	withPath     string
+	expression   *formatter.Expression
 	withPretty   bool
 	withColor    bool
 	withSort     bool
 	withBlanks   bool
 	withFilename bool
-	printf       func(string, ...interface{}) (int, error)
+	withNumbers  bool
+	withOffsets  bool
+	vimGrep      bool
+	column       func(text string) int
+	prettyOpts   formatter.PrettyOptions
+	format       func(dst, line []byte) []byte
+	formatRecord func(dst []byte, rec *Record) []byte
+	output       io.Writer
+
+	in  []byte
+	out []byte
+
+	headerPending bool
 }
 
 // Options controls the behavior of NewLinePrinter-created objects
 //
 // WithBlanks controls whether empty lines are filtered out.
 // WithFilename controls whether each printed line is prefixed with the filename it came from or not.
+// WithLineNumbers and WithOffsets prefix each line from a file with its line number and byte offset (after any filename)
+// VimGrep prints lines from a file as path:line:column:text (the raw text, ignoring any formatting), for jumping to them in an editor;
+// Column gives the 1-based column to jump to in the text (1 when nil)
 // WithMemusage controls whether the tsar memusage line(s) will be printed
-// JSONPath determines the output format (raw line if this is empty)
+// Expression determines the output format (raw line if this is nil)
+// JSONPath is the uncompiled form of Expression, used when Expression is nil (and never reporting errors)
 // Pretty determines whether the lines will attempted to be made pretty (field per line, etc)
 // Color determines whether the lines are colorized or not
 // Sort determines whether the keys of a json line will be sorted or not
+// PrettyOptions controls the indentation, width, prefix, pinned keys and YAML mode of Pretty output (Sort also applies)
+// Formatter, when set, replaces the JSONPath/Pretty formatting; it appends the formatted line (given without its newline) to dst
+// RecordFormatter is like Formatter, but given the whole record (so it can use the json the record has parsed); it wins over Formatter
+// Output is where the lines are written (os.Stdout when nil); each line is a single Write, so buffering it (and flushing) is up to the caller
 type Options struct {
-	WithBlanks   bool
-	WithFilename bool
-	Pretty       bool
-	Color        bool
-	Sort         bool
-	JSONPath     string
-	Printf       func(string, ...interface{}) (int, error)
+	WithBlanks      bool
+	WithFilename    bool
+	WithLineNumbers bool
+	WithOffsets     bool
+	VimGrep         bool
+	Column          func(text string) int
+	Pretty          bool
+	Color           bool
+	Sort            bool
+	PrettyOptions   formatter.PrettyOptions
+	Expression      *formatter.Expression
+	JSONPath        string
+	Formatter       func(dst, line []byte) []byte
+	RecordFormatter func(dst []byte, rec *Record) []byte
+	Output          io.Writer
 }
 
 // NewLinePrinter returns a new struct that implements the FilterLineHandler interface
 func NewLinePrinter(opts Options) FilterLineHandler {
 	lp := &linePrinter{
 		withPath:     opts.JSONPath,
+		expression:   opts.Expression,
 		withPretty:   opts.Pretty,
 		withColor:    opts.Color,
 		withSort:     opts.Sort,
 		withBlanks:   opts.WithBlanks,
 		withFilename: opts.WithFilename,
-		printf:       opts.Printf,
+		withNumbers:  opts.WithLineNumbers,
+		withOffsets:  opts.WithOffsets,
+		vimGrep:      opts.VimGrep,
+		column:       opts.Column,
+		prettyOpts:   opts.PrettyOptions,
+		format:       opts.Formatter,
+		formatRecord: opts.RecordFormatter,
+		output:       opts.Output,
+	}
+
+	lp.prettyOpts.SortKeys = lp.prettyOpts.SortKeys || lp.withSort
+
+	if lp.expression == nil && lp.withPath != "" {
+		lp.expression = formatter.ParseExpression(lp.withPath)
+	}
+
+	if lp.format == nil && lp.formatRecord == nil {
+		lp.format = lp.defaultFormat()
+		lp.headerPending = lp.expression != nil && lp.expression.HasHeader()
 	}
 
-	if lp.printf == nil {
-		lp.printf = fmt.Printf
+	if lp.output == nil {
+		lp.output = os.Stdout
 	}
 
 	return lp
 }
 
-// HandleLine considers printing a line and handles formatting if it will print it
-func (lp *linePrinter) HandleLine(filename, line string) (lineHadNewline bool) {
-	maybeNewline := ""
-	l := strings.TrimRight(line, "\n")
-	if l != line {
-		maybeNewline = "\n"
-		lineHadNewline = true
+func (lp *linePrinter) defaultFormat() func(dst, line []byte) []byte {
+	switch {
+	case lp.expression != nil:
+		stream := lp.expression.NewStream()
+		stream.SetPrettyOptions(lp.prettyOpts)
+		return func(dst, line []byte) []byte {
+			return stream.AppendFormat(dst, line, lp.withPretty, lp.withColor, lp.withSort)
+		}
+	case lp.withPretty:
+		return func(dst, line []byte) []byte {
+			return lp.prettyOpts.AppendPretty(dst, line, lp.withColor)
+		}
+	default:
+		return func(dst, line []byte) []byte {
+			return append(dst, line...)
+		}
 	}
+}
 
-	lp.maybePrint(filename, l, maybeNewline)
+// HandleLine considers printing a line and handles formatting if it will print it
+func (lp *linePrinter) HandleLine(rec *Record) error {
+	l := rec.Text()
+
+	if lp.vimGrep {
+		return lp.printVimGrep(rec, l)
+	}
 
-	return lineHadNewline
+	return lp.maybePrint(rec, l, len(l) != len(rec.Line))
 }
 
-func (lp *linePrinter) maybePrint(filename, line, maybeNewline string) {
-	var toPrint string
+func (lp *linePrinter) maybePrint(rec *Record, line string, withNewline bool) error {
+	lp.in = append(lp.in[:0], line...)
+	lp.out = lp.appendPrefix(lp.out[:0], rec)
 
-	if lp.withPath == "" {
-		if lp.withPretty {
-			toPrint = formatter.PrettyLine(line, lp.withColor, lp.withSort)
-			toPrint = strings.TrimRight(toPrint, "\n")
-		} else {
-			toPrint = line
-		}
+	start := len(lp.out)
+	if lp.formatRecord != nil {
+		lp.out = lp.formatRecord(lp.out, rec)
 	} else {
-		toPrint = formatter.FormatLine(line, lp.withPath, lp.withPretty, lp.withColor, lp.withSort)
+		lp.out = lp.format(lp.out, lp.in)
 	}
 
-	if !lp.withBlanks && strings.TrimSpace(toPrint) == "" {
-		return
+	if !lp.withBlanks && len(bytes.TrimSpace(lp.out[start:])) == 0 {
+		return nil
 	}
 
-	var err error
+	lp.out = append(lp.out, rec.Suffix...)
+
+	if withNewline {
+		lp.out = append(lp.out, '\n')
+	}
+
+	if lp.headerPending {
+		lp.headerPending = false
+		if err := lp.printHeader(); err != nil {
+			return err
+		}
+	}
+
+	_, err := lp.output.Write(lp.out)
+
+	return err
+}
+
+// appendPrefix appends the filename, line number and offset of rec (as requested) to dst, as in "app.log:12:3456: "
+//
+// Line numbers and offsets are only given for lines from a file.
+func (lp *linePrinter) appendPrefix(dst []byte, rec *Record) []byte {
+	n := len(dst)
+
 	if lp.withFilename {
-		_, err = lp.printf("%s: %s%s", filename, toPrint, maybeNewline)
-	} else {
-		_, err = lp.printf("%s%s", toPrint, maybeNewline)
+		dst = append(dst, rec.Filename()...)
+		dst = append(dst, ':')
+	}
+
+	if rec.Number > 0 {
+		if lp.withNumbers {
+			dst = strconv.AppendInt(dst, int64(rec.Number), 10)
+			dst = append(dst, ':')
+		}
+
+		if lp.withOffsets {
+			dst = strconv.AppendInt(dst, rec.Offset, 10)
+			dst = append(dst, ':')
+		}
+	}
+
+	if len(dst) > n {
+		dst = append(dst, ' ')
+	}
+
+	return dst
+}
+
+// printVimGrep prints a line from a file as path:line:column:text (skipping any others, which have nowhere to jump to)
+func (lp *linePrinter) printVimGrep(rec *Record, text string) error {
+	if rec.Number == 0 {
+		return nil
 	}
 
-	if err != nil {
-		panic(err)
+	col := 1
+	if lp.column != nil {
+		col = lp.column(text)
 	}
+
+	lp.out = append(lp.out[:0], rec.Path...)
+	lp.out = append(lp.out, ':')
+	lp.out = strconv.AppendInt(lp.out, int64(rec.Number), 10)
+	lp.out = append(lp.out, ':')
+	lp.out = strconv.AppendInt(lp.out, int64(col), 10)
+	lp.out = append(lp.out, ':')
+	lp.out = append(lp.out, text...)
+	lp.out = append(lp.out, rec.Suffix...)
+	lp.out = append(lp.out, '\n')
+
+	_, err := lp.output.Write(lp.out)
+
+	return err
+}
+
+// printHeader prints the expression's header row (without any filename prefix, so the output stays loadable)
+func (lp *linePrinter) printHeader() error {
+	header := append(lp.expression.AppendHeader(nil), '\n')
+	_, err := lp.output.Write(header)
+
+	return err
 }
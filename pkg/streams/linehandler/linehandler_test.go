@@ -1,9 +1,13 @@
 package linehandler
 
 import (
+	"bytes"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
 	"github.com/gsmcwhirter/prettify/pkg/testutil"
 )
 
@@ -11,12 +15,13 @@ func TestNewLinePrinter(t *testing.T) {
 	t.Parallel()
 	expected := linePrinter{
 		withPath:     "a",
+		expression:   formatter.ParseExpression("a"),
 		withPretty:   true,
 		withColor:    true,
 		withSort:     false,
 		withBlanks:   true,
 		withFilename: true,
-		printf:       nil,
+		output:       nil,
 	}
 
 	type args struct {
@@ -56,14 +61,20 @@ func TestNewLinePrinter(t *testing.T) {
 				Pretty:       tt.args.withPretty,
 				Color:        tt.args.withColor,
 				Sort:         tt.args.withSort,
-				Printf:       nil,
+				Output:       nil,
 			})
 
-			if got.(*linePrinter).printf == nil {
-				t.Errorf("NewLinePrinter() didn't set printf default")
+			if got.(*linePrinter).output == nil {
+				t.Errorf("NewLinePrinter() didn't set output default")
 			}
 
-			got.(*linePrinter).printf = nil
+			got.(*linePrinter).output = nil
+
+			if got.(*linePrinter).format == nil {
+				t.Errorf("NewLinePrinter() didn't set format default")
+			}
+
+			got.(*linePrinter).format = nil
 
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewLinePrinter() = %+v, want %+v", got, tt.want)
@@ -250,10 +261,10 @@ func TestLinePrinter_HandleLine(t *testing.T) {
 				Pretty:       tt.lpArgs.withPretty,
 				Color:        tt.lpArgs.withColor,
 				Sort:         tt.lpArgs.withSort,
-				Printf:       buffer.Printf,
+				Output:       &buffer,
 			})
 
-			lp.HandleLine(tt.args.filename, tt.args.line)
+			lp.HandleLine(&Record{Path: tt.args.filename, Line: tt.args.line})
 
 			bufferBytes := buffer.GetData()
 			if !reflect.DeepEqual(bufferBytes, tt.wantBytes) && (len(bufferBytes) > 0 || len(tt.wantBytes) > 0) {
@@ -262,3 +273,210 @@ func TestLinePrinter_HandleLine(t *testing.T) {
 		})
 	}
 }
+
+func TestLinePrinter_HandleLine_Formatter(t *testing.T) {
+	t.Parallel()
+	buffer := testutil.NewPrintfBuffer(1024)
+	lp := NewLinePrinter(Options{
+		WithFilename: true,
+		JSONPath:     "a",
+		Formatter: func(dst, line []byte) []byte {
+			return append(dst, bytes.ToUpper(line)...)
+		},
+		Output: &buffer,
+	})
+
+	if err := lp.HandleLine(&Record{Path: "test", Line: "{\"a\": \"foo\"}\n"}); err != nil {
+		t.Errorf("HandleLine() error = %v", err)
+	}
+
+	if got, want := string(buffer.GetData()), "test: {\"A\": \"FOO\"}\n"; got != want {
+		t.Errorf("HandleLine() output = %q, want %q", got, want)
+	}
+}
+
+// failingWriter counts the writes to it, failing each one
+type failingWriter struct {
+	err   error
+	calls int
+}
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	w.calls++
+	return 0, w.err
+}
+
+func TestLinePrinter_HandleLine_writeError(t *testing.T) {
+	t.Parallel()
+	errClosed := errors.New("closed")
+	w := &failingWriter{err: errClosed}
+	lp := NewLinePrinter(Options{Output: w})
+
+	if err := lp.HandleLine(&Record{Path: "test", Line: "foo\n"}); !errors.Is(err, errClosed) {
+		t.Errorf("HandleLine() error = %v, want %v", err, errClosed)
+	}
+
+	if err := lp.HandleLine(&Record{Path: "test", Line: "\n"}); err != nil {
+		t.Errorf("HandleLine() of a skipped line error = %v, want nil", err)
+	}
+
+	if w.calls != 1 {
+		t.Errorf("Write calls = %d, want 1", w.calls)
+	}
+}
+
+func TestLinePrinter_HandleLine_location(t *testing.T) {
+	t.Parallel()
+	fromFile := Record{Path: "logs/app.log", Line: "{\"a\": \"foo\"}\n", Number: 12, Offset: 3456}
+	notice := Record{Path: "logs/app.log", Line: "--\n"}
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "line numbers",
+			opts: Options{WithLineNumbers: true},
+			want: "12: {\"a\": \"foo\"}\n--\n",
+		},
+		{
+			name: "everything",
+			opts: Options{WithFilename: true, WithLineNumbers: true, WithOffsets: true},
+			want: "app.log:12:3456: {\"a\": \"foo\"}\napp.log: --\n",
+		},
+		{
+			name: "vimgrep",
+			opts: Options{VimGrep: true, JSONPath: "a"},
+			want: "logs/app.log:12:1:{\"a\": \"foo\"}\n",
+		},
+		{
+			name: "vimgrep column",
+			opts: Options{VimGrep: true, Column: func(text string) int { return strings.Index(text, "foo") + 1 }},
+			want: "logs/app.log:12:8:{\"a\": \"foo\"}\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			buffer := testutil.NewPrintfBuffer(1024)
+			tt.opts.Output = &buffer
+			lp := NewLinePrinter(tt.opts)
+
+			for _, rec := range []Record{fromFile, notice} {
+				rec := rec
+				if err := lp.HandleLine(&rec); err != nil {
+					t.Errorf("HandleLine() error = %v", err)
+				}
+			}
+
+			if got := string(buffer.GetData()); got != tt.want {
+				t.Errorf("HandleLine() output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinePrinter_HandleLine_suffix(t *testing.T) {
+	t.Parallel()
+	buffer := testutil.NewPrintfBuffer(1024)
+	lp := NewLinePrinter(Options{JSONPath: "a", WithFilename: true, Output: &buffer})
+
+	if err := lp.HandleLine(&Record{Path: "app.log", Line: "{\"a\": \"foo\"}\n", Suffix: " (×2)"}); err != nil {
+		t.Errorf("HandleLine() error = %v", err)
+	}
+
+	if got, want := string(buffer.GetData()), "app.log: foo (×2)\n"; got != want {
+		t.Errorf("HandleLine() output = %q, want %q", got, want)
+	}
+}
+
+func TestLinePrinter_HandleLine_Expression(t *testing.T) {
+	t.Parallel()
+	expr, err := formatter.Compile("a,b,|@tsv")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	buffer := testutil.NewPrintfBuffer(1024)
+	lp := NewLinePrinter(Options{
+		Expression: expr,
+		JSONPath:   "ignored",
+		Output:     &buffer,
+	})
+
+	lp.HandleLine(&Record{Path: "test", Line: "{\"a\": \"foo\", \"b\": 1}\n"})
+
+	if got, want := string(buffer.GetData()), "foo\t1\n"; got != want {
+		t.Errorf("HandleLine() output = %q, want %q", got, want)
+	}
+}
+
+func TestLinePrinter_HandleLine_Header(t *testing.T) {
+	t.Parallel()
+	buffer := testutil.NewPrintfBuffer(1024)
+	lp := NewLinePrinter(Options{
+		WithFilename: true,
+		JSONPath:     "a,b,|@csv:header",
+		Output:       &buffer,
+	})
+
+	lp.HandleLine(&Record{Path: "test", Line: "{\"a\": \"x,y\", \"b\": 1}\n"})
+	lp.HandleLine(&Record{Path: "test", Line: "{\"a\": \"z\", \"b\": 2}\n"})
+
+	if got, want := string(buffer.GetData()), "a,b\ntest: \"x,y\",1\ntest: z,2\n"; got != want {
+		t.Errorf("HandleLine() output = %q, want %q", got, want)
+	}
+}
+
+func TestLinePrinter_HandleLine_PrettyOptions(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "indent and pinned keys",
+			opts: Options{
+				Pretty:        true,
+				Sort:          true,
+				PrettyOptions: formatter.PrettyOptions{Indent: "\t", PinnedKeys: []string{"msg"}},
+			},
+			want: "{\n\t\"msg\": \"hi\",\n\t\"a\": [1, 2],\n\t\"z\": true\n}\n",
+		},
+		{
+			name: "yaml",
+			opts: Options{
+				Pretty:        true,
+				PrettyOptions: formatter.PrettyOptions{YAML: true},
+			},
+			want: "z: true\nmsg: hi\na:\n  - 1\n  - 2\n",
+		},
+		{
+			name: "yaml expression",
+			opts: Options{
+				Pretty:        true,
+				JSONPath:      "a,msg",
+				PrettyOptions: formatter.PrettyOptions{YAML: true, Prefix: "> "},
+			},
+			want: "> - 1\n> - 2\nhi\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			buffer := testutil.NewPrintfBuffer(1024)
+			tt.opts.Output = &buffer
+			lp := NewLinePrinter(tt.opts)
+
+			lp.HandleLine(&Record{Path: "test", Line: `{"z": true, "msg": "hi", "a": [1, 2]}` + "\n"})
+
+			if got := string(buffer.GetData()); got != tt.want {
+				t.Errorf("HandleLine() output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package linehandler
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
+)
+
+// Record is a line to be handled, along with where it came from
+//
+// Path is the file the line was read from (as it was opened). Line keeps its trailing newline, if it had one.
+// Number is the 1-based line number in the file, and Offset the byte offset of the start of the line;
+// both are 0 for lines that did not come from the file (like notices and separators made up by handlers).
+// Raw is the line as it was read, before any handler changed Line (nil for lines that did not come from the file).
+// Suffix is shown after the formatted line (e.g. a count of collapsed duplicates), without being part of it.
+//
+// The json of the line is parsed when first asked for, and kept until Line changes,
+// so the handlers a line passes through share that work.
+//
+// A Record (and its Raw bytes) may be reused for the next line once HandleLine returns,
+// so handlers that hold on to one must Copy it.
+type Record struct {
+	Path   string
+	Line   string
+	Number int
+	Offset int64
+	Raw    []byte
+	Suffix string
+
+	json     gjson.Result
+	jsonFor  string
+	jsonDone bool
+}
+
+// Filename is the last element of the Path (what --with-filename shows)
+func (r *Record) Filename() string {
+	if r.Path == "" {
+		return ""
+	}
+
+	return filepath.Base(r.Path)
+}
+
+// Text is the Line without its newline
+func (r *Record) Text() string {
+	return strings.TrimRight(r.Line, "\n")
+}
+
+// WithText returns a copy of the record with its Line replaced by text (which is given without a newline),
+// keeping the newline if the Line had one
+func (r *Record) WithText(text string) *Record {
+	c := *r
+	if strings.HasSuffix(r.Line, "\n") {
+		text += "\n"
+	}
+	c.Line = text
+
+	return &c
+}
+
+// Copy returns a copy of the record that stays valid after HandleLine returns
+func (r *Record) Copy() Record {
+	c := *r
+	if c.Raw != nil {
+		c.Raw = append([]byte(nil), c.Raw...)
+	}
+
+	return c
+}
+
+// JSON is the line parsed as a json object (a zero gjson.Result, which does not exist, when it is not one)
+func (r *Record) JSON() gjson.Result {
+	if r.jsonDone && r.jsonFor == r.Line {
+		return r.json
+	}
+
+	r.json = gjson.Result{}
+	if text := strings.TrimSpace(r.Line); strings.HasPrefix(text, "{") {
+		r.json = gjson.Parse(text)
+	}
+	r.jsonFor, r.jsonDone = r.Line, true
+
+	return r.json
+}
+
+// Get is the value at path in the line's json (which does not exist when the line is not json, or has nothing there)
+//
+// gjson modifiers in path are looked up as field names (see formatter.EscapeModifiers).
+func (r *Record) Get(path string) gjson.Result {
+	res := r.JSON()
+	if !res.Exists() {
+		return res
+	}
+
+	return res.Get(formatter.EscapeModifiers(path))
+}
@@ -0,0 +1,50 @@
+package linehandler
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestRecord_JSON(t *testing.T) {
+	t.Parallel()
+	rec := &Record{Path: "logs/app.log", Line: `{"a": {"b": 1}}` + "\n", Raw: []byte("raw")}
+
+	if got := rec.Get("a.b").Int(); got != 1 {
+		t.Errorf("Get(a.b) = %v, want 1", got)
+	}
+
+	// gjson modifiers are just field names
+	mod := &Record{Line: `{"@this": "v", "@keys": "k"}`}
+	if got := mod.Get("@this").Raw; got != `"v"` {
+		t.Errorf("Get(@this) = %v, want %q", got, `"v"`)
+	}
+	if got := mod.Get("@keys").Raw; got != `"k"` {
+		t.Errorf("Get(@keys) = %v, want %q", got, `"k"`)
+	}
+
+	if rec.JSON().Type != gjson.JSON {
+		t.Errorf("JSON() type = %v, want %v", rec.JSON().Type, gjson.JSON)
+	}
+
+	// a changed line is parsed again
+	rec.Line = `{"a": {"b": 2}}`
+	if got := rec.Get("a.b").Int(); got != 2 {
+		t.Errorf("Get(a.b) after a change = %v, want 2", got)
+	}
+
+	changed := rec.WithText("plain")
+	if changed.JSON().Exists() {
+		t.Errorf("WithText() record has json %v, want none", changed.JSON())
+	}
+
+	c := rec.Copy()
+	rec.Raw[0] = 'R'
+	if string(c.Raw) != "raw" {
+		t.Errorf("Copy() Raw = %q after the original changed, want %q", c.Raw, "raw")
+	}
+
+	if got := c.Filename(); got != "app.log" {
+		t.Errorf("Filename() = %q, want %q", got, "app.log")
+	}
+}
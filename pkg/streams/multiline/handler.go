@@ -0,0 +1,90 @@
+package multiline
+
+import (
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+)
+
+// Handler is a linehandler.LineHandler that assembles the lines of multi-line events into one record before passing them along
+//
+// The record of an event has the Path, Number and Offset of its start line, and its lines (and Raw bytes) put together.
+// Events end at the end of a file. An event is only passed along once it is complete, so Flush must be called
+// when a file ends or the lines go idle.
+type Handler struct {
+	next      linehandler.LineHandler
+	assembler *Assembler
+	reversed  bool
+	start     linehandler.Record
+	raw       []byte
+	scratch   []byte
+}
+
+// NewHandler wraps next in a Handler configured by opts
+func NewHandler(next linehandler.LineHandler, opts Options) (*Handler, error) {
+	a, err := NewAssembler(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		next:      next,
+		assembler: a,
+		reversed:  opts.Reversed,
+	}, nil
+}
+
+// HandleLine adds the line to the event it belongs to, and passes along any event that is complete
+func (h *Handler) HandleLine(rec *linehandler.Record) error {
+	if h.assembler.Pending() > 0 && rec.Path != h.start.Path {
+		if err := h.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if h.reversed {
+		h.start = linehandler.Record{Path: rec.Path, Number: rec.Number, Offset: rec.Offset}
+		h.scratch = append(append(h.scratch[:0], rec.Raw...), h.raw...)
+		h.raw, h.scratch = h.scratch, h.raw
+
+		if event, ok := h.assembler.Add(rec.Line); ok {
+			return h.send(event)
+		}
+
+		return nil
+	}
+
+	if event, ok := h.assembler.Add(rec.Line); ok {
+		if err := h.send(event); err != nil {
+			return err
+		}
+	}
+
+	if h.assembler.Pending() == 1 {
+		h.start = linehandler.Record{Path: rec.Path, Number: rec.Number, Offset: rec.Offset}
+	}
+	h.raw = append(h.raw, rec.Raw...)
+
+	return nil
+}
+
+// Flush passes along the current event, if there is one
+func (h *Handler) Flush() error {
+	event, ok := h.assembler.Flush()
+	if !ok {
+		return nil
+	}
+
+	return h.send(event)
+}
+
+func (h *Handler) send(event string) error {
+	rec := h.start
+	rec.Line = event
+	if len(h.raw) > 0 {
+		rec.Raw = h.raw
+	}
+
+	err := h.next.HandleLine(&rec)
+	h.raw = h.raw[:0]
+
+	return err
+}
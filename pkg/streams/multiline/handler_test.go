@@ -0,0 +1,83 @@
+package multiline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+)
+
+func TestHandler_HandleLine(t *testing.T) {
+	t.Parallel()
+	type line struct {
+		path string
+		line string
+	}
+	tests := []struct {
+		name  string
+		opts  Options
+		lines []line
+		want  []linehandler.Record
+	}{
+		{
+			name: "in order",
+			lines: []line{
+				{"a.log", "ERROR failed\n"},
+				{"a.log", "\tat x\n"},
+				{"a.log", "INFO ok\n"},
+				{"a.log", "  trailing\n"},
+				{"b.log", "  other file\n"},
+			},
+			want: []linehandler.Record{
+				{Path: "a.log", Line: "ERROR failed\n\tat x\n", Number: 1, Offset: 0, Raw: []byte("ERROR failed\n\tat x\n")},
+				{Path: "a.log", Line: "INFO ok\n  trailing\n", Number: 3, Offset: 100, Raw: []byte("INFO ok\n  trailing\n")},
+				{Path: "b.log", Line: "  other file\n", Number: 5, Offset: 200, Raw: []byte("  other file\n")},
+			},
+		},
+		{
+			name: "reversed",
+			opts: Options{Reversed: true},
+			lines: []line{
+				{"a.log", "INFO ok\n"},
+				{"a.log", "\tat x\n"},
+				{"a.log", "ERROR failed\n"},
+			},
+			want: []linehandler.Record{
+				{Path: "a.log", Line: "INFO ok\n", Number: 1, Offset: 0, Raw: []byte("INFO ok\n")},
+				{Path: "a.log", Line: "ERROR failed\n\tat x\n", Number: 3, Offset: 100, Raw: []byte("ERROR failed\n\tat x\n")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var got []linehandler.Record
+			next := linehandler.HandlerFunc(func(rec *linehandler.Record) error {
+				got = append(got, linehandler.Record{Path: rec.Path, Line: rec.Line, Number: rec.Number, Offset: rec.Offset, Raw: append([]byte(nil), rec.Raw...)})
+				return nil
+			})
+
+			h, err := NewHandler(next, tt.opts)
+			if err != nil {
+				t.Fatalf("NewHandler() error = %v", err)
+			}
+
+			rec := &linehandler.Record{}
+			for i, l := range tt.lines {
+				*rec = linehandler.Record{Path: l.path, Line: l.line, Number: i + 1, Offset: int64(i * 50), Raw: []byte(l.line)} // reused, like the streamer does
+				if err := h.HandleLine(rec); err != nil {
+					t.Errorf("HandleLine() error = %v", err)
+				}
+			}
+
+			if err := h.Flush(); err != nil {
+				t.Errorf("Flush() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("passed along %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
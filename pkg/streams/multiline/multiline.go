@@ -0,0 +1,168 @@
+// Package multiline contains functionality for assembling the lines of multi-line events (like stack traces
+// in plain-text logs) into one
+package multiline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultMaxLines is the most lines in an event when Options.MaxLines is not set
+const DefaultMaxLines = 1000
+
+// DefaultStartPatterns match the lines that start an event: ones starting with a date or time, a json object, or a level keyword
+var DefaultStartPatterns = []string{
+	`^\[?\d{4}[-/]\d{2}[-/]\d{2}`,
+	`^\[?\d{2}:\d{2}:\d{2}`,
+	`^[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`,
+	`^\{`,
+	`(?i)^\[?(trace|debug|info|notice|warn|warning|error|err|fatal|panic|crit|critical)\b`,
+}
+
+// DefaultContinuationPatterns match the lines that continue an event: indented ones, and the frames of Java and Go traces
+var DefaultContinuationPatterns = []string{
+	`^\s`,
+	`^at `,
+	`^goroutine `,
+}
+
+// Options controls how lines are assembled into events
+//
+// Start are regular expressions for the lines that start an event (DefaultStartPatterns when empty)
+// Continuation are regular expressions for the lines that continue an event (DefaultContinuationPatterns when empty);
+// they win over Start, and lines that match neither continue an event too (like the exception line after a log line)
+// MaxLines is the most lines in an event, after which the next line starts a new one (DefaultMaxLines when not positive)
+// Reversed is for lines given last-first, so the continuation lines of an event come before its start
+type Options struct {
+	Start        []string
+	Continuation []string
+	MaxLines     int
+	Reversed     bool
+}
+
+// Assembler groups lines into events
+//
+// The lines are kept as they are given (with any newlines), so an event is just its lines put together
+type Assembler struct {
+	start        []*regexp.Regexp
+	continuation []*regexp.Regexp
+	maxLines     int
+	reversed     bool
+
+	lines []string
+}
+
+// NewAssembler creates a new Assembler configured by opts
+func NewAssembler(opts Options) (*Assembler, error) {
+	a := &Assembler{
+		maxLines: opts.MaxLines,
+		reversed: opts.Reversed,
+	}
+
+	if a.maxLines <= 0 {
+		a.maxLines = DefaultMaxLines
+	}
+
+	if len(opts.Start) == 0 {
+		opts.Start = DefaultStartPatterns
+	}
+
+	if len(opts.Continuation) == 0 {
+		opts.Continuation = DefaultContinuationPatterns
+	}
+
+	var err error
+	if a.start, err = compile(opts.Start); err != nil {
+		return nil, fmt.Errorf("bad start pattern: %w", err)
+	}
+
+	if a.continuation, err = compile(opts.Continuation); err != nil {
+		return nil, fmt.Errorf("bad continuation pattern: %w", err)
+	}
+
+	return a, nil
+}
+
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+
+	return res, nil
+}
+
+// Add adds a line to the current event, or starts a new one with it
+//
+// When an event is complete, it is returned: for lines in order, that is the previous event when the line starts a new one;
+// for reversed lines, it is the current one (ending with this line) when the line is its start.
+func (a *Assembler) Add(line string) (string, bool) {
+	if a.reversed {
+		a.lines = append(a.lines, line)
+		if !a.continues(line) || len(a.lines) >= a.maxLines {
+			return a.Flush()
+		}
+
+		return "", false
+	}
+
+	if len(a.lines) > 0 && len(a.lines) < a.maxLines && a.continues(line) {
+		a.lines = append(a.lines, line)
+		return "", false
+	}
+
+	event, ok := a.Flush()
+	a.lines = append(a.lines, line)
+
+	return event, ok
+}
+
+// Pending is the number of lines in the current event (0 when there is none)
+func (a *Assembler) Pending() int {
+	return len(a.lines)
+}
+
+// Flush ends the current event and returns it, if there is one
+//
+// This should be called when the lines end or go idle, so the event is not held back
+func (a *Assembler) Flush() (string, bool) {
+	if len(a.lines) == 0 {
+		return "", false
+	}
+
+	var sb strings.Builder
+	for i := range a.lines {
+		if a.reversed {
+			sb.WriteString(a.lines[len(a.lines)-1-i])
+		} else {
+			sb.WriteString(a.lines[i])
+		}
+	}
+	a.lines = a.lines[:0]
+
+	return sb.String(), true
+}
+
+// continues reports whether a line continues an event rather than starting one
+func (a *Assembler) continues(line string) bool {
+	line = strings.TrimRight(line, "\r\n")
+
+	for _, re := range a.continuation {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+
+	for _, re := range a.start {
+		if re.MatchString(line) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,107 @@
+package multiline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAssembler_Add(t *testing.T) {
+	t.Parallel()
+	java := []string{
+		"2024-01-02 10:00:00 ERROR Worker - failed\n",
+		"java.lang.IllegalStateException: boom\n",
+		"\tat com.example.Worker.run(Worker.java:12)\n",
+		"Caused by: java.io.IOException: gone\n",
+		"\t... 5 more\n",
+		"2024-01-02 10:00:01 INFO Worker - retrying\n",
+	}
+	goPanic := []string{
+		"INFO starting\n",
+		"panic: boom\n",
+		"\n",
+		"goroutine 1 [running]:\n",
+		"main.main()\n",
+		"\t/src/main.go:12 +0x1d\n",
+		"{\"message\": \"json\"}\n",
+	}
+
+	tests := []struct {
+		name  string
+		opts  Options
+		lines []string
+		want  []string
+	}{
+		{
+			name:  "java",
+			lines: java,
+			want: []string{
+				"2024-01-02 10:00:00 ERROR Worker - failed\njava.lang.IllegalStateException: boom\n\tat com.example.Worker.run(Worker.java:12)\nCaused by: java.io.IOException: gone\n\t... 5 more\n",
+				"2024-01-02 10:00:01 INFO Worker - retrying\n",
+			},
+		},
+		{
+			name:  "go panic",
+			lines: goPanic,
+			want: []string{
+				"INFO starting\n",
+				"panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/src/main.go:12 +0x1d\n",
+				"{\"message\": \"json\"}\n",
+			},
+		},
+		{
+			name:  "continuation before any start",
+			lines: []string{"  orphan\n", "ERROR x\n"},
+			want:  []string{"  orphan\n", "ERROR x\n"},
+		},
+		{
+			name:  "custom patterns",
+			opts:  Options{Start: []string{`^>`}, Continuation: []string{`^\+`}},
+			lines: []string{"> a\n", "+ b\n", "c\n", "> d\n"},
+			want:  []string{"> a\n+ b\nc\n", "> d\n"},
+		},
+		{
+			name:  "max lines",
+			opts:  Options{MaxLines: 2},
+			lines: []string{"ERROR a\n", " b\n", " c\n", " d\n"},
+			want:  []string{"ERROR a\n b\n", " c\n d\n"},
+		},
+		{
+			name:  "reversed",
+			opts:  Options{Reversed: true},
+			lines: []string{"INFO after\n", "\tat b\n", "\tat a\n", "ERROR failed\n", "  orphan\n"},
+			want:  []string{"INFO after\n", "ERROR failed\n\tat a\n\tat b\n", "  orphan\n"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a, err := NewAssembler(tt.opts)
+			if err != nil {
+				t.Fatalf("NewAssembler() error = %v", err)
+			}
+
+			var got []string
+			for _, line := range tt.lines {
+				if event, ok := a.Add(line); ok {
+					got = append(got, event)
+				}
+			}
+
+			if event, ok := a.Flush(); ok {
+				got = append(got, event)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAssembler_badPattern(t *testing.T) {
+	t.Parallel()
+	if _, err := NewAssembler(Options{Start: []string{"("}}); err == nil {
+		t.Errorf("NewAssembler() error = nil, want one")
+	}
+}
@@ -0,0 +1,182 @@
+// Package pipeline contains functionality for rendering lines on several goroutines while keeping their order
+package pipeline
+
+import (
+	"context"
+	"runtime"
+)
+
+// DefaultBatchSize is the batch size used when Options.BatchSize is not set
+const DefaultBatchSize = 256
+
+// Item is a single unit of input
+//
+// Done marks Line as already rendered, so it is written as-is (e.g. notices produced while reading)
+// Suffix is written right after the rendered Line (e.g. a count of collapsed duplicates)
+type Item struct {
+	Line   string
+	Done   bool
+	Suffix string
+}
+
+// Options controls the behavior of Run
+//
+// Workers is the number of goroutines rendering batches (runtime.NumCPU() when not positive)
+// BatchSize is the most items handed to a worker at once (DefaultBatchSize when not positive)
+// Render turns an input line into its output text
+type Options struct {
+	Workers   int
+	BatchSize int
+	Render    func(string) string
+}
+
+type job struct {
+	items []Item
+	done  chan []string
+}
+
+// Run renders the items from in on opts.Workers goroutines, and passes the results to write in input order
+//
+// Batches are cut short whenever in has nothing more ready, and flush is called whenever no more output is
+// ready, so interactive use sees each line right away. At most a few batches per worker are in flight at once,
+// so a slow writer slows down the reading of in. Run returns once in is closed and everything is written, or
+// at the first error from write or flush, or when ctx is done.
+func Run(ctx context.Context, in <-chan Item, opts Options, write func(string) error, flush func() error) error {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan job, opts.Workers)
+	results := make(chan chan []string, 2*opts.Workers)
+
+	go batch(ctx, in, opts.BatchSize, jobs, results)
+
+	for i := 0; i < opts.Workers; i++ {
+		go work(ctx, jobs, opts.Render)
+	}
+
+	for {
+		var slot chan []string
+		var ok bool
+
+		select {
+		case slot, ok = <-results:
+		default:
+			if err := flush(); err != nil {
+				return err
+			}
+
+			select {
+			case slot, ok = <-results:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if !ok {
+			break
+		}
+
+		var lines []string
+		select {
+		case lines = <-slot:
+		default:
+			if err := flush(); err != nil {
+				return err
+			}
+
+			select {
+			case lines = <-slot:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		for _, line := range lines {
+			if err := write(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// batch groups items from in, queueing each batch for a worker and its result slot for the writer (in order)
+func batch(ctx context.Context, in <-chan Item, size int, jobs chan<- job, results chan<- chan []string) {
+	defer close(results)
+	defer close(jobs)
+
+	for {
+		var items []Item
+
+		select {
+		case item, ok := <-in:
+			if !ok {
+				return
+			}
+			items = append(make([]Item, 0, size), item)
+		case <-ctx.Done():
+			return
+		}
+
+		more := true
+		for more && len(items) < size {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					more = false
+					break
+				}
+				items = append(items, item)
+			default:
+				more = false
+			}
+		}
+
+		j := job{items: items, done: make(chan []string, 1)}
+
+		select {
+		case results <- j.done:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case jobs <- j:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func work(ctx context.Context, jobs <-chan job, render func(string) string) {
+	for j := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		lines := make([]string, len(j.items))
+		for i, item := range j.items {
+			if item.Done {
+				lines[i] = item.Line
+			} else {
+				lines[i] = render(item.Line)
+			}
+			lines[i] += item.Suffix
+		}
+
+		j.done <- lines
+	}
+}
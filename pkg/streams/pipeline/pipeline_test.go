@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func feed(n int) <-chan Item {
+	in := make(chan Item)
+	go func() {
+		defer close(in)
+		for i := 0; i < n; i++ {
+			item := Item{Line: strconv.Itoa(i), Done: i%7 == 0}
+			if i%5 == 0 {
+				item.Suffix = "+"
+			}
+			in <- item
+		}
+	}()
+
+	return in
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		opts Options
+		n    int
+	}{
+		{name: "single worker", opts: Options{Workers: 1, BatchSize: 3}, n: 100},
+		{name: "many workers", opts: Options{Workers: 8, BatchSize: 5}, n: 5000},
+		{name: "defaults", n: 1000},
+		{name: "empty", opts: Options{Workers: 2}, n: 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.opts.Render = func(s string) string {
+				n, _ := strconv.Atoi(s)
+				time.Sleep(time.Duration(n%3) * time.Microsecond) // finish out of order
+				return "r" + s
+			}
+
+			var got []string
+			flushes := 0
+			err := Run(context.Background(), feed(tt.n), tt.opts, func(s string) error {
+				got = append(got, s)
+				return nil
+			}, func() error {
+				flushes++
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if len(got) != tt.n {
+				t.Fatalf("Run() wrote %v lines, want %v", len(got), tt.n)
+			}
+
+			for i, s := range got {
+				want := "r" + strconv.Itoa(i)
+				if i%7 == 0 {
+					want = strconv.Itoa(i)
+				}
+				if i%5 == 0 {
+					want += "+"
+				}
+				if s != want {
+					t.Fatalf("line %v = %v, want %v", i, s, want)
+				}
+			}
+
+			if flushes == 0 {
+				t.Errorf("Run() never flushed")
+			}
+		})
+	}
+}
+
+func TestRun_Interactive(t *testing.T) {
+	t.Parallel()
+	in := make(chan Item)
+	flushed := make(chan string, 1)
+
+	var pending []string
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(context.Background(), in, Options{Workers: 2, BatchSize: 100, Render: strings.ToUpper}, func(s string) error {
+			pending = append(pending, s)
+			return nil
+		}, func() error {
+			if len(pending) > 0 {
+				flushed <- strings.Join(pending, ",")
+				pending = nil
+			}
+			return nil
+		})
+	}()
+
+	for _, s := range []string{"a", "b"} {
+		in <- Item{Line: s}
+		select {
+		case got := <-flushed:
+			if got != strings.ToUpper(s) {
+				t.Errorf("flushed %v, want %v", got, strings.ToUpper(s))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("line %v was not flushed while waiting for more input", s)
+		}
+	}
+
+	close(in)
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestRun_WriteError(t *testing.T) {
+	t.Parallel()
+	boom := errors.New("boom")
+	err := Run(context.Background(), feed(10000), Options{Workers: 4, BatchSize: 8, Render: strings.ToUpper}, func(s string) error {
+		if s == "501" {
+			return boom
+		}
+		return nil
+	}, func() error { return nil })
+
+	if !errors.Is(err, boom) {
+		t.Errorf("Run() error = %v, want %v", err, boom)
+	}
+}
+
+func TestRun_Canceled(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Run(ctx, make(chan Item), Options{Workers: 2, Render: strings.ToUpper}, func(string) error { return nil }, func() error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+}
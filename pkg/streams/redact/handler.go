@@ -0,0 +1,29 @@
+package redact
+
+import "github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+
+// Handler is a linehandler.LineHandler that redacts lines before passing them along
+type Handler struct {
+	next     linehandler.LineHandler
+	redactor *Redactor
+}
+
+// NewHandler wraps next in a Handler that uses r
+func NewHandler(next linehandler.LineHandler, r *Redactor) *Handler {
+	return &Handler{
+		next:     next,
+		redactor: r,
+	}
+}
+
+// HandleLine redacts the line and passes it along
+func (h *Handler) HandleLine(rec *linehandler.Record) error {
+	return h.next.HandleLine(rec.WithText(h.redactor.RedactLine(rec.Text())))
+}
+
+// Middleware returns a linehandler.Middleware that redacts lines with r (see linehandler.Chain)
+func Middleware(r *Redactor) linehandler.Middleware {
+	return func(next linehandler.LineHandler) linehandler.LineHandler {
+		return NewHandler(next, r)
+	}
+}
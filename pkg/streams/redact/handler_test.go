@@ -0,0 +1,82 @@
+package redact
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/testutil"
+)
+
+func TestHandler_HandleLine(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		line      string
+		lpOpts    linehandler.Options
+		wantBytes []byte
+	}{
+		{
+			name:      "keeps the newline",
+			line:      "{\"token\": \"abc\"}\n",
+			wantBytes: []byte("{\"token\":\"[REDACTED]\"}\n"),
+		},
+		{
+			name:      "no newline",
+			line:      "{\"token\": \"abc\"}",
+			wantBytes: []byte("{\"token\":\"[REDACTED]\"}"),
+		},
+		{
+			name:      "before output expressions",
+			line:      "{\"token\": \"abc\", \"message\": \"hi\"}\n",
+			lpOpts:    linehandler.Options{JSONPath: "token,message,|@tsv"},
+			wantBytes: []byte("[REDACTED]\thi\n"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			buffer := testutil.NewPrintfBuffer(1024)
+			tt.lpOpts.Output = &buffer
+
+			r, err := New(Options{FieldPatterns: DefaultFieldPatterns})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			h := NewHandler(linehandler.NewLinePrinter(tt.lpOpts), r)
+			h.HandleLine(&linehandler.Record{Path: "test", Line: tt.line})
+
+			bufferBytes := buffer.GetData()
+			if !reflect.DeepEqual(bufferBytes, tt.wantBytes) {
+				t.Errorf("HandleLine() output = %v (\n%s), want %v (\n%s)", bufferBytes, string(bufferBytes), tt.wantBytes, string(tt.wantBytes))
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+	r, err := New(Options{FieldPatterns: DefaultFieldPatterns})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	printed := testutil.NewPrintfBuffer(1024)
+	written := testutil.NewPrintfBuffer(1024)
+
+	lh := linehandler.Chain(Middleware(r))(linehandler.Tee(
+		linehandler.NewLinePrinter(linehandler.Options{Output: &printed}),
+		linehandler.NewLinePrinter(linehandler.Options{JSONPath: "token", Output: &written}),
+	))
+	lh.HandleLine(&linehandler.Record{Path: "test", Line: "{\"token\": \"abc\"}\n"})
+
+	if got, want := string(printed.GetData()), "{\"token\":\"[REDACTED]\"}\n"; got != want {
+		t.Errorf("printed = %q, want %q", got, want)
+	}
+
+	if got, want := string(written.GetData()), "[REDACTED]\n"; got != want {
+		t.Errorf("written = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,253 @@
+// Package redact contains functionality for hiding secrets and personal data in log lines
+// before they are shown or shared
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Placeholder replaces hidden values in Redact mode
+const Placeholder = "[REDACTED]"
+
+// Mode determines what replaces a sensitive value
+type Mode int
+
+const (
+	// Redact replaces sensitive values with Placeholder
+	Redact Mode = iota
+	// Pseudonymize replaces sensitive values with a stable keyed hash, so equal values still correlate
+	Pseudonymize
+)
+
+// Options controls the behavior of New-created objects
+//
+// FieldPatterns are case-insensitive globs (path.Match syntax) for field names whose values are hidden entirely
+// Rules find sensitive substrings in any string value (or anywhere in non-json lines)
+// Key is the HMAC key for Pseudonymize mode (a random per-process key when empty, so pseudonyms only correlate within one run)
+type Options struct {
+	Mode          Mode
+	FieldPatterns []string
+	Rules         []Rule
+	Key           []byte
+}
+
+// Redactor hides sensitive values in log lines
+type Redactor struct {
+	mode          Mode
+	fieldPatterns []string
+	rules         []Rule
+	key           []byte
+}
+
+// New creates a Redactor from opts
+func New(opts Options) (*Redactor, error) {
+	r := &Redactor{
+		mode:          opts.Mode,
+		fieldPatterns: make([]string, 0, len(opts.FieldPatterns)),
+		rules:         opts.Rules,
+		key:           opts.Key,
+	}
+
+	for _, p := range opts.FieldPatterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, err
+		}
+
+		r.fieldPatterns = append(r.fieldPatterns, p)
+	}
+
+	if r.mode == Pseudonymize && len(r.key) == 0 {
+		r.key = make([]byte, 32)
+		if _, err := rand.Read(r.key); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// RedactLine hides the sensitive values in a single line (json or otherwise)
+//
+// A line where nothing is hidden is returned unchanged. Otherwise json lines come back in compact form.
+func (r *Redactor) RedactLine(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		if !gjson.Valid(trimmed) {
+			return r.redactText(line)
+		}
+
+		var buf bytes.Buffer
+		if r.writeValue(&buf, gjson.Parse(trimmed), false) {
+			return buf.String()
+		}
+
+		return line
+	}
+
+	return r.redactText(line)
+}
+
+func (r *Redactor) sensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range r.fieldPatterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeValue writes the (possibly redacted) json for v to buf, and reports whether anything was hidden
+func (r *Redactor) writeValue(buf *bytes.Buffer, v gjson.Result, sensitive bool) bool {
+	switch {
+	case sensitive:
+		if v.Type == gjson.String {
+			buf.WriteString(quote(r.replacement(v.String())))
+		} else {
+			buf.WriteString(quote(r.replacement(v.Raw)))
+		}
+		return true
+	case v.IsObject():
+		changed := false
+		first := true
+		buf.WriteByte('{')
+		v.ForEach(func(key, value gjson.Result) bool {
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+
+			buf.WriteString(key.Raw)
+			buf.WriteByte(':')
+			if r.writeValue(buf, value, r.sensitiveField(key.String())) {
+				changed = true
+			}
+			return true
+		})
+		buf.WriteByte('}')
+		return changed
+	case v.IsArray():
+		changed := false
+		buf.WriteByte('[')
+		for i, item := range v.Array() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if r.writeValue(buf, item, false) {
+				changed = true
+			}
+		}
+		buf.WriteByte(']')
+		return changed
+	case v.Type == gjson.String:
+		s := v.String()
+		redacted := r.redactText(s)
+		if redacted == s {
+			buf.WriteString(v.Raw)
+			return false
+		}
+		buf.WriteString(quote(redacted))
+		return true
+	default:
+		buf.WriteString(v.Raw)
+		return false
+	}
+}
+
+func (r *Redactor) redactText(s string) string {
+	for _, rule := range r.rules {
+		rule := rule
+		s = rule.Regex.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.Valid != nil && !rule.Valid(match) {
+				return match
+			}
+
+			return r.replacement(match)
+		})
+	}
+
+	return s
+}
+
+func (r *Redactor) replacement(value string) string {
+	if r.mode != Pseudonymize {
+		return Placeholder
+	}
+
+	return Pseudonym(r.key, value)
+}
+
+// Pseudonym is the stable stand-in for value under the given key
+func Pseudonym(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(value))
+
+	return "anon:" + hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// Config is the command-line friendly form of Options
+//
+// Enabled turns on Redact mode, and Pseudonymize turns on Pseudonymize mode (and wins if both are set).
+// Rules are names of built-in rules, and Regexes are additional user-supplied value rules.
+type Config struct {
+	Enabled      bool
+	Pseudonymize bool
+	Fields       []string
+	Rules        []string
+	Regexes      []string
+	Key          string
+}
+
+// Redactor builds the configured Redactor, or returns nil if redaction is not turned on
+func (c Config) Redactor() (*Redactor, error) {
+	if !c.Enabled && !c.Pseudonymize {
+		return nil, nil
+	}
+
+	rules, err := BuiltinRules(c.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, expr := range c.Regexes {
+		rule, err := RegexRule(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	opts := Options{
+		Mode:          Redact,
+		FieldPatterns: c.Fields,
+		Rules:         rules,
+		Key:           []byte(c.Key),
+	}
+
+	if c.Pseudonymize {
+		opts.Mode = Pseudonymize
+	}
+
+	return New(opts)
+}
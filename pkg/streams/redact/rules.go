@@ -0,0 +1,123 @@
+package redact
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultFieldPatterns are the field name globs whose values are always hidden
+var DefaultFieldPatterns = []string{
+	"*password*",
+	"*passwd*",
+	"*secret*",
+	"*token*",
+	"*api_key*",
+	"*apikey*",
+	"authorization",
+	"cookie",
+	"set-cookie",
+}
+
+// Rule finds sensitive values inside of text, regardless of which field they appear in
+type Rule struct {
+	Name  string
+	Regex *regexp.Regexp
+	// Valid optionally double-checks a match, to cut down on false positives
+	Valid func(match string) bool
+}
+
+var builtinRules = map[string]Rule{
+	"jwt": {
+		Name:  "jwt",
+		Regex: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	},
+	"aws": {
+		Name:  "aws",
+		Regex: regexp.MustCompile(`\b(?:AKIA|ASIA|AIDA|AROA)[0-9A-Z]{16}\b`),
+	},
+	"card": {
+		Name:  "card",
+		Regex: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Valid: luhnValid,
+	},
+	"email": {
+		Name:  "email",
+		Regex: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+	},
+	"ipv4": {
+		Name:  "ipv4",
+		Regex: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+		Valid: func(match string) bool { return net.ParseIP(match) != nil },
+	},
+	"bearer": {
+		Name:  "bearer",
+		Regex: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]+=*`),
+	},
+}
+
+// RuleNames returns the sorted names of the built-in value rules
+func RuleNames() []string {
+	names := make([]string, 0, len(builtinRules))
+	for name := range builtinRules {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinRules looks up built-in value rules by name
+func BuiltinRules(names []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(names))
+	for _, name := range names {
+		rule, ok := builtinRules[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction rule %q (known rules are %s)", name, strings.Join(RuleNames(), ", "))
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// RegexRule creates a value rule from a user-supplied regular expression
+func RegexRule(expr string) (Rule, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Rule{}, fmt.Errorf("bad redaction regex %q: %w", expr, err)
+	}
+
+	return Rule{Name: expr, Regex: re}, nil
+}
+
+// luhnValid checks the credit card checksum of the digits in s
+func luhnValid(s string) bool {
+	sum := 0
+	digits := 0
+	double := false
+
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+
+		sum += d
+		digits++
+		double = !double
+	}
+
+	return digits >= 13 && sum%10 == 0
+}
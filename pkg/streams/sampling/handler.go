@@ -0,0 +1,129 @@
+package sampling
+
+import (
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/formatter"
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+)
+
+// Options controls the behavior of NewHandler-created objects
+//
+// SampleRate is the fraction of SampleKey values to keep (sampling is disabled when this is 0 or at least 1)
+// SampleKey is the field whose value decides whether a line is sampled (the whole line when empty)
+// RateLimit is the number of lines per RateKey value shown in each RateInterval (unlimited when 0)
+// RateInterval is the length of a rate limiting window (1 second when 0)
+// RateKey is the field whose value groups lines for rate limiting (the whole line when empty)
+// Now is the clock used for rate limiting (time.Now when nil)
+type Options struct {
+	SampleRate   float64
+	SampleKey    string
+	RateLimit    int
+	RateInterval time.Duration
+	RateKey      string
+	Now          func() time.Time
+}
+
+// Handler is a linehandler.LineHandler that samples and rate limits lines before passing them along
+type Handler struct {
+	next      linehandler.LineHandler
+	sampler   *Sampler
+	sampleKey string
+	limiter   *Limiter
+	rateKey   string
+}
+
+// NewHandler wraps next in a Handler configured by opts
+func NewHandler(next linehandler.LineHandler, opts Options) *Handler {
+	h := &Handler{
+		next:      next,
+		sampleKey: opts.SampleKey,
+		rateKey:   opts.RateKey,
+	}
+
+	if opts.SampleRate > 0 && opts.SampleRate < 1 {
+		s := NewSampler(opts.SampleRate)
+		h.sampler = &s
+	}
+
+	if opts.RateLimit > 0 {
+		h.limiter = NewLimiter(opts.RateLimit, opts.RateInterval, opts.Now)
+	}
+
+	return h
+}
+
+// Key extracts the value of field from a json line to group or sample it by
+//
+// The whole line (without surrounding whitespace) is used when field is empty,
+// the line is not json, or the field is missing. gjson modifiers in field are looked up as field names.
+func Key(line, field string) string {
+	trimmed := strings.TrimSpace(line)
+	if field == "" || !strings.HasPrefix(trimmed, "{") {
+		return trimmed
+	}
+
+	res := gjson.Get(trimmed, formatter.EscapeModifiers(field))
+	if !res.Exists() {
+		return trimmed
+	}
+
+	return res.String()
+}
+
+// recordKey is Key for a record, using the json the record has (or will have) parsed
+func recordKey(rec *linehandler.Record, field string) string {
+	if field != "" {
+		if res := rec.Get(field); res.Exists() {
+			return res.String()
+		}
+	}
+
+	return strings.TrimSpace(rec.Line)
+}
+
+// HandleLine drops lines outside of the sample or over the rate limit, and passes the others along
+func (h *Handler) HandleLine(rec *linehandler.Record) error {
+	if h.sampler != nil && !h.sampler.Keep(recordKey(rec, h.sampleKey)) {
+		return nil
+	}
+
+	if h.limiter == nil {
+		return h.next.HandleLine(rec)
+	}
+
+	allowed, notices := h.limiter.Allow(recordKey(rec, h.rateKey))
+	if err := h.printNotices(rec.Path, notices); err != nil {
+		return err
+	}
+
+	if !allowed {
+		return nil
+	}
+
+	return h.next.HandleLine(rec)
+}
+
+// Flush passes along notices for any lines that are still being suppressed
+//
+// This should be called when the stream ends
+func (h *Handler) Flush(path string) error {
+	if h.limiter == nil {
+		return nil
+	}
+
+	return h.printNotices(path, h.limiter.Flush())
+}
+
+func (h *Handler) printNotices(path string, notices []Notice) error {
+	for _, n := range notices {
+		if err := h.next.HandleLine(&linehandler.Record{Path: path, Line: n.String() + "\n"}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package sampling
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gsmcwhirter/prettify/pkg/streams/linehandler"
+	"github.com/gsmcwhirter/prettify/pkg/testutil"
+)
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		line  string
+		field string
+		want  string
+	}{
+		{
+			name:  "no field",
+			line:  "{\"message\": \"foo\"}\n",
+			field: "",
+			want:  `{"message": "foo"}`,
+		},
+		{
+			name:  "field",
+			line:  "{\"message\": \"foo\"}\n",
+			field: "message",
+			want:  "foo",
+		},
+		{
+			name:  "missing field",
+			line:  `{"message": "foo"}`,
+			field: "trace_id",
+			want:  `{"message": "foo"}`,
+		},
+		{
+			name:  "field named like a modifier",
+			line:  `{"@this": "foo", "message": "bar"}`,
+			field: "@this",
+			want:  "foo",
+		},
+		{
+			name:  "not json",
+			line:  "plain text\n",
+			field: "message",
+			want:  "plain text",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := Key(tt.line, tt.field); got != tt.want {
+				t.Errorf("Key() = %v, want %v", got, tt.want)
+			}
+
+			if got := recordKey(&linehandler.Record{Line: tt.line}, tt.field); got != tt.want {
+				t.Errorf("recordKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleLine(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		opts      Options
+		lines     []string
+		wantBytes []byte
+	}{
+		{
+			name: "passthrough",
+			opts: Options{},
+			lines: []string{
+				"a\n",
+				"a\n",
+			},
+			wantBytes: []byte("a\na\n"),
+		},
+		{
+			name: "rate limited by message",
+			opts: Options{RateLimit: 1, RateKey: "message"},
+			lines: []string{
+				"{\"message\": \"a\", \"n\": 1}\n",
+				"{\"message\": \"a\", \"n\": 2}\n",
+				"{\"message\": \"b\", \"n\": 3}\n",
+				"{\"message\": \"a\", \"n\": 4}\n",
+			},
+			wantBytes: []byte("{\"message\": \"a\", \"n\": 1}\n{\"message\": \"b\", \"n\": 3}\n… 2 similar lines suppressed\n"),
+		},
+		{
+			name: "sampled out",
+			opts: Options{SampleRate: 0.000001, SampleKey: "trace_id"},
+			lines: []string{
+				"{\"trace_id\": \"abc\"}\n",
+			},
+			wantBytes: []byte{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			buffer := testutil.NewPrintfBuffer(1024)
+			lp := linehandler.NewLinePrinter(linehandler.Options{
+				Output: &buffer,
+			})
+
+			clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+			tt.opts.Now = clock.Now
+			h := NewHandler(lp, tt.opts)
+
+			for _, line := range tt.lines {
+				h.HandleLine(&linehandler.Record{Path: "test", Line: line})
+			}
+			h.Flush("test")
+
+			bufferBytes := buffer.GetData()
+			if !reflect.DeepEqual(bufferBytes, tt.wantBytes) && (len(bufferBytes) > 0 || len(tt.wantBytes) > 0) {
+				t.Errorf("HandleLine() output = %v (\n%s), want %v (\n%s)", bufferBytes, string(bufferBytes), tt.wantBytes, string(tt.wantBytes))
+			}
+		})
+	}
+}
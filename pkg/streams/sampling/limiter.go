@@ -0,0 +1,138 @@
+// Package sampling contains functionality for thinning out a stream of log lines,
+// either by rate limiting floods of similar lines or by deterministic sampling
+package sampling
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Notice describes a run of lines that were suppressed by a Limiter
+type Notice struct {
+	Key        string
+	Suppressed int
+}
+
+// String renders the notice in the form it is shown to users
+func (n Notice) String() string {
+	if n.Suppressed == 1 {
+		return "… 1 similar line suppressed"
+	}
+
+	return fmt.Sprintf("… %d similar lines suppressed", n.Suppressed)
+}
+
+type window struct {
+	start      time.Time
+	allowed    int
+	suppressed int
+}
+
+// Limiter allows up to a fixed number of lines per key in each interval, and counts the rest
+//
+// Note: this keeps one small window per distinct key seen during the current interval.
+// Windows are discarded once their interval has passed
+type Limiter struct {
+	limit     int
+	interval  time.Duration
+	now       func() time.Time
+	windows   map[string]*window
+	lastSweep time.Time
+}
+
+// NewLimiter creates a new Limiter allowing limit lines per key per interval
+//
+// If interval is not positive, one second is used. If now is nil, time.Now is used
+func NewLimiter(limit int, interval time.Duration, now func() time.Time) *Limiter {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	if now == nil {
+		now = time.Now
+	}
+
+	return &Limiter{
+		limit:    limit,
+		interval: interval,
+		now:      now,
+		windows:  map[string]*window{},
+	}
+}
+
+// Allow reports whether a line with the given key should be shown
+//
+// Any notices for windows (of any key) that have expired since the last sweep are returned as well,
+// and should be shown before the line itself
+func (l *Limiter) Allow(key string) (bool, []Notice) {
+	now := l.now()
+	notices := l.sweep(now)
+
+	w, ok := l.windows[key]
+	if ok && now.Sub(w.start) >= l.interval {
+		if w.suppressed > 0 {
+			notices = append(notices, Notice{Key: key, Suppressed: w.suppressed})
+		}
+		ok = false
+	}
+
+	if !ok {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+
+	if w.allowed < l.limit {
+		w.allowed++
+		return true, notices
+	}
+
+	w.suppressed++
+	return false, notices
+}
+
+// Flush returns notices for all windows that have suppressed lines, and forgets all windows
+func (l *Limiter) Flush() []Notice {
+	notices := make([]Notice, 0, len(l.windows))
+	for key, w := range l.windows {
+		if w.suppressed > 0 {
+			notices = append(notices, Notice{Key: key, Suppressed: w.suppressed})
+		}
+	}
+
+	l.windows = map[string]*window{}
+	sortNotices(notices)
+
+	return notices
+}
+
+func (l *Limiter) sweep(now time.Time) []Notice {
+	if now.Sub(l.lastSweep) < l.interval {
+		return nil
+	}
+	l.lastSweep = now
+
+	var notices []Notice
+	for key, w := range l.windows {
+		if now.Sub(w.start) < l.interval {
+			continue
+		}
+
+		if w.suppressed > 0 {
+			notices = append(notices, Notice{Key: key, Suppressed: w.suppressed})
+		}
+
+		delete(l.windows, key)
+	}
+
+	sortNotices(notices)
+
+	return notices
+}
+
+// sortNotices keeps the output deterministic, since map iteration is not
+func sortNotices(notices []Notice) {
+	sort.Slice(notices, func(i, j int) bool {
+		return notices[i].Key < notices[j].Key
+	})
+}
@@ -0,0 +1,146 @@
+package sampling
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.now = fc.now.Add(d)
+}
+
+func TestNotice_String(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		notice Notice
+		want   string
+	}{
+		{
+			name:   "one",
+			notice: Notice{Key: "foo", Suppressed: 1},
+			want:   "… 1 similar line suppressed",
+		},
+		{
+			name:   "many",
+			notice: Notice{Key: "foo", Suppressed: 9876},
+			want:   "… 9876 similar lines suppressed",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.notice.String(); got != tt.want {
+				t.Errorf("Notice.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	type step struct {
+		advance     time.Duration
+		key         string
+		wantAllowed bool
+		wantNotices []Notice
+	}
+	tests := []struct {
+		name  string
+		limit int
+		steps []step
+	}{
+		{
+			name:  "under the limit",
+			limit: 2,
+			steps: []step{
+				{key: "a", wantAllowed: true},
+				{key: "a", wantAllowed: true},
+				{key: "b", wantAllowed: true},
+			},
+		},
+		{
+			name:  "over the limit",
+			limit: 1,
+			steps: []step{
+				{key: "a", wantAllowed: true},
+				{key: "a", wantAllowed: false},
+				{key: "a", wantAllowed: false},
+				{key: "b", wantAllowed: true},
+			},
+		},
+		{
+			name:  "new window reports suppressed lines",
+			limit: 1,
+			steps: []step{
+				{key: "a", wantAllowed: true},
+				{key: "a", wantAllowed: false},
+				{key: "a", wantAllowed: false},
+				{advance: time.Second, key: "a", wantAllowed: true, wantNotices: []Notice{{Key: "a", Suppressed: 2}}},
+				{key: "a", wantAllowed: false},
+			},
+		},
+		{
+			name:  "other keys report expired windows",
+			limit: 1,
+			steps: []step{
+				{key: "a", wantAllowed: true},
+				{key: "a", wantAllowed: false},
+				{key: "b", wantAllowed: true},
+				{key: "b", wantAllowed: false},
+				{advance: 2 * time.Second, key: "c", wantAllowed: true, wantNotices: []Notice{{Key: "a", Suppressed: 1}, {Key: "b", Suppressed: 1}}},
+				{key: "a", wantAllowed: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+			l := NewLimiter(tt.limit, time.Second, clock.Now)
+
+			for i, s := range tt.steps {
+				clock.Advance(s.advance)
+				gotAllowed, gotNotices := l.Allow(s.key)
+				if gotAllowed != s.wantAllowed {
+					t.Errorf("step %d: Limiter.Allow() allowed = %v, want %v", i, gotAllowed, s.wantAllowed)
+				}
+				if len(gotNotices) != 0 || len(s.wantNotices) != 0 {
+					if !reflect.DeepEqual(gotNotices, s.wantNotices) {
+						t.Errorf("step %d: Limiter.Allow() notices = %v, want %v", i, gotNotices, s.wantNotices)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLimiter_Flush(t *testing.T) {
+	t.Parallel()
+	l := NewLimiter(1, time.Minute, nil)
+
+	for _, key := range []string{"b", "a", "b", "a", "a", "c"} {
+		l.Allow(key)
+	}
+
+	want := []Notice{{Key: "a", Suppressed: 2}, {Key: "b", Suppressed: 1}}
+	if got := l.Flush(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Limiter.Flush() = %v, want %v", got, want)
+	}
+
+	if got := l.Flush(); len(got) != 0 {
+		t.Errorf("Limiter.Flush() second call = %v, want nothing", got)
+	}
+}
@@ -0,0 +1,82 @@
+package sampling
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrBadRate means that a sample rate could not be parsed or was out of range
+var ErrBadRate = errors.New("sample rate must be a percentage like 1% or a fraction, more than 0 and at most 1")
+
+// Sampler deterministically keeps a fraction of keys
+//
+// The decision is made from a hash of the key, so every line sharing a key
+// (a trace_id, for example) is either kept or dropped together
+type Sampler struct {
+	threshold uint64
+	keepAll   bool
+}
+
+// NewSampler creates a Sampler keeping approximately the given fraction (0 to 1) of keys
+func NewSampler(rate float64) Sampler {
+	if rate >= 1 {
+		return Sampler{keepAll: true}
+	}
+
+	if rate <= 0 {
+		return Sampler{}
+	}
+
+	return Sampler{threshold: uint64(rate * math.MaxUint64)}
+}
+
+// ParseRate parses a sample rate given as a percentage ("1%", "12.5%") or a fraction ("0.01")
+//
+// A rate of 0 is an error rather than sampling nothing, since that would show no lines at all.
+func ParseRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+
+	scale := 1.0
+	if strings.HasSuffix(s, "%") {
+		s = strings.TrimSuffix(s, "%")
+		scale = 100.0
+	}
+
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, ErrBadRate
+	}
+
+	rate /= scale
+	if rate <= 0 || rate > 1 || math.IsNaN(rate) {
+		return 0, ErrBadRate
+	}
+
+	return rate, nil
+}
+
+// Keep reports whether lines with the given key are part of the sample
+func (s Sampler) Keep(key string) bool {
+	if s.keepAll {
+		return true
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	return mix64(h.Sum64()) < s.threshold
+}
+
+// mix64 is the murmur3 finalizer; fnv alone spreads similar keys (trace-1, trace-2, ...) poorly in the high bits
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
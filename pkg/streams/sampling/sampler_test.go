@@ -0,0 +1,129 @@
+package sampling
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseRate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		rate    string
+		want    float64
+		wantErr bool
+	}{
+		{
+			name: "percent",
+			rate: "1%",
+			want: 0.01,
+		},
+		{
+			name: "fractional percent",
+			rate: " 12.5% ",
+			want: 0.125,
+		},
+		{
+			name: "fraction",
+			rate: "0.25",
+			want: 0.25,
+		},
+		{
+			name:    "too big",
+			rate:    "150%",
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			rate:    "-0.1",
+			wantErr: true,
+		},
+		{
+			name:    "zero",
+			rate:    "0%",
+			wantErr: true,
+		},
+		{
+			name: "all",
+			rate: "100%",
+			want: 1,
+		},
+		{
+			name:    "garbage",
+			rate:    "lots",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseRate(tt.rate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampler_Keep(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		rate    float64
+		wantMin int
+		wantMax int
+	}{
+		{
+			name:    "none",
+			rate:    0,
+			wantMin: 0,
+			wantMax: 0,
+		},
+		{
+			name:    "all",
+			rate:    1,
+			wantMin: 10000,
+			wantMax: 10000,
+		},
+		{
+			name:    "ten percent",
+			rate:    0.1,
+			wantMin: 800,
+			wantMax: 1200,
+		},
+		{
+			name:    "half",
+			rate:    0.5,
+			wantMin: 4700,
+			wantMax: 5300,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewSampler(tt.rate)
+
+			kept := 0
+			for i := 0; i < 10000; i++ {
+				key := fmt.Sprintf("trace-%d", i)
+				keep := s.Keep(key)
+				if keep != s.Keep(key) {
+					t.Fatalf("Sampler.Keep(%q) is not deterministic", key)
+				}
+				if keep {
+					kept++
+				}
+			}
+
+			if kept < tt.wantMin || kept > tt.wantMax {
+				t.Errorf("Sampler.Keep() kept %d of 10000, want between %d and %d", kept, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
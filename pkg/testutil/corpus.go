@@ -0,0 +1,74 @@
+package testutil
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Corpus is a named set of log lines for benchmarks
+type Corpus struct {
+	Name  string
+	Lines [][]byte
+}
+
+// LogCorpora returns deterministic sets of n realistic log lines each: structured service logs,
+// http access logs, error logs with stack traces, and plain text
+func LogCorpora(n int) []Corpus {
+	rng := rand.New(rand.NewSource(1)) //nolint:gosec // deterministic test data
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	levels := []string{"debug", "info", "info", "info", "warn", "error"}
+	messages := []string{"request handled", "cache miss", "retrying upstream call", "user logged in", "job finished", "slow query"}
+	paths := []string{"/api/v1/users", "/api/v1/orders/123", "/healthz", "/static/app.js", "/api/v1/search?q=shoes"}
+
+	service := make([][]byte, n)
+	access := make([][]byte, n)
+	errs := make([][]byte, n)
+	plain := make([][]byte, n)
+
+	for i := 0; i < n; i++ {
+		ts := start.Add(time.Duration(i) * 1500 * time.Microsecond).Format(time.RFC3339Nano)
+		level := levels[rng.Intn(len(levels))]
+		msg := messages[rng.Intn(len(messages))]
+		path := paths[rng.Intn(len(paths))]
+		traceID := fmt.Sprintf("%016x", rng.Uint64())
+
+		service[i] = []byte(fmt.Sprintf(`{"timestamp":"%s","level":"%s","message":"%s","caller":"server/handler.go:%d","service":"checkout","trace_id":"%s","user_id":%d,"duration_ms":%.3f,"attempt":%d}`,
+			ts, level, msg, 100+rng.Intn(400), traceID, rng.Intn(100000), rng.Float64()*250, rng.Intn(3)))
+
+		access[i] = []byte(fmt.Sprintf(`{"timestamp":"%s","level":"info","message":"http","method":"GET","path":"%s","status":%d,"bytes":%d,"remote_addr":"10.0.%d.%d","user_agent":"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36","headers":{"accept":"application/json","x-request-id":"%s"}}`,
+			ts, path, []int{200, 200, 200, 201, 304, 404, 500}[rng.Intn(7)], rng.Intn(50000), rng.Intn(256), rng.Intn(256), traceID))
+
+		frames := make([]string, 4+rng.Intn(8))
+		for j := range frames {
+			frames[j] = fmt.Sprintf(`"github.com/example/checkout/pkg/service.(*Handler).step%d\n\t/src/pkg/service/handler.go:%d"`, j, 10+rng.Intn(500))
+		}
+		errs[i] = []byte(fmt.Sprintf(`{"timestamp":"%s","level":"error","message":"payment failed: upstream returned \"503 Service Unavailable\"","error":"context deadline exceeded","trace_id":"%s","stack":[%s]}`,
+			ts, traceID, strings.Join(frames, ",")))
+
+		plain[i] = []byte(fmt.Sprintf("%s %s [checkout] %s trace=%s", ts, strings.ToUpper(level), msg, traceID))
+	}
+
+	return []Corpus{
+		{Name: "service", Lines: service},
+		{Name: "access", Lines: access},
+		{Name: "errors", Lines: errs},
+		{Name: "plain", Lines: plain},
+	}
+}
+
+// AverageLineSize is the mean length of the lines in c, for b.SetBytes when each op handles one line
+func (c Corpus) AverageLineSize() int64 {
+	if len(c.Lines) == 0 {
+		return 0
+	}
+
+	var total int64
+	for _, l := range c.Lines {
+		total += int64(len(l))
+	}
+
+	return total / int64(len(c.Lines))
+}
@@ -2,7 +2,7 @@ package testutil
 
 import "fmt"
 
-// PrintfBuffer can stand in for fmt.Printf when testing
+// PrintfBuffer can stand in for fmt.Printf (or, through Write, an io.Writer) when testing
 type PrintfBuffer struct {
 	writtenData  []byte
 	bytesWritten int
@@ -41,6 +41,12 @@ func (pb *PrintfBuffer) Printf(format string, args ...interface{}) (int, error)
 	return len(newBytes), nil
 }
 
+// Write writes the data into the buffer
+func (pb *PrintfBuffer) Write(data []byte) (int, error) {
+	pb.writeBytes(data)
+	return len(data), nil
+}
+
 // GetData returns the data written so far
 func (pb *PrintfBuffer) GetData() []byte {
 	return pb.writtenData[:pb.bytesWritten]
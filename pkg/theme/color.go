@@ -0,0 +1,282 @@
+// Package theme contains the color palettes used to render log lines,
+// along with the logic deciding whether (and how richly) to use color at all
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+
+	"github.com/gsmcwhirter/prettify/pkg/minmax"
+)
+
+// Depth is the number of colors a terminal can display
+type Depth int
+
+// The supported color depths
+const (
+	Depth16 Depth = iota
+	Depth256
+	DepthTrueColor
+)
+
+// ErrBadDepth means that a color depth could not be parsed
+var ErrBadDepth = errors.New("color depth must be one of auto, 16, 256, truecolor")
+
+// ParseDepth parses a color depth name; "auto" (or empty) detects it from the environment
+func ParseDepth(s string) (Depth, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return DetectDepth(), nil
+	case "16", "basic":
+		return Depth16, nil
+	case "256":
+		return Depth256, nil
+	case "truecolor", "24bit", "16m":
+		return DepthTrueColor, nil
+	default:
+		return Depth16, ErrBadDepth
+	}
+}
+
+// DetectDepth guesses the color depth of the terminal from FORCE_COLOR, COLORTERM and TERM
+func DetectDepth() Depth {
+	switch os.Getenv("FORCE_COLOR") {
+	case "2":
+		return Depth256
+	case "3":
+		return DepthTrueColor
+	}
+
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return DepthTrueColor
+	}
+
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return Depth256
+	}
+
+	return Depth16
+}
+
+// Enabled decides whether color should be used
+//
+// An explicit request (force, usually a --color flag) always wins. Otherwise a FORCE_COLOR
+// environment variable (other than "", "0" or "false") turns color on, a non-empty NO_COLOR
+// turns it off, and failing both, color is used only when isTerminal is true.
+func Enabled(force, isTerminal bool) bool {
+	if force {
+		return true
+	}
+
+	if v := os.Getenv("FORCE_COLOR"); v != "" && v != "0" && v != "false" {
+		return true
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	return isTerminal
+}
+
+type colorKind int
+
+const (
+	noColor colorKind = iota
+	basicColor
+	indexedColor
+	rgbColor
+)
+
+// Color is a foreground or background color, at whatever depth it was specified
+type Color struct {
+	kind    colorKind
+	index   int
+	r, g, b uint8
+}
+
+var basicNames = map[string]int{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+}
+
+// basicRGB approximates the xterm defaults for the 16 basic colors
+var basicRGB = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// ParseColor parses a color name (red, hi-red, bright-red, gray), a 256-color index (0-255) or a #rrggbb hex value
+func ParseColor(s string) (Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	switch {
+	case s == "gray" || s == "grey":
+		return Color{kind: basicColor, index: 8}, nil
+	case strings.HasPrefix(s, "#"):
+		return parseHex(s)
+	case s != "" && s[0] >= '0' && s[0] <= '9':
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > 255 {
+			return Color{}, fmt.Errorf("bad color index %q", s)
+		}
+		return Color{kind: indexedColor, index: n}, nil
+	}
+
+	bright := false
+	for _, prefix := range []string{"hi-", "bright-", "hi", "bright"} {
+		if strings.HasPrefix(s, prefix) {
+			s = strings.TrimPrefix(s, prefix)
+			bright = true
+			break
+		}
+	}
+
+	idx, ok := basicNames[s]
+	if !ok {
+		return Color{}, fmt.Errorf("unknown color %q", s)
+	}
+
+	if bright {
+		idx += 8
+	}
+
+	return Color{kind: basicColor, index: idx}, nil
+}
+
+func parseHex(s string) (Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return Color{}, fmt.Errorf("bad hex color %q", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("bad hex color %q", s)
+	}
+
+	return Color{kind: rgbColor, r: uint8(v >> 16), g: uint8(v >> 8), b: uint8(v)}, nil
+}
+
+// params returns the SGR parameters selecting this color, downgraded to fit the depth
+func (c Color) params(depth Depth, background bool) []int {
+	base := 30
+	if background {
+		base = 40
+	}
+
+	switch c.kind {
+	case basicColor:
+		return basicParams(c.index, base)
+	case indexedColor:
+		if depth >= Depth256 {
+			return []int{base + 8, 5, c.index}
+		}
+		r, g, b := indexToRGB(c.index)
+		return basicParams(nearestBasic(r, g, b), base)
+	case rgbColor:
+		switch depth {
+		case DepthTrueColor:
+			return []int{base + 8, 2, int(c.r), int(c.g), int(c.b)}
+		case Depth256:
+			return []int{base + 8, 5, rgbToIndex(c.r, c.g, c.b)}
+		default:
+			return basicParams(nearestBasic(c.r, c.g, c.b), base)
+		}
+	default:
+		return nil
+	}
+}
+
+func basicParams(idx, base int) []int {
+	if idx >= 8 {
+		return []int{base + 60 + idx - 8}
+	}
+
+	return []int{base + idx}
+}
+
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+func indexToRGB(idx int) (r, g, b uint8) {
+	switch {
+	case idx < 16:
+		c := basicRGB[idx]
+		return c[0], c[1], c[2]
+	case idx < 232:
+		idx -= 16
+		return cubeLevels[idx/36], cubeLevels[(idx/6)%6], cubeLevels[idx%6]
+	default:
+		v := uint8(8 + 10*(idx-232))
+		return v, v, v
+	}
+}
+
+func cubeIndex(v uint8) int {
+	if v < 48 {
+		return 0
+	}
+
+	if v < 115 {
+		return 1
+	}
+
+	return int(v-35) / 40
+}
+
+func rgbToIndex(r, g, b uint8) int {
+	cr, cg, cb := cubeIndex(r), cubeIndex(g), cubeIndex(b)
+	cubeIdx := 16 + 36*cr + 6*cg + cb
+
+	// grays are usually better served by the grayscale ramp
+	avg := (int(r) + int(g) + int(b)) / 3
+	grayIdx := 232 + minmax.IntMin((minmax.IntMax(avg-8, 0)+5)/10, 23)
+
+	gr, gg, gb := indexToRGB(grayIdx)
+	qr, qg, qb := indexToRGB(cubeIdx)
+	if distance(r, g, b, gr, gg, gb) < distance(r, g, b, qr, qg, qb) {
+		return grayIdx
+	}
+
+	return cubeIdx
+}
+
+func nearestBasic(r, g, b uint8) int {
+	best, bestDist := 0, -1
+	for i, c := range basicRGB {
+		d := distance(r, g, b, c[0], c[1], c[2])
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+
+	return best
+}
+
+func distance(r1, g1, b1, r2, g2, b2 uint8) int {
+	dr, dg, db := int(r1)-int(r2), int(g1)-int(g2), int(b1)-int(b2)
+	return dr*dr + dg*dg + db*db
+}
+
+// IsTerminal reports whether f is an interactive terminal that understands color
+func IsTerminal(f *os.File) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
@@ -0,0 +1,257 @@
+package theme
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		spec    string
+		want    Color
+		wantErr bool
+	}{
+		{
+			name: "basic",
+			spec: "red",
+			want: Color{kind: basicColor, index: 1},
+		},
+		{
+			name: "bright",
+			spec: "hi-blue",
+			want: Color{kind: basicColor, index: 12},
+		},
+		{
+			name: "bright alias",
+			spec: "Bright-Black",
+			want: Color{kind: basicColor, index: 8},
+		},
+		{
+			name: "gray",
+			spec: "gray",
+			want: Color{kind: basicColor, index: 8},
+		},
+		{
+			name: "indexed",
+			spec: "208",
+			want: Color{kind: indexedColor, index: 208},
+		},
+		{
+			name: "hex",
+			spec: "#D55E00",
+			want: Color{kind: rgbColor, r: 0xd5, g: 0x5e, b: 0x00},
+		},
+		{
+			name:    "index out of range",
+			spec:    "256",
+			wantErr: true,
+		},
+		{
+			name:    "short hex",
+			spec:    "#fff",
+			wantErr: true,
+		},
+		{
+			name:    "unknown name",
+			spec:    "chartreuse",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseColor(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseColor() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseColor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColor_params(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		color      Color
+		depth      Depth
+		background bool
+		want       []int
+	}{
+		{
+			name:  "basic",
+			color: Color{kind: basicColor, index: 1},
+			depth: DepthTrueColor,
+			want:  []int{31},
+		},
+		{
+			name:       "basic bright background",
+			color:      Color{kind: basicColor, index: 9},
+			depth:      Depth16,
+			background: true,
+			want:       []int{101},
+		},
+		{
+			name:  "indexed at 256",
+			color: Color{kind: indexedColor, index: 208},
+			depth: Depth256,
+			want:  []int{38, 5, 208},
+		},
+		{
+			name:  "indexed downgraded",
+			color: Color{kind: indexedColor, index: 196},
+			depth: Depth16,
+			want:  []int{91},
+		},
+		{
+			name:  "rgb at truecolor",
+			color: Color{kind: rgbColor, r: 1, g: 2, b: 3},
+			depth: DepthTrueColor,
+			want:  []int{38, 2, 1, 2, 3},
+		},
+		{
+			name:  "rgb downgraded to 256",
+			color: Color{kind: rgbColor, r: 255, g: 135, b: 0},
+			depth: Depth256,
+			want:  []int{38, 5, 208},
+		},
+		{
+			name:  "rgb gray downgraded to 256",
+			color: Color{kind: rgbColor, r: 128, g: 128, b: 128},
+			depth: Depth256,
+			want:  []int{38, 5, 244},
+		},
+		{
+			name:  "rgb downgraded to 16",
+			color: Color{kind: rgbColor, r: 250, g: 10, b: 10},
+			depth: Depth16,
+			want:  []int{91},
+		},
+		{
+			name:  "no color",
+			color: Color{},
+			depth: DepthTrueColor,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.color.params(tt.depth, tt.background); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Color.params() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		name       string
+		force      bool
+		isTerminal bool
+		env        map[string]string
+		want       bool
+	}{
+		{
+			name:       "terminal",
+			isTerminal: true,
+			want:       true,
+		},
+		{
+			name:       "pipe",
+			isTerminal: false,
+			want:       false,
+		},
+		{
+			name:       "NO_COLOR on a terminal",
+			isTerminal: true,
+			env:        map[string]string{"NO_COLOR": "1"},
+			want:       false,
+		},
+		{
+			name:       "forced despite NO_COLOR",
+			force:      true,
+			isTerminal: false,
+			env:        map[string]string{"NO_COLOR": "1"},
+			want:       true,
+		},
+		{
+			name:       "FORCE_COLOR in a pipe",
+			isTerminal: false,
+			env:        map[string]string{"FORCE_COLOR": "1"},
+			want:       true,
+		},
+		{
+			name:       "FORCE_COLOR=0",
+			isTerminal: true,
+			env:        map[string]string{"FORCE_COLOR": "0", "NO_COLOR": "1"},
+			want:       false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", "")
+			t.Setenv("FORCE_COLOR", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			if got := Enabled(tt.force, tt.isTerminal); got != tt.want {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		spec    string
+		want    Depth
+		wantErr bool
+	}{
+		{
+			name: "16",
+			spec: "16",
+			want: Depth16,
+		},
+		{
+			name: "256",
+			spec: "256",
+			want: Depth256,
+		},
+		{
+			name: "truecolor",
+			spec: "TrueColor",
+			want: DepthTrueColor,
+		},
+		{
+			name:    "bad",
+			spec:    "lots",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseDepth(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDepth() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseDepth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package theme
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var attributeNames = map[string]int{
+	"bold":      1,
+	"dim":       2,
+	"italic":    3,
+	"underline": 4,
+	"blink":     5,
+	"reverse":   7,
+}
+
+// Style is a foreground color, an optional background color and text attributes
+type Style struct {
+	fg    Color
+	bg    Color
+	attrs []int
+}
+
+// ParseStyle parses a space-separated style specification, like "bold red", "#d55e00",
+// "208 on black" or "underline hi-cyan"; the empty string is the plain (uncolored) style
+func ParseStyle(spec string) (Style, error) {
+	var s Style
+	background := false
+
+	for _, word := range strings.Fields(strings.ToLower(spec)) {
+		if attr, ok := attributeNames[word]; ok {
+			s.attrs = append(s.attrs, attr)
+			continue
+		}
+
+		if word == "on" {
+			background = true
+			continue
+		}
+
+		c, err := ParseColor(word)
+		if err != nil {
+			return Style{}, fmt.Errorf("bad style %q: %w", spec, err)
+		}
+
+		if background {
+			s.bg = c
+			background = false
+		} else {
+			s.fg = c
+		}
+	}
+
+	if background {
+		return Style{}, fmt.Errorf("bad style %q: missing background color after 'on'", spec)
+	}
+
+	return s, nil
+}
+
+// IsPlain is true for the style that does not change the text at all
+func (s Style) IsPlain() bool {
+	return s.fg.kind == noColor && s.bg.kind == noColor && len(s.attrs) == 0
+}
+
+func (s Style) params(depth Depth) []int {
+	params := make([]int, 0, len(s.attrs)+10)
+	params = append(params, s.attrs...)
+	params = append(params, s.fg.params(depth, false)...)
+	params = append(params, s.bg.params(depth, true)...)
+
+	return params
+}
+
+// sequences returns the escape sequences that start and end the style
+func (s Style) sequences(depth Depth) [2]string {
+	if s.IsPlain() {
+		return [2]string{"", ""}
+	}
+
+	params := s.params(depth)
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = strconv.Itoa(p)
+	}
+
+	return [2]string{"\x1b[" + strings.Join(parts, ";") + "m", "\x1b[0m"}
+}
+
+// colorAttrs converts the style into the form fatih/color wants
+func (s Style) colorAttrs(depth Depth) []color.Attribute {
+	params := s.params(depth)
+	attrs := make([]color.Attribute, len(params))
+	for i, p := range params {
+		attrs[i] = color.Attribute(p)
+	}
+
+	return attrs
+}
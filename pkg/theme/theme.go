@@ -0,0 +1,385 @@
+package theme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/pretty"
+)
+
+// DefaultName is the theme used when none is requested
+const DefaultName = "dark"
+
+// maxExtends bounds how long a chain of themes extending each other may be (which also catches cycles)
+const maxExtends = 8
+
+// ErrUnknownTheme means that a theme was neither built in nor defined in the config file
+var ErrUnknownTheme = errors.New("unknown theme")
+
+// JSONStyle holds the styles used when colorizing json
+type JSONStyle struct {
+	Key    Style
+	String Style
+	Number Style
+	True   Style
+	False  Style
+	Null   Style
+	Escape Style
+}
+
+// Theme is a complete palette for rendering log lines at a particular color depth
+type Theme struct {
+	Name      string
+	Timestamp Style
+	Key       Style
+	Notice    Style
+	Levels    map[string]Style
+	JSON      JSONStyle
+	depth     Depth
+}
+
+// JSONSpec is the user-editable form of a JSONStyle
+type JSONSpec struct {
+	Key    string `json:"key,omitempty"`
+	String string `json:"string,omitempty"`
+	Number string `json:"number,omitempty"`
+	True   string `json:"true,omitempty"`
+	False  string `json:"false,omitempty"`
+	Null   string `json:"null,omitempty"`
+	Escape string `json:"escape,omitempty"`
+}
+
+// Spec is the user-editable form of a Theme, as found in the config file
+//
+// Every style is a ParseStyle specification. Styles that are left empty are taken from the
+// Extends theme (the default theme when Extends is empty). Levels are keyed by the first
+// four letters of the upper-cased level name (DEBU, INFO, WARN, ERRO, ...; NONE for lines without one)
+type Spec struct {
+	Extends   string            `json:"extends,omitempty"`
+	Timestamp string            `json:"timestamp,omitempty"`
+	Key       string            `json:"key,omitempty"`
+	Notice    string            `json:"notice,omitempty"`
+	Levels    map[string]string `json:"levels,omitempty"`
+	JSON      JSONSpec          `json:"json,omitempty"`
+}
+
+var builtins = map[string]Spec{
+	// matches the historical hard-coded colors, except that NONE is no longer black-on-black
+	"dark": {
+		Timestamp: "hi-black",
+		Key:       "cyan",
+		Notice:    "hi-black",
+		Levels: map[string]string{
+			"NONE": "white",
+			"TRAC": "hi-black",
+			"DEBU": "magenta",
+			"INFO": "green",
+			"WARN": "yellow",
+			"ERRO": "red",
+			"FATA": "bold red",
+			"PANI": "bold red",
+		},
+		JSON: JSONSpec{
+			Key:    "hi-blue",
+			String: "hi-green",
+			Number: "hi-yellow",
+			True:   "hi-cyan",
+			False:  "hi-cyan",
+			Null:   "hi-red",
+			Escape: "magenta",
+		},
+	},
+	// avoids the yellows and whites that vanish on a light background
+	"light": {
+		Timestamp: "244",
+		Key:       "blue",
+		Notice:    "244",
+		Levels: map[string]string{
+			"NONE": "black",
+			"TRAC": "244",
+			"DEBU": "magenta",
+			"INFO": "green",
+			"WARN": "#af5f00",
+			"ERRO": "red",
+			"FATA": "bold red",
+			"PANI": "bold red",
+		},
+		JSON: JSONSpec{
+			Key:    "blue",
+			String: "green",
+			Number: "#af5f00",
+			True:   "cyan",
+			False:  "cyan",
+			Null:   "red",
+			Escape: "magenta",
+		},
+	},
+	// uses the Okabe-Ito palette, which stays distinguishable with the common color vision deficiencies
+	"colorblind": {
+		Timestamp: "gray",
+		Key:       "#56b4e9",
+		Notice:    "gray",
+		Levels: map[string]string{
+			"NONE": "white",
+			"TRAC": "gray",
+			"DEBU": "#cc79a7",
+			"INFO": "#009e73",
+			"WARN": "#e69f00",
+			"ERRO": "bold #d55e00",
+			"FATA": "bold underline #d55e00",
+			"PANI": "bold underline #d55e00",
+		},
+		JSON: JSONSpec{
+			Key:    "#56b4e9",
+			String: "#009e73",
+			Number: "#e69f00",
+			True:   "#0072b2",
+			False:  "#0072b2",
+			Null:   "#d55e00",
+			Escape: "#cc79a7",
+		},
+	},
+}
+
+// Names returns the sorted names of the built-in themes
+func Names() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// NameFromEnv returns the theme named by $PRETTIFY_THEME, or DefaultName when it is not set
+func NameFromEnv() string {
+	if name := os.Getenv("PRETTIFY_THEME"); name != "" {
+		return name
+	}
+
+	return DefaultName
+}
+
+// DefaultConfigPath is where user-defined themes are looked for
+//
+// This is $PRETTIFY_THEMES if it is set, or prettify/themes.json in the user config directory otherwise
+func DefaultConfigPath() string {
+	if p := os.Getenv("PRETTIFY_THEMES"); p != "" {
+		return p
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "prettify", "themes.json")
+}
+
+// LoadSpecs reads user-defined theme specs (a json object of name to Spec) from a config file
+//
+// A missing file is not an error, and yields no specs
+func LoadSpecs(configPath string) (map[string]Spec, error) {
+	specs := map[string]Spec{}
+	if configPath == "" {
+		return specs, nil
+	}
+
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return specs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("could not parse themes in %s: %w", configPath, err)
+	}
+
+	return specs, nil
+}
+
+// Load finds the named theme, among the user-defined themes in configPath first and the built-in ones second,
+// and prepares it for the given color depth
+func Load(name, configPath string, depth Depth) (*Theme, error) {
+	specs, err := LoadSpecs(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return Resolve(name, specs, depth)
+}
+
+// Resolve builds the named theme from the given user-defined specs and the built-in ones
+func Resolve(name string, specs map[string]Spec, depth Depth) (*Theme, error) {
+	if name == "" {
+		name = DefaultName
+	}
+
+	chain := make([]Spec, 0, 2)
+	for cur := name; ; {
+		if len(chain) >= maxExtends {
+			return nil, fmt.Errorf("theme %q extends too many other themes (is there a cycle?)", name)
+		}
+
+		userSpec, isUser := specs[cur]
+		builtinSpec, isBuiltin := builtins[cur]
+		if !isUser && !isBuiltin {
+			return nil, fmt.Errorf("%w: %q (built-in themes are %s)", ErrUnknownTheme, cur, strings.Join(Names(), ", "))
+		}
+
+		if isUser {
+			chain = append(chain, userSpec)
+			if userSpec.Extends != "" {
+				cur = userSpec.Extends
+				continue
+			}
+		}
+
+		// a user theme may shadow a built-in one, in which case it extends the original
+		if isBuiltin {
+			chain = append(chain, builtinSpec)
+			break
+		}
+
+		cur = DefaultName
+	}
+
+	merged := Spec{Levels: map[string]string{}}
+	for i := len(chain) - 1; i >= 0; i-- {
+		merged = merge(merged, chain[i])
+	}
+
+	t, err := compile(merged)
+	if err != nil {
+		return nil, fmt.Errorf("theme %q: %w", name, err)
+	}
+
+	t.Name = name
+	t.depth = depth
+
+	return t, nil
+}
+
+func pick(override, base string) string {
+	if override != "" {
+		return override
+	}
+
+	return base
+}
+
+func merge(base, override Spec) Spec {
+	out := Spec{
+		Timestamp: pick(override.Timestamp, base.Timestamp),
+		Key:       pick(override.Key, base.Key),
+		Notice:    pick(override.Notice, base.Notice),
+		Levels:    map[string]string{},
+		JSON: JSONSpec{
+			Key:    pick(override.JSON.Key, base.JSON.Key),
+			String: pick(override.JSON.String, base.JSON.String),
+			Number: pick(override.JSON.Number, base.JSON.Number),
+			True:   pick(override.JSON.True, base.JSON.True),
+			False:  pick(override.JSON.False, base.JSON.False),
+			Null:   pick(override.JSON.Null, base.JSON.Null),
+			Escape: pick(override.JSON.Escape, base.JSON.Escape),
+		},
+	}
+
+	for k, v := range base.Levels {
+		out.Levels[k] = v
+	}
+
+	for k, v := range override.Levels {
+		out.Levels[strings.ToUpper(k)] = v
+	}
+
+	return out
+}
+
+func compile(spec Spec) (*Theme, error) {
+	var err error
+	t := &Theme{Levels: map[string]Style{}}
+
+	parse := func(dst *Style, s string) {
+		if err != nil {
+			return
+		}
+		*dst, err = ParseStyle(s)
+	}
+
+	parse(&t.Timestamp, spec.Timestamp)
+	parse(&t.Key, spec.Key)
+	parse(&t.Notice, spec.Notice)
+	parse(&t.JSON.Key, spec.JSON.Key)
+	parse(&t.JSON.String, spec.JSON.String)
+	parse(&t.JSON.Number, spec.JSON.Number)
+	parse(&t.JSON.True, spec.JSON.True)
+	parse(&t.JSON.False, spec.JSON.False)
+	parse(&t.JSON.Null, spec.JSON.Null)
+	parse(&t.JSON.Escape, spec.JSON.Escape)
+
+	for level, s := range spec.Levels {
+		var st Style
+		parse(&st, s)
+		t.Levels[level] = st
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Depth returns the color depth the theme renders for
+func (t *Theme) Depth() Depth {
+	return t.depth
+}
+
+// Level returns the style for a level, as identified by the first four letters of its upper-cased name
+func (t *Theme) Level(level string) (Style, bool) {
+	s, ok := t.Levels[level]
+	return s, ok
+}
+
+// Paint wraps the text in the style
+//
+// This goes through fatih/color, so it produces plain text whenever color.NoColor is set
+func (t *Theme) Paint(s Style, text string) string {
+	if s.IsPlain() {
+		return text
+	}
+
+	return color.New(s.colorAttrs(t.depth)...).Sprint(text)
+}
+
+// Sequences returns the escape sequences that start and end text in the style (both empty for the plain style)
+//
+// Unlike Paint, this does not look at color.NoColor
+func (t *Theme) Sequences(s Style) (start, end string) {
+	seq := s.sequences(t.depth)
+	return seq[0], seq[1]
+}
+
+// PrettyStyle returns the theme's json palette in the form tidwall/pretty wants
+func (t *Theme) PrettyStyle() *pretty.Style {
+	return &pretty.Style{
+		Key:    t.JSON.Key.sequences(t.depth),
+		String: t.JSON.String.sequences(t.depth),
+		Number: t.JSON.Number.sequences(t.depth),
+		True:   t.JSON.True.sequences(t.depth),
+		False:  t.JSON.False.sequences(t.depth),
+		Null:   t.JSON.Null.sequences(t.depth),
+		Escape: t.JSON.Escape.sequences(t.depth),
+		Append: pretty.TerminalStyle.Append,
+	}
+}
@@ -0,0 +1,224 @@
+package theme
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tidwall/pretty"
+)
+
+func TestParseStyle(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		spec    string
+		depth   Depth
+		want    [2]string
+		wantErr bool
+	}{
+		{
+			name: "plain",
+			spec: "",
+			want: [2]string{"", ""},
+		},
+		{
+			name: "color",
+			spec: "hi-blue",
+			want: [2]string{"\x1b[94m", "\x1b[0m"},
+		},
+		{
+			name:  "bold truecolor on a background",
+			spec:  "bold #010203 on black",
+			depth: DepthTrueColor,
+			want:  [2]string{"\x1b[1;38;2;1;2;3;40m", "\x1b[0m"},
+		},
+		{
+			name:    "dangling on",
+			spec:    "red on",
+			wantErr: true,
+		},
+		{
+			name:    "unknown word",
+			spec:    "sparkly red",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseStyle(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseStyle() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if seq := got.sequences(tt.depth); seq != tt.want {
+				t.Errorf("ParseStyle() sequences = %q, want %q", seq, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve_builtins(t *testing.T) {
+	t.Parallel()
+	for _, name := range Names() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			for _, depth := range []Depth{Depth16, Depth256, DepthTrueColor} {
+				th, err := Resolve(name, nil, depth)
+				if err != nil {
+					t.Fatalf("Resolve(%q) error = %v", name, err)
+				}
+
+				for _, level := range []string{"NONE", "DEBU", "INFO", "WARN", "ERRO"} {
+					if s, ok := th.Level(level); !ok || s.IsPlain() {
+						t.Errorf("Resolve(%q) has no style for level %s", name, level)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestResolve_darkMatchesTerminalStyle(t *testing.T) {
+	t.Parallel()
+	th, err := Resolve("dark", nil, Depth16)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	got := th.PrettyStyle()
+	want := pretty.TerminalStyle
+	for _, pair := range [][2][2]string{
+		{got.Key, want.Key},
+		{got.String, want.String},
+		{got.Number, want.Number},
+		{got.True, want.True},
+		{got.False, want.False},
+		{got.Null, want.Null},
+		{got.Escape, want.Escape},
+	} {
+		if pair[0][0] != pair[1][0] {
+			t.Errorf("dark PrettyStyle() start = %q, want %q", pair[0][0], pair[1][0])
+		}
+	}
+}
+
+func TestResolve_userThemes(t *testing.T) {
+	t.Parallel()
+	specs := map[string]Spec{
+		"mine": {
+			Key:    "208",
+			Levels: map[string]string{"info": "blue"},
+		},
+		"derived": {
+			Extends: "mine",
+			Key:     "red",
+		},
+		"light": {
+			Key: "black",
+		},
+		"loop": {
+			Extends: "loop",
+		},
+		"broken": {
+			Key: "sparkly",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		theme     string
+		wantKey   [2]string
+		wantInfo  [2]string
+		wantError bool
+	}{
+		{
+			name:     "extends default",
+			theme:    "mine",
+			wantKey:  [2]string{"\x1b[38;5;208m", "\x1b[0m"},
+			wantInfo: [2]string{"\x1b[34m", "\x1b[0m"},
+		},
+		{
+			name:     "extends user theme",
+			theme:    "derived",
+			wantKey:  [2]string{"\x1b[31m", "\x1b[0m"},
+			wantInfo: [2]string{"\x1b[34m", "\x1b[0m"},
+		},
+		{
+			name:     "shadows builtin",
+			theme:    "light",
+			wantKey:  [2]string{"\x1b[30m", "\x1b[0m"},
+			wantInfo: [2]string{"\x1b[32m", "\x1b[0m"},
+		},
+		{
+			name:      "cycle",
+			theme:     "loop",
+			wantError: true,
+		},
+		{
+			name:      "bad style",
+			theme:     "broken",
+			wantError: true,
+		},
+		{
+			name:      "unknown",
+			theme:     "nope",
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			th, err := Resolve(tt.theme, specs, Depth256)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantError)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := th.Key.sequences(th.Depth()); got != tt.wantKey {
+				t.Errorf("Resolve() key = %q, want %q", got, tt.wantKey)
+			}
+
+			info, _ := th.Level("INFO")
+			if got := info.sequences(th.Depth()); got != tt.wantInfo {
+				t.Errorf("Resolve() INFO = %q, want %q", got, tt.wantInfo)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "themes.json")
+	if err := os.WriteFile(configPath, []byte(`{"mine": {"extends": "colorblind", "key": "red"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	th, err := Load("mine", configPath, Depth16)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got, want := th.Key.sequences(Depth16), [2]string{"\x1b[31m", "\x1b[0m"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() key = %q, want %q", got, want)
+	}
+
+	if _, err := Load("dark", filepath.Join(dir, "missing.json"), Depth16); err != nil {
+		t.Errorf("Load() with a missing config error = %v", err)
+	}
+
+	if _, err := Load("nope", configPath, Depth16); !errors.Is(err, ErrUnknownTheme) {
+		t.Errorf("Load() unknown theme error = %v, want %v", err, ErrUnknownTheme)
+	}
+}